@@ -34,6 +34,11 @@ def _nmts_gen_txtpbs_impl(ctx):
         for template in ctx.files.templates:
             inputs.append(template)
             arguments += ["--tmpl_filename", template.short_path]
+        if ctx.attr.id_prefix:
+            name = infile.basename[:-len(".json")]
+            arguments += ["--id_prefix", ctx.attr.id_prefix.replace("{name}", name)]
+        for external_id in ctx.attr.external_ids:
+            arguments += ["--external_id", external_id]
 
         ctx.actions.run(
             outputs = [outfile],
@@ -64,6 +69,14 @@ _nmts_gen_txtpbs = rule(
             doc = "A list of JSON files to be used as input",
             mandatory = True,
         ),
+        "id_prefix": attr.string(
+            doc = "If set, prepended to every entity ID defined by each generated txtpb. \"{name}\" is replaced by the src filename without its .json extension",
+            mandatory = False,
+        ),
+        "external_ids": attr.string_list(
+            doc = "IDs of entities defined elsewhere that the generated relationships may refer to; never prefixed by id_prefix",
+            mandatory = False,
+        ),
         "_template2txtpb": attr.label(
             default = "//v1/cmd/template2txtpb",
             cfg = "exec",
@@ -0,0 +1,89 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	"outernetcouncil.org/nmts/v1/lib/topology"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+func listComponents(appCtx *cli.Context) error {
+	kinds, err := parseRelationshipKinds(appCtx.String("kinds"))
+	if err != nil {
+		return err
+	}
+	erColl, err := readGraph(appCtx)
+	if err != nil {
+		return err
+	}
+	g, err := graph.FromCollection(erColl)
+	if err != nil {
+		return err
+	}
+	return writeIslands(appCtx, topology.Islands(g, kinds...))
+}
+
+// parseRelationshipKinds parses a comma-separated list of relationship kinds,
+// e.g. "RK_CONTAINS,RK_TRAVERSES". The empty string yields no kinds.
+func parseRelationshipKinds(s string) ([]npb.RK, error) {
+	kinds := []npb.RK{}
+	if s == "" {
+		return kinds, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		rk, err := er.ParseRelationshipKind(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		kinds = append(kinds, rk)
+	}
+	return kinds, nil
+}
+
+func writeIslands(appCtx *cli.Context, islands []topology.Island) error {
+	w := appCtx.App.Writer
+	switch format := appCtx.String("format"); format {
+	case "text":
+		if _, err := fmt.Fprintf(w, "%d islands\n", len(islands)); err != nil {
+			return err
+		}
+		for i, island := range islands {
+			platforms := "none"
+			if len(island.Platforms) > 0 {
+				platforms = strings.Join(island.Platforms, ", ")
+			}
+			if _, err := fmt.Fprintf(w, "island %d: %d entities, representative %s\n\tplatforms: %s\n", i+1, len(island.IDs), island.Representative, platforms); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		data, err := json.MarshalIndent(islands, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	default:
+		return fmt.Errorf("unknown format '%v'", format)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"outernetcouncil.org/nmts/v1/lib/control"
+	"outernetcouncil.org/nmts/v1/lib/graph"
+)
+
+func reportControl(appCtx *cli.Context) error {
+	erColl, err := readGraph(appCtx)
+	if err != nil {
+		return err
+	}
+	g, err := graph.FromCollection(erColl)
+	if err != nil {
+		return err
+	}
+	return writeCoverage(appCtx, control.Analyze(g))
+}
+
+func writeCoverage(appCtx *cli.Context, c *control.Coverage) error {
+	w := appCtx.App.Writer
+	switch format := appCtx.String("format"); format {
+	case "text":
+		fmt.Fprintf(w, "%d SDN agents\n", len(c.Agents))
+		for _, a := range c.Agents {
+			fmt.Fprintf(w, "%s: controls %s; spans %d entities; worst-case latency %v", a.ID, strings.Join(a.Controls, ", "), len(a.Span), a.Latency)
+			if a.Enactor != "" {
+				fmt.Fprintf(w, " (%v to agent, %v to enact at %s)", a.ControllerToAgentLatency, a.EnactmentLatency, a.Enactor)
+			}
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%d uncontrolled entities\n", len(c.Uncontrolled))
+		for _, e := range c.Uncontrolled {
+			fmt.Fprintf(w, "\t%s (%s)\n", e.ID, e.Kind)
+		}
+		fmt.Fprintf(w, "%d multiply controlled entities\n", len(c.MultiplyControlled))
+		for _, e := range c.MultiplyControlled {
+			fmt.Fprintf(w, "\t%s (%s): %s\n", e.ID, e.Kind, strings.Join(e.Agents, ", "))
+		}
+		if c.SlowestAgent != "" {
+			if _, err := fmt.Fprintf(w, "worst-case control latency: %v, through %s\n", c.Latency, c.SlowestAgent); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		data, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	default:
+		return fmt.Errorf("unknown format '%v'", format)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/urfave/cli/v2"
+	"outernetcouncil.org/nmts/v1/lib/diff"
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+)
+
+// Exit codes of the diff command, following diff(1).
+const (
+	diffExitDiffers = 1
+	diffExitTrouble = 2
+)
+
+func diffGraphs(appCtx *cli.Context) error {
+	d, err := readDiff(appCtx)
+	if err == nil {
+		err = writeDiff(appCtx, d)
+	}
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("fatal error: %v", err), diffExitTrouble)
+	}
+
+	if !d.Empty() {
+		return cli.Exit("", diffExitDiffers)
+	}
+	return nil
+}
+
+func readDiff(appCtx *cli.Context) (*diff.Diff, error) {
+	args := appCtx.Args().Slice()
+	sep := slices.Index(args, "--")
+	if sep < 1 || sep == len(args)-1 {
+		return nil, fmt.Errorf("expected OLD... -- NEW...")
+	}
+
+	oldColl, err := readSources(appCtx, args[:sep], nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading old graph: %w", err)
+	}
+	newColl, err := readSources(appCtx, args[sep+1:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading new graph: %w", err)
+	}
+	return diff.Collections(oldColl, newColl), nil
+}
+
+func writeDiff(appCtx *cli.Context, d *diff.Diff) error {
+	w := appCtx.App.Writer
+	switch format := appCtx.String("format"); format {
+	case "text":
+		return d.WriteText(w)
+	case "json":
+		data, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case "patch":
+		data, err := er.MarshalMessage(d.Patch(), er.FormatText)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	default:
+		return fmt.Errorf("unknown format '%v'", format)
+	}
+}
@@ -31,11 +31,16 @@ import (
 	"github.com/urfave/cli/v2"
 	"google.golang.org/protobuf/encoding/prototext"
 	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	"outernetcouncil.org/nmts/v1/lib/topology"
 	npb "outernetcouncil.org/nmts/v1/proto"
 	eklpb "outernetcouncil.org/nmts/v1/proto/ek/logical"
 )
 
 func exportDot(appCtx *cli.Context) error {
+	if appCtx.IsSet("layer") {
+		return exportAdjacencyDot(appCtx)
+	}
 	g, err := readGraph(appCtx)
 	if err != nil {
 		return err
@@ -137,6 +142,44 @@ func exportDot(appCtx *cli.Context) error {
 	return err
 }
 
+// exportAdjacencyDot exports the adjacencies between network nodes at the
+// layer given by the --layer flag, with an edge for each underlying link.
+func exportAdjacencyDot(appCtx *cli.Context) error {
+	layer, err := topology.ParseLayer(appCtx.String("layer"))
+	if err != nil {
+		return err
+	}
+	erColl, err := readGraph(appCtx)
+	if err != nil {
+		return err
+	}
+	g, err := graph.FromCollection(erColl)
+	if err != nil {
+		return err
+	}
+	ag := topology.DeriveAdjacencyGraph(g, layer)
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("digraph G {\n")
+	fmt.Fprintf(buf, "\tlabel = %q;\n", "layer "+appCtx.String("layer"))
+	buf.WriteString("\tnode [shape=box];\n")
+	for _, id := range ag.Nodes {
+		fmt.Fprintf(buf, "\t%q\n", id)
+	}
+	for _, adj := range ag.Adjacencies {
+		for _, link := range adj.Links {
+			label := fmt.Sprintf("%s\n%s -> %s", link.ID, link.AInterface, link.ZInterface)
+			if link.Latency > 0 {
+				label += "\n" + link.Latency.String()
+			}
+			fmt.Fprintf(buf, "\t%q -> %q [label=%q]\n", adj.A, adj.Z, label)
+		}
+	}
+	buf.WriteString("}\n")
+	_, err = io.Copy(appCtx.App.Writer, buf)
+	return err
+}
+
 // Nodes of shape record can have multiple fields, which are provided as a
 // "label" in the form of a sequence of pipe separated "port" / value pairs
 // where the "port" is wrapped in angle brackets and the value is HTML escaped.
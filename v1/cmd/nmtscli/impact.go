@@ -0,0 +1,130 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	"outernetcouncil.org/nmts/v1/lib/impact"
+	mefpb "outernetcouncil.org/nmts/v1/proto/types/mef"
+)
+
+func reportImpact(appCtx *cli.Context) error {
+	failed := []string{}
+	for _, id := range strings.Split(appCtx.String("fail"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			failed = append(failed, id)
+		}
+	}
+	if len(failed) == 0 {
+		return fmt.Errorf("missing --fail")
+	}
+	erColl, err := readGraph(appCtx)
+	if err != nil {
+		return err
+	}
+	g, err := graph.FromCollection(erColl)
+	if err != nil {
+		return err
+	}
+	i, err := impact.Propagate(g, failed)
+	if err != nil {
+		return err
+	}
+	report := impactReport{Impact: i}
+	if files := appCtx.StringSlice("evc"); len(files) > 0 {
+		evcs, err := readEVCs(files)
+		if err != nil {
+			return err
+		}
+		services, err := impact.MapServices(g, evcs)
+		if err != nil {
+			return err
+		}
+		report.Services = i.Services(services)
+	}
+	return writeImpact(appCtx, report)
+}
+
+// impactReport is the impact of failures, and their effects on services
+// if any were given.
+type impactReport struct {
+	*impact.Impact
+	Services *impact.ServiceImpact `json:",omitempty"`
+}
+
+// readEVCs reads an EVC from each of the given files.
+func readEVCs(filenames []string) ([]*mefpb.EVC, error) {
+	evcs := []*mefpb.EVC{}
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		evc := &mefpb.EVC{}
+		if err := er.UnmarshalMessage(data, er.FragmentFormatFromFilename(filename), evc); err != nil {
+			return nil, fmt.Errorf("parsing EVC %q: %w", filename, err)
+		}
+		evcs = append(evcs, evc)
+	}
+	return evcs, nil
+}
+
+func writeImpact(appCtx *cli.Context, report impactReport) error {
+	i := report.Impact
+	w := appCtx.App.Writer
+	switch format := appCtx.String("format"); format {
+	case "text":
+		fmt.Fprintf(w, "%d entities failed: %s\n", len(i.Failed), strings.Join(i.Failed, ", "))
+		fmt.Fprintf(w, "%d entities affected\n", len(i.Effects))
+		for _, e := range i.Effects {
+			state := "down"
+			if e.Unmanaged {
+				state = "unmanaged"
+			}
+			fmt.Fprintf(w, "%s (%s): %s\n", e.ID, e.Kind, state)
+			for _, step := range e.Chain {
+				if _, err := fmt.Fprintf(w, "\t%s %s\n", step.ID, step.Rule); err != nil {
+					return err
+				}
+			}
+		}
+		if s := report.Services; s != nil {
+			fmt.Fprintf(w, "%d EVCs affected\n", len(s.EVCs))
+			for _, e := range s.EVCs {
+				fmt.Fprintf(w, "%s: %s, endpoints lost: %s\n", e.EVC, e.State, strings.Join(e.Endpoints, ", "))
+			}
+			if _, err := fmt.Fprintf(w, "%d UNIs lost connectivity: %s\n", len(s.UNIs), strings.Join(s.UNIs, ", ")); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	default:
+		return fmt.Errorf("unknown format '%v'", format)
+	}
+}
@@ -23,6 +23,8 @@ import (
 
 	"github.com/urfave/cli/v2"
 	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	"outernetcouncil.org/nmts/v1/lib/labels"
+	npb "outernetcouncil.org/nmts/v1/proto"
 )
 
 const appName = "nmtscli"
@@ -36,7 +38,10 @@ func main() {
 
 func App(stdin io.Reader, stdout, stderr io.Writer) *cli.App {
 	return &cli.App{
-		Name: appName,
+		Name:      appName,
+		Reader:    stdin,
+		Writer:    stdout,
+		ErrWriter: stderr,
 		Commands: []*cli.Command{
 			{
 				Name: "export",
@@ -44,56 +49,300 @@ func App(stdin io.Reader, stdout, stderr io.Writer) *cli.App {
 					{
 						Name:   "dot",
 						Action: exportDot,
-						Flags: []cli.Flag{
+						Flags: append(exportFlags(),
 							&cli.StringFlag{
 								Name: "rankdir",
 							},
-						},
+							&cli.StringFlag{
+								Name:  "layer",
+								Usage: "export the adjacencies between network nodes at this layer instead: l2, l3, or Interface.layer fields such as 'mpls'",
+							},
+						),
 					},
 					{
 						Name:   "d2",
 						Action: exportD2,
+						Flags:  exportFlags(),
 					},
 					{
 						Name:   "html",
 						Action: exportHtml,
+						Flags:  exportFlags(),
 					},
 					{
 						Name:   "nquads",
 						Action: exportNQuads,
+						Flags:  exportFlags(),
 					},
 					{
 						Name:   "prolog",
 						Action: exportProlog,
+						Flags:  exportFlags(),
 					},
 				},
 			},
+			{
+				Name:      "diff",
+				Usage:     "compare two versions of a graph",
+				ArgsUsage: "OLD... -- NEW...",
+				Action:    diffGraphs,
+				Flags: append(inputFlags(),
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "output format: text, json, or patch for a Patch in protobuf text format",
+						Value: "text",
+					},
+				),
+			},
+			{
+				Name:      "patch",
+				Usage:     "apply a Patch to a graph and write out the result",
+				ArgsUsage: "[input files]",
+				Action:    patchGraph,
+				Flags: append(inputFlags(),
+					&cli.StringFlag{
+						Name:  "patch",
+						Usage: "file holding the Patch message to apply",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "output format when writing to standard output: text, json or binary",
+						Value: er.FormatText.String(),
+					},
+					&cli.BoolFlag{
+						Name:  "in-place",
+						Usage: "rewrite each input file with its patched contents, instead of writing a single fragment to standard output",
+					},
+					&cli.StringFlag{
+						Name:  "add-to",
+						Usage: "with --in-place, the file to write added entities and relationships to; defaults to the first input file",
+					},
+				),
+			},
+			{
+				Name:      "components",
+				Usage:     "list the islands of a graph: the sets of entities connected to each other, but not to the rest",
+				ArgsUsage: "[input files]",
+				Action:    listComponents,
+				Flags: append(inputFlags(),
+					&cli.StringFlag{
+						Name:  "kinds",
+						Usage: "only follow relationships of these kinds, e.g. 'RK_CONTAINS,RK_ORIGINATES,RK_TERMINATES,RK_TRAVERSES'; by default every kind is followed",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "output format: text or json, which also lists each island's entities",
+						Value: "text",
+					},
+				),
+			},
+			{
+				Name:      "control",
+				Usage:     "list the entities each SDN agent controls, the controllable entities no agent or several agents control, and the worst-case control latency",
+				ArgsUsage: "[input files]",
+				Action:    reportControl,
+				Flags: append(inputFlags(),
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "output format: text or json, which also lists each agent's span",
+						Value: "text",
+					},
+				),
+			},
+			{
+				Name:      "impact",
+				Usage:     "list the entities affected by the failure of some entities, and explain why each is affected",
+				ArgsUsage: "[input files]",
+				Action:    reportImpact,
+				Flags: append(inputFlags(),
+					&cli.StringFlag{
+						Name:  "fail",
+						Usage: "comma-separated IDs of the entities that fail",
+					},
+					&cli.StringSliceFlag{
+						Name:  "evc",
+						Usage: "file holding an EVC message whose endpoints are at the EK_UNIs with the same IDs or MEF UNI IDs; also reports the EVCs degraded or down and the UNIs that lost connectivity. May be repeated",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "output format: text or json",
+						Value: "text",
+					},
+				),
+			},
+			{
+				Name:      "match",
+				Usage:     "find the paths through a graph that match a pattern, e.g. 'EK_NETWORK_NODE -[RK_CONTAINS]-> EK_INTERFACE'",
+				ArgsUsage: "PATTERN [input files]",
+				Action:    matchPattern,
+				Flags: append(inputFlags(),
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "output format: text, with the IDs bound by each match on a line, or json",
+						Value: "text",
+					},
+				),
+			},
+			{
+				Name:      "resilience",
+				Usage:     "fail entities k at a time and rank the scenarios by the number of pairs of endpoints made unreachable",
+				ArgsUsage: "[input files]",
+				Action:    sweepResilience,
+				Flags: append(inputFlags(),
+					&cli.StringFlag{
+						Name:  "layer",
+						Usage: "the layer at which reachability is computed: l2, l3, or Interface.layer fields such as 'mpls'",
+						Value: "l3",
+					},
+					&cli.StringFlag{
+						Name:  "endpoints",
+						Usage: "compute reachability between the network nodes whose labels match this selector; by default every network node",
+					},
+					&cli.StringFlag{
+						Name:  "candidates",
+						Usage: "fail the entities whose labels match this selector; by default every platform, physical medium link and network node",
+					},
+					&cli.IntFlag{
+						Name:  "k",
+						Usage: "the number of candidates that fail together in each scenario",
+						Value: 1,
+					},
+					&cli.IntFlag{
+						Name:  "parallelism",
+						Usage: "the number of scenarios evaluated at once; by default the number of CPUs",
+					},
+					&cli.IntFlag{
+						Name:  "top",
+						Usage: "only report this many of the worst scenarios; by default all of them",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "output format: text or json",
+						Value: "text",
+					},
+				),
+			},
+			{
+				Name:      "spof",
+				Usage:     "find the entities and relationships whose failure disconnects network nodes, ranked by the number of pairs of nodes disconnected",
+				ArgsUsage: "[input files]",
+				Action:    reportSinglePointsOfFailure,
+				Flags: append(inputFlags(),
+					&cli.StringFlag{
+						Name:  "layer",
+						Usage: "the layer at which network nodes are connected: l2, l3, or Interface.layer fields such as 'mpls'",
+						Value: "l3",
+					},
+					&cli.StringFlag{
+						Name:  "from",
+						Usage: "only consider the connectivity of the network nodes whose labels match this selector; by default every network node",
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "only consider connectivity to the network nodes whose labels match this selector; by default every network node",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "output format: text, with the groups of nodes each failure leaves disconnected, or json",
+						Value: "text",
+					},
+				),
+			},
 			{
 				Name:   "validate",
 				Action: validateGraph,
+				Flags:  inputFlags(),
 			},
 		},
 	}
 }
 
+// inputFlags returns the flags accepted by every command that reads a graph.
+func inputFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "merge-policy",
+			Usage: "how to handle an entity defined more than once: error, identical, last-wins or deep",
+			Value: er.MergeError.String(),
+		},
+	}
+}
+
+// exportFlags returns the flags accepted by every export subcommand.
+func exportFlags() []cli.Flag {
+	return append(inputFlags(),
+		&cli.StringFlag{
+			Name:  "selector",
+			Usage: "only export entities whose labels match this selector, e.g. 'env=prod,region in (us-east,us-west),!deprecated', and the relationships between them",
+		},
+	)
+}
+
 func readGraph(appCtx *cli.Context) (*er.Collection, error) {
 	return readGraphWithValidator(appCtx, nil)
 }
 
 func readGraphWithValidator(appCtx *cli.Context, v er.Validator) (*er.Collection, error) {
-	srcs := appCtx.Args().Slice()
+	return readSources(appCtx, appCtx.Args().Slice(), v)
+}
+
+// readSources reads the graph from the given fragment files, directories and
+// globs, as configured by the command's input flags.
+func readSources(appCtx *cli.Context, srcs []string, v er.Validator) (*er.Collection, error) {
 	if len(srcs) == 0 {
 		return nil, fmt.Errorf("missing input files")
 	}
 
-	g, err := er.ReadFragmentFiles(srcs)
+	mergePolicy, err := er.ParseMergePolicy(appCtx.String("merge-policy"))
+	if err != nil {
+		return nil, err
+	}
+	selector, err := labels.Parse(appCtx.String("selector"))
+	if err != nil {
+		return nil, err
+	}
+
+	sources := er.NewSourceMap()
+	fr := &er.FragmentReader{Stdin: appCtx.App.Reader, Sources: sources}
+	g, err := fr.ReadFragments(srcs)
 	if err != nil {
 		return nil, err
 	}
 
 	collBldr := er.NewCollectionBuilder(v)
+	collBldr.SetSourceMap(sources)
+	collBldr.SetMergePolicy(mergePolicy)
 	if err := collBldr.InsertFragments(g); err != nil {
 		return nil, err
 	}
+	erColl, err := collBldr.Build()
+	if err != nil || selector.Empty() {
+		return erColl, err
+	}
+	return selectEntities(erColl, selector)
+}
+
+// selectEntities returns the entities in erColl that match selector, and
+// the relationships between them.
+func selectEntities(erColl *er.Collection, selector labels.Selector) (*er.Collection, error) {
+	selected := &npb.Fragment{}
+	kept := map[string]bool{}
+	for _, entity := range erColl.ToFragment().GetEntity() {
+		if selector.Matches(entity.GetLabels()) {
+			selected.Entity = append(selected.Entity, entity)
+			kept[entity.GetId()] = true
+		}
+	}
+	for _, r := range erColl.Relationships() {
+		if kept[r.A] && kept[r.Z] {
+			selected.Relationship = append(selected.Relationship, r.ToProto())
+		}
+	}
+
+	collBldr := er.NewNonValidatingCollectionBuilder()
+	collBldr.SetSourceMap(erColl.Sources)
+	if err := collBldr.InsertFragments(selected); err != nil {
+		return nil, err
+	}
 	return collBldr.Build()
 }
@@ -0,0 +1,68 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	"outernetcouncil.org/nmts/v1/lib/pattern"
+)
+
+func matchPattern(appCtx *cli.Context) error {
+	args := appCtx.Args().Slice()
+	if len(args) < 1 {
+		return fmt.Errorf("expected PATTERN [input files]")
+	}
+	p, err := pattern.Compile(args[0])
+	if err != nil {
+		return err
+	}
+
+	erColl, err := readSources(appCtx, args[1:], nil)
+	if err != nil {
+		return err
+	}
+	g, err := graph.FromCollection(erColl)
+	if err != nil {
+		return err
+	}
+	return writeBindings(appCtx, p.Match(g))
+}
+
+func writeBindings(appCtx *cli.Context, bindings []pattern.Binding) error {
+	w := appCtx.App.Writer
+	switch format := appCtx.String("format"); format {
+	case "text":
+		for _, b := range bindings {
+			if _, err := fmt.Fprintln(w, strings.Join(b.IDs, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		data, err := json.MarshalIndent(bindings, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	default:
+		return fmt.Errorf("unknown format '%v'", format)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/urfave/cli/v2"
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	"outernetcouncil.org/nmts/v1/lib/validation"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+func patchGraph(appCtx *cli.Context) error {
+	patch, err := readPatch(appCtx.String("patch"))
+	if err != nil {
+		return err
+	}
+
+	srcs := appCtx.Args().Slice()
+	erColl, err := readSources(appCtx, srcs, validation.DefaultValidator{})
+	if err != nil {
+		return err
+	}
+
+	// Record where everything came from before the patch replaces any
+	// entities, so that entities keep their file even when replaced.
+	entityFiles := map[string]string{}
+	for id := range erColl.Entities {
+		if loc, ok := erColl.EntitySource(id); ok {
+			entityFiles[id] = loc.File
+		}
+	}
+	relationshipFiles := map[er.Relationship]string{}
+	for _, r := range erColl.Relationships() {
+		if loc, ok := erColl.RelationshipSource(r); ok {
+			relationshipFiles[r] = loc.File
+		}
+	}
+
+	if err := erColl.Apply(patch); err != nil {
+		return fmt.Errorf("applying patch: %w", err)
+	}
+
+	if !appCtx.Bool("in-place") {
+		format, err := parseFragmentFormat(appCtx.String("format"))
+		if err != nil {
+			return err
+		}
+		data, err := er.MarshalFragment(erColl.ToFragment(), format)
+		if err != nil {
+			return err
+		}
+		_, err = appCtx.App.Writer.Write(data)
+		return err
+	}
+
+	filenames, err := er.ExpandFragmentPaths(srcs)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(filenames, er.StdinPath) {
+		return fmt.Errorf("cannot patch standard input in place")
+	}
+	addTo := appCtx.String("add-to")
+	if addTo == "" {
+		addTo = filenames[0]
+	}
+	if !slices.Contains(filenames, addTo) {
+		filenames = append(filenames, addTo)
+	}
+
+	fragments := map[string]*npb.Fragment{}
+	for _, f := range filenames {
+		fragments[f] = &npb.Fragment{}
+	}
+	fragmentFor := func(file string) *npb.Fragment {
+		if fragment, exists := fragments[file]; exists {
+			return fragment
+		}
+		return fragments[addTo]
+	}
+	// ToFragment is ordered, so each file's fragment is too.
+	for _, entity := range erColl.ToFragment().GetEntity() {
+		fragment := fragmentFor(entityFiles[entity.GetId()])
+		fragment.Entity = append(fragment.Entity, entity)
+	}
+	for _, r := range erColl.Relationships() {
+		fragment := fragmentFor(relationshipFiles[r])
+		fragment.Relationship = append(fragment.Relationship, r.ToProto())
+	}
+
+	// Serialize everything before writing anything, so that an error
+	// doesn't leave the files half patched.
+	contents := map[string][]byte{}
+	for _, f := range filenames {
+		data, err := er.MarshalFragment(fragments[f], fragmentFileFormat(f))
+		if err != nil {
+			return err
+		}
+		contents[f] = data
+	}
+	for _, f := range filenames {
+		if err := os.WriteFile(f, contents[f], 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readPatch(filename string) (*npb.Patch, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("missing --patch")
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	patch := &npb.Patch{}
+	if err := er.UnmarshalMessage(data, er.FragmentFormatFromFilename(filename), patch); err != nil {
+		return nil, fmt.Errorf("parsing patch %q: %w", filename, err)
+	}
+	return patch, nil
+}
+
+func parseFragmentFormat(name string) (er.FragmentFormat, error) {
+	for _, format := range []er.FragmentFormat{er.FormatText, er.FormatBinary, er.FormatJSON} {
+		if format.String() == name {
+			return format, nil
+		}
+	}
+	return er.FormatUnknown, fmt.Errorf("unknown format '%v'", name)
+}
+
+// fragmentFileFormat returns the format to write the named file in: that
+// implied by its extension, or else that of its current content, or else
+// text.
+func fragmentFileFormat(filename string) er.FragmentFormat {
+	if format := er.FragmentFormatFromFilename(filename); format != er.FormatUnknown {
+		return format
+	}
+	if existing, err := os.ReadFile(filename); err == nil && len(existing) > 0 {
+		return er.SniffFragmentFormat(existing)
+	}
+	return er.FormatText
+}
@@ -0,0 +1,95 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	"outernetcouncil.org/nmts/v1/lib/labels"
+	"outernetcouncil.org/nmts/v1/lib/topology"
+)
+
+func sweepResilience(appCtx *cli.Context) error {
+	layer, err := topology.ParseLayer(appCtx.String("layer"))
+	if err != nil {
+		return err
+	}
+	erColl, err := readGraph(appCtx)
+	if err != nil {
+		return err
+	}
+	g, err := graph.FromCollection(erColl)
+	if err != nil {
+		return err
+	}
+	sweep := topology.Sweep{Layer: layer, K: appCtx.Int("k"), Parallelism: appCtx.Int("parallelism"), Top: appCtx.Int("top")}
+	if sweep.Endpoints, err = selectNetworkNodes(g, "endpoints", appCtx.String("endpoints")); err != nil {
+		return err
+	}
+	if s := appCtx.String("candidates"); s != "" {
+		selector, err := labels.Parse(s)
+		if err != nil {
+			return err
+		}
+		for node := range g.AllNodesMatching(selector) {
+			sweep.Candidates = append(sweep.Candidates, node.GetID())
+		}
+		if len(sweep.Candidates) == 0 {
+			return fmt.Errorf("no entity matches --candidates '%v'", s)
+		}
+	}
+	outcomes, err := sweep.Run(appCtx.Context, g)
+	if err != nil {
+		return err
+	}
+	return writeOutcomes(appCtx, outcomes)
+}
+
+func writeOutcomes(appCtx *cli.Context, outcomes []topology.Outcome) error {
+	w := appCtx.App.Writer
+	switch format := appCtx.String("format"); format {
+	case "text":
+		fmt.Fprintf(w, "%d scenarios at layer %v\n", len(outcomes), appCtx.String("layer"))
+		for i, o := range outcomes {
+			fmt.Fprintf(w, "%d. %s: %d endpoint pairs unreachable, %d entities down\n", i+1, strings.Join(o.Failed, ", "), len(o.Unreachable), o.Down)
+			if len(o.EndpointsDown) > 0 {
+				fmt.Fprintf(w, "\tendpoints down: %s\n", strings.Join(o.EndpointsDown, ", "))
+			}
+			for _, pair := range o.Unreachable {
+				if slices.Contains(o.EndpointsDown, pair[0]) || slices.Contains(o.EndpointsDown, pair[1]) {
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "\t%s -> %s\n", pair[0], pair[1]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case "json":
+		data, err := json.MarshalIndent(outcomes, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	default:
+		return fmt.Errorf("unknown format '%v'", format)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	"outernetcouncil.org/nmts/v1/lib/labels"
+	"outernetcouncil.org/nmts/v1/lib/topology"
+)
+
+func reportSinglePointsOfFailure(appCtx *cli.Context) error {
+	layer, err := topology.ParseLayer(appCtx.String("layer"))
+	if err != nil {
+		return err
+	}
+	erColl, err := readGraph(appCtx)
+	if err != nil {
+		return err
+	}
+	g, err := graph.FromCollection(erColl)
+	if err != nil {
+		return err
+	}
+	from, err := selectNetworkNodes(g, "from", appCtx.String("from"))
+	if err != nil {
+		return err
+	}
+	to, err := selectNetworkNodes(g, "to", appCtx.String("to"))
+	if err != nil {
+		return err
+	}
+	return writeFailures(appCtx, g, topology.SinglePointsOfFailure(g, layer, from, to))
+}
+
+// selectNetworkNodes returns the IDs of the network nodes whose labels match
+// the selector given by the named flag, sorted, or nil if it's empty.
+func selectNetworkNodes(g *graph.Graph, flag, s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	selector, err := labels.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for node := range g.AllNodesMatching(selector) {
+		if node.GetKind() == "EK_NETWORK_NODE" {
+			ids = append(ids, node.GetID())
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no network node matches --%v '%v'", flag, s)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func writeFailures(appCtx *cli.Context, g *graph.Graph, failures []topology.Failure) error {
+	w := appCtx.App.Writer
+	switch format := appCtx.String("format"); format {
+	case "text":
+		fmt.Fprintf(w, "%d single points of failure at layer %v\n", len(failures), appCtx.String("layer"))
+		for i, f := range failures {
+			failed := fmt.Sprintf("relationship %v", f.Relationship)
+			if f.Entity != "" {
+				failed = fmt.Sprintf("entity %v (%v)", f.Entity, g.Node(f.Entity).GetKind())
+			}
+			fmt.Fprintf(w, "%d. %s: %d node pairs disconnected, %d other entities affected\n", i+1, failed, f.DisconnectedPairs, len(f.Affected))
+			for _, parts := range f.Partitions {
+				groups := []string{}
+				for _, part := range parts {
+					groups = append(groups, strings.Join(part, ", "))
+				}
+				if _, err := fmt.Fprintf(w, "\t%s\n", strings.Join(groups, " | ")); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case "json":
+		data, err := json.MarshalIndent(failures, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	default:
+		return fmt.Errorf("unknown format '%v'", format)
+	}
+}
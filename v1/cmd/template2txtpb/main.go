@@ -27,6 +27,7 @@ import (
 	"text/template"
 
 	"google.golang.org/protobuf/encoding/prototext"
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
 	npb "outernetcouncil.org/nmts/v1/proto"
 )
 
@@ -53,10 +54,20 @@ func run(args []string, stdin io.Reader, stdout io.Writer) error {
 	outFile := fs.String("output", "", "Output file (stdout if blank)")
 	inFile := fs.String("input", "", "Input file (stdin if blank)")
 
+	idPrefix := fs.String("id_prefix", "", "Prefix prepended to every entity ID defined by the template, and to relationship references to them")
+	externalIDs := []string{}
+	fs.Func("external_id", "ID of an entity defined outside the template that its relationships may refer to; left unprefixed by --id_prefix", func(v string) error {
+		externalIDs = append(externalIDs, v)
+		return nil
+	})
+
 	skipProtoValidation := fs.Bool("skip_proto_validation", false, "Skip validating the template output as a protobuf message and emit it immediately")
 	if err := fs.Parse(args); err != nil {
 		return fmt.Errorf("parsing flags: %w", err)
 	}
+	if *idPrefix != "" && *skipProtoValidation {
+		return fmt.Errorf("--id_prefix requires parsing the template output, so cannot be combined with --skip_proto_validation")
+	}
 
 	// Read in the template file.
 	t, err := template.New(filepath.Base(tmplFilenames[0])).
@@ -123,6 +134,12 @@ func run(args []string, stdin io.Reader, stdout io.Writer) error {
 		return fmt.Errorf("parsing executed template as Fragment: %w", err)
 	}
 
+	if *idPrefix != "" {
+		if err := er.PrefixNamespace(*idPrefix, externalIDs...).Apply(fragmentMsg); err != nil {
+			return fmt.Errorf("prefixing Fragment IDs: %w", err)
+		}
+	}
+
 	// Prettyprint the Fragment message to output
 	text, err := marshalOptions.Marshal(fragmentMsg)
 	if err != nil {
@@ -0,0 +1,220 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package control analyses the control plane of a model: which entities each EK_SDN_AGENT
+// controls, which controllable entities no agent or several agents control, and how long it takes
+// at worst for the SDN controller's instructions to be enacted.
+package control
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// ControllableKinds are the kinds of entity an EK_SDN_AGENT may RK_CONTROLS, sorted.
+var ControllableKinds = []string{
+	"EK_ACCESS_FN",
+	"EK_ANTENNA",
+	"EK_BP_AGENT_FN",
+	"EK_DEMODULATOR",
+	"EK_MODULATOR",
+	"EK_NETWORK_NODE",
+	"EK_PLATFORM",
+	"EK_ROUTE_FN",
+}
+
+// Agent is an EK_SDN_AGENT and the entities it controls.
+type Agent struct {
+	// ID is the ID of the agent.
+	ID string
+
+	// Controls holds the IDs of the entities the agent RK_CONTROLS, sorted.
+	Controls []string
+
+	// Span holds the IDs of the entities the agent controls, directly or transitively, sorted; see
+	// Analyze.
+	Span []string
+
+	// ControllerToAgentLatency is the agent's controller_to_agent_latency.
+	ControllerToAgentLatency time.Duration
+
+	// EnactmentLatency is the greatest enactment_latency of the entities in the agent's span, and
+	// Enactor the ID of the entity it's that of, or empty if none of them has one.
+	EnactmentLatency time.Duration
+	Enactor          string `json:",omitempty"`
+
+	// Latency is the agent's worst-case control latency, the sum of ControllerToAgentLatency and
+	// EnactmentLatency.
+	Latency time.Duration
+}
+
+// Entity is a controllable entity and what controls it.
+type Entity struct {
+	// ID is the ID of the entity, and Kind its kind.
+	ID   string
+	Kind string
+
+	// Agents holds the IDs of the agents controlling the entity, sorted: those that RK_CONTROLS it,
+	// and those that RK_CONTROLS a route function that RK_CONTROLS it.
+	Agents []string
+}
+
+// Coverage is the analysis of a model's control plane.
+type Coverage struct {
+	// Agents holds every agent, sorted by ID.
+	Agents []Agent
+
+	// Uncontrolled holds the controllable entities in no agent's span, sorted by ID.
+	Uncontrolled []Entity
+
+	// MultiplyControlled holds the controllable entities controlled by more than one agent, sorted by
+	// ID.
+	MultiplyControlled []Entity
+
+	// Latency is the greatest worst-case control latency of any agent, and SlowestAgent the ID of
+	// that agent, or empty if there are no agents.
+	Latency      time.Duration
+	SlowestAgent string `json:",omitempty"`
+}
+
+// Analyze analyses the control plane of g. An agent's span is what it can reach following the
+// RK_CONTROLS relationships of the agent and of the route functions in its span, and the
+// RK_CONTAINS relationships of the entities in its span, so an agent controlling a platform or a
+// network node controls everything the platform or node contains. Other agents in the span are
+// included but not followed, since what they control is theirs.
+//
+// An entity is uncontrolled if it's in no agent's span, but multiply controlled only if several
+// agents control it, directly or through a route function: an agent controlling a platform and
+// another controlling a modulator on it both span the modulator, but only the latter controls it.
+//
+// An agent's worst-case control latency is its controller_to_agent_latency plus the greatest
+// enactment_latency of the route functions, modulators and demodulators in its span.
+func Analyze(g *graph.Graph) *Coverage {
+	c := &Coverage{Agents: []Agent{}, Uncontrolled: []Entity{}, MultiplyControlled: []Entity{}}
+	spanned := map[string]bool{}
+	for node := range g.AllNodesOfKind("EK_SDN_AGENT") {
+		a := analyzeAgent(g, node)
+		for _, id := range a.Span {
+			spanned[id] = true
+		}
+		c.Agents = append(c.Agents, a)
+		if c.SlowestAgent == "" || a.Latency > c.Latency || (a.Latency == c.Latency && a.ID < c.SlowestAgent) {
+			c.Latency, c.SlowestAgent = a.Latency, a.ID
+		}
+	}
+	slices.SortFunc(c.Agents, func(l, r Agent) int { return cmp.Compare(l.ID, r.ID) })
+
+	for _, kind := range ControllableKinds {
+		for node := range g.AllNodesOfKind(kind) {
+			e := Entity{ID: node.GetID(), Kind: kind, Agents: agentsControlling(g, node.GetID())}
+			if !spanned[e.ID] {
+				c.Uncontrolled = append(c.Uncontrolled, e)
+			}
+			if len(e.Agents) > 1 {
+				c.MultiplyControlled = append(c.MultiplyControlled, e)
+			}
+		}
+	}
+	slices.SortFunc(c.Uncontrolled, func(l, r Entity) int { return cmp.Compare(l.ID, r.ID) })
+	slices.SortFunc(c.MultiplyControlled, func(l, r Entity) int { return cmp.Compare(l.ID, r.ID) })
+	return c
+}
+
+// agentsControlling returns the IDs of the agents that RK_CONTROLS the given entity or a route
+// function that RK_CONTROLS it, sorted.
+func agentsControlling(g *graph.Graph, id string) []string {
+	agents := []string{}
+	for a := range g.InNeighbors(id, npb.RK_RK_CONTROLS) {
+		switch g.Node(a).GetKind() {
+		case "EK_SDN_AGENT":
+			agents = append(agents, a)
+		case "EK_ROUTE_FN":
+			for agent := range g.InNeighbors(a, npb.RK_RK_CONTROLS) {
+				if g.Node(agent).GetKind() == "EK_SDN_AGENT" {
+					agents = append(agents, agent)
+				}
+			}
+		}
+	}
+	slices.Sort(agents)
+	return slices.Compact(agents)
+}
+
+// analyzeAgent returns the span and latency of the given agent.
+func analyzeAgent(g *graph.Graph, node *graph.Node) Agent {
+	a := Agent{
+		ID:                       node.GetID(),
+		Controls:                 []string{},
+		Span:                     []string{},
+		ControllerToAgentLatency: node.GetEntity().GetEkSdnAgent().GetControllerToAgentLatency().AsDuration(),
+	}
+	for id := range g.OutNeighbors(a.ID, npb.RK_RK_CONTROLS) {
+		a.Controls = append(a.Controls, id)
+	}
+	slices.Sort(a.Controls)
+
+	seen := map[string]bool{a.ID: true}
+	queue := slices.Clone(a.Controls)
+	for _, id := range queue {
+		seen[id] = true
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		a.Span = append(a.Span, id)
+
+		next := g.Node(id)
+		if latency := enactmentLatency(next); latency > a.EnactmentLatency || (latency > 0 && latency == a.EnactmentLatency && id < a.Enactor) {
+			a.EnactmentLatency, a.Enactor = latency, id
+		}
+		if next.GetKind() == "EK_SDN_AGENT" {
+			continue
+		}
+		for z := range g.OutNeighbors(id, npb.RK_RK_CONTAINS) {
+			if !seen[z] {
+				seen[z] = true
+				queue = append(queue, z)
+			}
+		}
+		if next.GetKind() == "EK_ROUTE_FN" {
+			for z := range g.OutNeighbors(id, npb.RK_RK_CONTROLS) {
+				if !seen[z] {
+					seen[z] = true
+					queue = append(queue, z)
+				}
+			}
+		}
+	}
+	slices.Sort(a.Span)
+	a.Latency = a.ControllerToAgentLatency + a.EnactmentLatency
+	return a
+}
+
+// enactmentLatency returns the enactment_latency of the given entity, or 0 if it has none.
+func enactmentLatency(node *graph.Node) time.Duration {
+	e := node.GetEntity()
+	switch node.GetKind() {
+	case "EK_ROUTE_FN":
+		return e.GetEkRouteFn().GetEnactmentLatency().AsDuration()
+	case "EK_MODULATOR":
+		return e.GetEkModulator().GetEnactmentLatency().AsDuration()
+	case "EK_DEMODULATOR":
+		return e.GetEkDemodulator().GetEnactmentLatency().AsDuration()
+	}
+	return 0
+}
@@ -0,0 +1,144 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control_test
+
+import (
+	"testing"
+	"time"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
+
+	"outernetcouncil.org/nmts/v1/lib/control"
+	testutil "outernetcouncil.org/nmts/v1/lib/utilities/testing"
+)
+
+// Platform p holds network node n, whose route function controls it and whose agent a1 controls the
+// platform, and a modulator that agent a2 controls directly, along with a demodulator. Route
+// function lone, platform p2 and its antenna are controlled by no agent.
+const controlFragment = `
+entity { id: "p" ek_platform{} }
+entity { id: "p/ant" ek_antenna{} }
+entity { id: "p/mod" ek_modulator{ enactment_latency{ nanos: 8000000 } } }
+entity { id: "p/demod" ek_demodulator{ enactment_latency{ nanos: 3000000 } } }
+entity { id: "n" ek_network_node{} }
+entity { id: "n/rf" ek_route_fn{ enactment_latency{ nanos: 5000000 } } }
+entity { id: "n/if" ek_interface{ eth{} } }
+entity { id: "a1" ek_sdn_agent{ controller_to_agent_latency{ nanos: 20000000 } } }
+entity { id: "a2" ek_sdn_agent{ controller_to_agent_latency{ nanos: 50000000 } } }
+entity { id: "lone" ek_route_fn{} }
+entity { id: "p2" ek_platform{} }
+entity { id: "p2/ant" ek_antenna{} }
+relationship { a: "p" kind: RK_CONTAINS z: "p/ant" }
+relationship { a: "p" kind: RK_CONTAINS z: "p/mod" }
+relationship { a: "p" kind: RK_CONTAINS z: "n" }
+relationship { a: "n" kind: RK_CONTAINS z: "n/rf" }
+relationship { a: "n" kind: RK_CONTAINS z: "n/if" }
+relationship { a: "n" kind: RK_CONTAINS z: "a1" }
+relationship { a: "n/rf" kind: RK_CONTROLS z: "n" }
+relationship { a: "p2" kind: RK_CONTAINS z: "p2/ant" }
+relationship { a: "a1" kind: RK_CONTROLS z: "p" }
+relationship { a: "a2" kind: RK_CONTROLS z: "p/mod" }
+relationship { a: "a2" kind: RK_CONTROLS z: "p/demod" }
+`
+
+func TestAnalyze(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(controlFragment))))
+	want := &control.Coverage{
+		Agents: []control.Agent{
+			{
+				ID:                       "a1",
+				Controls:                 []string{"p"},
+				Span:                     []string{"n", "n/if", "n/rf", "p", "p/ant", "p/mod"},
+				ControllerToAgentLatency: 20 * time.Millisecond,
+				EnactmentLatency:         8 * time.Millisecond,
+				Enactor:                  "p/mod",
+				Latency:                  28 * time.Millisecond,
+			},
+			{
+				ID:                       "a2",
+				Controls:                 []string{"p/demod", "p/mod"},
+				Span:                     []string{"p/demod", "p/mod"},
+				ControllerToAgentLatency: 50 * time.Millisecond,
+				EnactmentLatency:         8 * time.Millisecond,
+				Enactor:                  "p/mod",
+				Latency:                  58 * time.Millisecond,
+			},
+		},
+		Uncontrolled: []control.Entity{
+			{ID: "lone", Kind: "EK_ROUTE_FN", Agents: []string{}},
+			{ID: "p2", Kind: "EK_PLATFORM", Agents: []string{}},
+			{ID: "p2/ant", Kind: "EK_ANTENNA", Agents: []string{}},
+		},
+		MultiplyControlled: []control.Entity{},
+		Latency:            58 * time.Millisecond,
+		SlowestAgent:       "a2",
+	}
+	if diff := gcmp.Diff(want, control.Analyze(g)); diff != "" {
+		t.Errorf("unexpected coverage (-want +got): %s", diff)
+	}
+}
+
+func TestAnalyzeNestedAgents(t *testing.T) {
+	// Agent outer controls the node containing agent inner, but not what inner controls.
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(`
+entity { id: "n" ek_network_node{} }
+entity { id: "outer" ek_sdn_agent{} }
+entity { id: "inner" ek_sdn_agent{} }
+entity { id: "mod" ek_modulator{} }
+relationship { a: "n" kind: RK_CONTAINS z: "inner" }
+relationship { a: "outer" kind: RK_CONTROLS z: "n" }
+relationship { a: "inner" kind: RK_CONTROLS z: "mod" }
+`))))
+	c := control.Analyze(g)
+	spans := map[string][]string{}
+	for _, a := range c.Agents {
+		spans[a.ID] = a.Span
+	}
+	want := map[string][]string{"inner": {"mod"}, "outer": {"inner", "n"}}
+	if diff := gcmp.Diff(want, spans); diff != "" {
+		t.Errorf("unexpected spans (-want +got): %s", diff)
+	}
+	if len(c.Uncontrolled) != 0 || len(c.MultiplyControlled) != 0 {
+		t.Errorf("got uncontrolled %v and multiply controlled %v; want neither", c.Uncontrolled, c.MultiplyControlled)
+	}
+	if c.Latency != 0 || c.SlowestAgent != "inner" {
+		t.Errorf("got latency %v of %q; want 0s of \"inner\"", c.Latency, c.SlowestAgent)
+	}
+}
+
+func TestAnalyzeMultiplyControlled(t *testing.T) {
+	// Agent a controls node n directly and b through its route function; c spans modulator mod
+	// through its platform, but only a controls it.
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(`
+entity { id: "p" ek_platform{} }
+entity { id: "mod" ek_modulator{} }
+entity { id: "n" ek_network_node{} }
+entity { id: "rf" ek_route_fn{} }
+entity { id: "a" ek_sdn_agent{} }
+entity { id: "b" ek_sdn_agent{} }
+entity { id: "c" ek_sdn_agent{} }
+relationship { a: "p" kind: RK_CONTAINS z: "mod" }
+relationship { a: "rf" kind: RK_CONTROLS z: "n" }
+relationship { a: "a" kind: RK_CONTROLS z: "n" }
+relationship { a: "a" kind: RK_CONTROLS z: "mod" }
+relationship { a: "b" kind: RK_CONTROLS z: "rf" }
+relationship { a: "c" kind: RK_CONTROLS z: "p" }
+`))))
+	want := []control.Entity{{ID: "n", Kind: "EK_NETWORK_NODE", Agents: []string{"a", "b"}}}
+	if diff := gcmp.Diff(want, control.Analyze(g).MultiplyControlled); diff != "" {
+		t.Errorf("unexpected multiply controlled entities (-want +got): %s", diff)
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff computes the semantic difference between two versions of an
+// NMTS model, independent of how either is split across files or ordered.
+package diff
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// EntityChange describes an entity present in both versions, but with
+// different contents.
+type EntityChange struct {
+	ID       string
+	Old, New *npb.Entity
+	Fields   []FieldChange
+}
+
+// KindChanged returns whether the entity kind differs between the versions.
+func (c *EntityChange) KindChanged() bool {
+	return er.EntityKindStringFromProto(c.Old) != er.EntityKindStringFromProto(c.New)
+}
+
+// Diff is the difference between two versions of a model. Entities are
+// ordered by ID and relationships by A, kind and Z.
+type Diff struct {
+	AddedEntities        []*npb.Entity
+	RemovedEntities      []*npb.Entity
+	ModifiedEntities     []EntityChange
+	AddedRelationships   []er.Relationship
+	RemovedRelationships []er.Relationship
+
+	// Relationships present in both versions that are incident to an
+	// entity whose kind changed. A Patch must detach and reattach them,
+	// as an entity can only change kind by being removed and re-added.
+	reattached []er.Relationship
+}
+
+// Collections returns the difference from old to new.
+func Collections(old, new *er.Collection) *Diff {
+	d := &Diff{}
+
+	for _, id := range slices.Sorted(maps.Keys(old.Entities)) {
+		oldEntity := old.Entities[id]
+		newEntity, exists := new.Entities[id]
+		switch {
+		case !exists:
+			d.RemovedEntities = append(d.RemovedEntities, oldEntity)
+		case !proto.Equal(oldEntity, newEntity):
+			d.ModifiedEntities = append(d.ModifiedEntities, EntityChange{
+				ID:     id,
+				Old:    oldEntity,
+				New:    newEntity,
+				Fields: Entities(oldEntity, newEntity),
+			})
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(new.Entities)) {
+		if !old.EntityExists(id) {
+			d.AddedEntities = append(d.AddedEntities, new.Entities[id])
+		}
+	}
+
+	for _, r := range old.Relationships() {
+		if !new.RelationshipExists(r) {
+			d.RemovedRelationships = append(d.RemovedRelationships, r)
+		}
+	}
+	for _, r := range new.Relationships() {
+		if !old.RelationshipExists(r) {
+			d.AddedRelationships = append(d.AddedRelationships, r)
+		}
+	}
+
+	reattached := map[er.Relationship]struct{}{}
+	for _, change := range d.ModifiedEntities {
+		if !change.KindChanged() {
+			continue
+		}
+		for _, r := range old.IncidentRelationships(change.ID) {
+			if new.RelationshipExists(r) {
+				reattached[r] = struct{}{}
+			}
+		}
+	}
+	d.reattached = slices.SortedFunc(maps.Keys(reattached), er.CompareRelationships)
+
+	return d
+}
+
+// Empty returns whether the two versions are identical.
+func (d *Diff) Empty() bool {
+	return len(d.AddedEntities) == 0 &&
+		len(d.RemovedEntities) == 0 &&
+		len(d.ModifiedEntities) == 0 &&
+		len(d.AddedRelationships) == 0 &&
+		len(d.RemovedRelationships) == 0
+}
+
+// Patch returns a Patch that, applied to the old version, yields the new
+// one.
+func (d *Diff) Patch() *npb.Patch {
+	patch := &npb.Patch{}
+
+	removeRelationships := slices.Concat(d.RemovedRelationships, d.reattached)
+	slices.SortFunc(removeRelationships, er.CompareRelationships)
+	for _, r := range removeRelationships {
+		patch.RemoveRelationship = append(patch.RemoveRelationship, r.ToProto())
+	}
+
+	addEntities := slices.Clone(d.AddedEntities)
+	for _, e := range d.RemovedEntities {
+		patch.RemoveEntity = append(patch.RemoveEntity, e.GetId())
+	}
+	for _, change := range d.ModifiedEntities {
+		if change.KindChanged() {
+			patch.RemoveEntity = append(patch.RemoveEntity, change.ID)
+			addEntities = append(addEntities, change.New)
+		} else {
+			patch.ReplaceEntity = append(patch.ReplaceEntity, change.New)
+		}
+	}
+	slices.Sort(patch.RemoveEntity)
+	slices.SortFunc(addEntities, func(l, r *npb.Entity) int {
+		return cmp.Compare(l.GetId(), r.GetId())
+	})
+	patch.AddEntity = addEntities
+
+	addRelationships := slices.Concat(d.AddedRelationships, d.reattached)
+	slices.SortFunc(addRelationships, er.CompareRelationships)
+	for _, r := range addRelationships {
+		patch.AddRelationship = append(patch.AddRelationship, r.ToProto())
+	}
+
+	return patch
+}
+
+// WriteText writes a human-readable summary of the difference to w, one
+// line per change, prefixed with "+" for additions, "-" for removals and
+// "~" for modifications. Each modification is followed by an indented
+// line per changed field.
+func (d *Diff) WriteText(w io.Writer) error {
+	lines := []string{}
+	for _, e := range d.RemovedEntities {
+		lines = append(lines, fmt.Sprintf("- entity %q (%s)", e.GetId(), er.EntityKindStringFromProto(e)))
+	}
+	for _, e := range d.AddedEntities {
+		lines = append(lines, fmt.Sprintf("+ entity %q (%s)", e.GetId(), er.EntityKindStringFromProto(e)))
+	}
+	for _, change := range d.ModifiedEntities {
+		kind := er.EntityKindStringFromProto(change.Old)
+		if change.KindChanged() {
+			kind += " -> " + er.EntityKindStringFromProto(change.New)
+		}
+		lines = append(lines, fmt.Sprintf("~ entity %q (%s)", change.ID, kind))
+		for _, f := range change.Fields {
+			lines = append(lines, fmt.Sprintf("    %s: %s -> %s", f.Path, orUnset(f.Old), orUnset(f.New)))
+		}
+	}
+	for _, r := range d.RemovedRelationships {
+		lines = append(lines, "- relationship "+r.String())
+	}
+	for _, r := range d.AddedRelationships {
+		lines = append(lines, "+ relationship "+r.String())
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func orUnset(value string) string {
+	if value == "" {
+		return "<unset>"
+	}
+	return value
+}
+
+type jsonRelationship struct {
+	A    string `json:"a"`
+	Kind string `json:"kind"`
+	Z    string `json:"z"`
+}
+
+type jsonEntityChange struct {
+	ID     string            `json:"id"`
+	Old    json.RawMessage   `json:"old"`
+	New    json.RawMessage   `json:"new"`
+	Fields []jsonFieldChange `json:"fields"`
+}
+
+type jsonFieldChange struct {
+	Path string `json:"path"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+type jsonDiff struct {
+	AddedEntities        []json.RawMessage  `json:"addedEntities"`
+	RemovedEntities      []json.RawMessage  `json:"removedEntities"`
+	ModifiedEntities     []jsonEntityChange `json:"modifiedEntities"`
+	AddedRelationships   []jsonRelationship `json:"addedRelationships"`
+	RemovedRelationships []jsonRelationship `json:"removedRelationships"`
+}
+
+// MarshalJSON encodes the difference as a JSON object. Entities are encoded
+// with the protobuf JSON mapping, and field values as in FieldChange.
+func (d *Diff) MarshalJSON() ([]byte, error) {
+	var err error
+	entityJSON := func(e *npb.Entity) json.RawMessage {
+		data, marshalErr := protojson.Marshal(e)
+		if marshalErr != nil {
+			err = marshalErr
+		}
+		return data
+	}
+	relationshipsJSON := func(rels []er.Relationship) []jsonRelationship {
+		out := make([]jsonRelationship, 0, len(rels))
+		for _, r := range rels {
+			out = append(out, jsonRelationship{A: r.A, Kind: r.Kind.String(), Z: r.Z})
+		}
+		return out
+	}
+
+	out := jsonDiff{
+		AddedEntities:        make([]json.RawMessage, 0, len(d.AddedEntities)),
+		RemovedEntities:      make([]json.RawMessage, 0, len(d.RemovedEntities)),
+		ModifiedEntities:     make([]jsonEntityChange, 0, len(d.ModifiedEntities)),
+		AddedRelationships:   relationshipsJSON(d.AddedRelationships),
+		RemovedRelationships: relationshipsJSON(d.RemovedRelationships),
+	}
+	for _, e := range d.AddedEntities {
+		out.AddedEntities = append(out.AddedEntities, entityJSON(e))
+	}
+	for _, e := range d.RemovedEntities {
+		out.RemovedEntities = append(out.RemovedEntities, entityJSON(e))
+	}
+	for _, change := range d.ModifiedEntities {
+		fields := make([]jsonFieldChange, 0, len(change.Fields))
+		for _, f := range change.Fields {
+			fields = append(fields, jsonFieldChange(f))
+		}
+		out.ModifiedEntities = append(out.ModifiedEntities, jsonEntityChange{
+			ID:     change.ID,
+			Old:    entityJSON(change.Old),
+			New:    entityJSON(change.New),
+			Fields: fields,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(out)
+}
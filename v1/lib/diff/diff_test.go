@@ -0,0 +1,184 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"outernetcouncil.org/nmts/v1/lib/diff"
+	testutil "outernetcouncil.org/nmts/v1/lib/utilities/testing"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+var entityFieldsTestCases = []struct {
+	desc     string
+	old, new string
+	want     []diff.FieldChange
+}{
+	{
+		desc: "identical",
+		old:  `id: "p" ek_platform { name: "sat" }`,
+		new:  `id: "p" ek_platform { name: "sat" }`,
+		want: []diff.FieldChange{},
+	},
+	{
+		desc: "scalars changed and cleared",
+		old:  `id: "p" ek_platform { name: "sat" category_tag: "leo" }`,
+		new:  `id: "p" ek_platform { name: "sat-1" }`,
+		want: []diff.FieldChange{
+			{Path: "ek_platform.name", Old: `"sat"`, New: `"sat-1"`},
+			{Path: "ek_platform.category_tag", Old: `"leo"`},
+		},
+	},
+	{
+		desc: "labels",
+		old:  `id: "p" ek_platform {} labels { key: "env" value: "dev" } labels { key: "gone" value: "x" }`,
+		new:  `id: "p" ek_platform {} labels { key: "env" value: "prod" } labels { key: "new" value: "y" }`,
+		want: []diff.FieldChange{
+			{Path: `labels["env"]`, Old: `"dev"`, New: `"prod"`},
+			{Path: `labels["gone"]`, Old: `"x"`},
+			{Path: `labels["new"]`, New: `"y"`},
+		},
+	},
+	{
+		desc: "kind changed",
+		old:  `id: "x" ek_port { name: "eth0" }`,
+		new:  `id: "x" ek_interface {}`,
+		want: []diff.FieldChange{
+			{Path: "ek_port.name", Old: `"eth0"`},
+			{Path: "ek_interface", New: "{}"},
+		},
+	},
+}
+
+func TestEntities(t *testing.T) {
+	for _, tc := range entityFieldsTestCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := diff.Entities(lo.Must(testutil.EntityFrom(tc.old)), lo.Must(testutil.EntityFrom(tc.new)))
+			if d := gcmp.Diff(tc.want, got); d != "" {
+				t.Errorf("unexpected field changes (-want +got):\n%s", d)
+			}
+		})
+	}
+}
+
+const oldVersion = `
+entity { id: "sat" ek_platform { name: "sat" } }
+entity { id: "node" ek_network_node {} }
+entity { id: "port" ek_port { name: "eth0" } }
+entity { id: "gone" ek_network_node {} }
+relationship { a: "sat" kind: RK_CONTAINS z: "node" }
+relationship { a: "node" kind: RK_CONTAINS z: "port" }
+relationship { a: "sat" kind: RK_CONTAINS z: "gone" }
+`
+
+// newVersion renames the platform, turns "port" into an interface, drops
+// "gone" and adds "ground", in a different order to oldVersion.
+const newVersion = `
+relationship { a: "sat" kind: RK_CONTAINS z: "ground" }
+relationship { a: "node" kind: RK_CONTAINS z: "port" }
+relationship { a: "sat" kind: RK_CONTAINS z: "node" }
+entity { id: "ground" ek_platform { name: "ground" } }
+entity { id: "port" ek_interface {} }
+entity { id: "node" ek_network_node {} }
+entity { id: "sat" ek_platform { name: "sat-1" } }
+`
+
+func TestCollections(t *testing.T) {
+	oldColl := lo.Must(testutil.CollectionFromFragments(lo.Must(testutil.FragmentFrom(oldVersion))))
+	newColl := lo.Must(testutil.CollectionFromFragments(lo.Must(testutil.FragmentFrom(newVersion))))
+
+	sameColl := lo.Must(testutil.CollectionFromFragments(lo.Must(testutil.FragmentFrom(oldVersion))))
+	if d := diff.Collections(oldColl, sameColl); !d.Empty() {
+		t.Errorf("diff of identical versions is not empty: %+v", d)
+	}
+
+	d := diff.Collections(oldColl, newColl)
+	if d.Empty() {
+		t.Fatalf("diff of different versions is empty")
+	}
+
+	var text bytes.Buffer
+	if err := d.WriteText(&text); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	wantText := `- entity "gone" (EK_NETWORK_NODE)
++ entity "ground" (EK_PLATFORM)
+~ entity "port" (EK_PORT -> EK_INTERFACE)
+    ek_port.name: "eth0" -> <unset>
+    ek_interface: <unset> -> {}
+~ entity "sat" (EK_PLATFORM)
+    ek_platform.name: "sat" -> "sat-1"
+- relationship sat->RK_CONTAINS->gone
++ relationship sat->RK_CONTAINS->ground
+`
+	if got := text.String(); got != wantText {
+		t.Errorf("unexpected text diff (-want +got):\n%s", gcmp.Diff(wantText, got))
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	var decoded struct {
+		ModifiedEntities []struct {
+			ID string `json:"id"`
+		} `json:"modifiedEntities"`
+		RemovedRelationships []struct {
+			A, Kind, Z string
+		} `json:"removedRelationships"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unable to decode JSON diff %s: %v", data, err)
+	}
+	if len(decoded.ModifiedEntities) != 2 || decoded.ModifiedEntities[0].ID != "port" {
+		t.Errorf("unexpected modified entities in JSON diff: %s", data)
+	}
+	if len(decoded.RemovedRelationships) != 1 || decoded.RemovedRelationships[0].Kind != "RK_CONTAINS" {
+		t.Errorf("unexpected removed relationships in JSON diff: %s", data)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	oldColl := lo.Must(testutil.CollectionFromFragments(lo.Must(testutil.FragmentFrom(oldVersion))))
+	newColl := lo.Must(testutil.CollectionFromFragments(lo.Must(testutil.FragmentFrom(newVersion))))
+	d := diff.Collections(oldColl, newColl)
+
+	want := &npb.Patch{}
+	if err := prototext.Unmarshal([]byte(`
+remove_relationship { a: "node" kind: RK_CONTAINS z: "port" }
+remove_relationship { a: "sat" kind: RK_CONTAINS z: "gone" }
+remove_entity: "gone"
+remove_entity: "port"
+replace_entity { id: "sat" ek_platform { name: "sat-1" } }
+add_entity { id: "ground" ek_platform { name: "ground" } }
+add_entity { id: "port" ek_interface {} }
+add_relationship { a: "node" kind: RK_CONTAINS z: "port" }
+add_relationship { a: "sat" kind: RK_CONTAINS z: "ground" }
+`), want); err != nil {
+		t.Fatalf("unable to unmarshal expected patch: %v", err)
+	}
+
+	if got := d.Patch(); !gcmp.Equal(want, got, protocmp.Transform()) {
+		t.Errorf("unexpected patch (-want +got):\n%s", gcmp.Diff(want, got, protocmp.Transform()))
+	}
+}
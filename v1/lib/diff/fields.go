@@ -0,0 +1,165 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// FieldChange is a single leaf field that differs between two versions of
+// an entity.
+type FieldChange struct {
+	// Path identifies the field, e.g. `ek_platform.name`,
+	// `labels["env"]` or `ek_platform.motion.entry[2].gcrf`.
+	Path string
+
+	// Old and New are the field's values in protobuf text format, or
+	// empty where the field is unset. A set message with no fields set is
+	// "{}".
+	Old, New string
+}
+
+// Entities returns the leaf fields that differ between two versions of an
+// entity, ordered by field number and then by list index or map key.
+func Entities(old, new *npb.Entity) []FieldChange {
+	changes := []FieldChange{}
+	diffMessages("", old.ProtoReflect(), new.ProtoReflect(), &changes)
+	return changes
+}
+
+func diffMessages(path string, old, new protoreflect.Message, changes *[]FieldChange) {
+	fields := old.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !old.Has(fd) && !new.Has(fd) {
+			continue
+		}
+		fieldPath := string(fd.Name())
+		if path != "" {
+			fieldPath = path + "." + fieldPath
+		}
+
+		switch {
+		case fd.IsList():
+			diffLists(fieldPath, fd, old.Get(fd).List(), new.Get(fd).List(), changes)
+		case fd.IsMap():
+			diffMaps(fieldPath, fd, old.Get(fd).Map(), new.Get(fd).Map(), changes)
+		default:
+			diffValues(fieldPath, fd, old.Has(fd), old.Get(fd), new.Has(fd), new.Get(fd), changes)
+		}
+	}
+}
+
+func diffLists(path string, fd protoreflect.FieldDescriptor, old, new protoreflect.List, changes *[]FieldChange) {
+	for i := 0; i < max(old.Len(), new.Len()); i++ {
+		var oldValue, newValue protoreflect.Value
+		if i < old.Len() {
+			oldValue = old.Get(i)
+		}
+		if i < new.Len() {
+			newValue = new.Get(i)
+		}
+		diffValues(fmt.Sprintf("%s[%d]", path, i), fd, i < old.Len(), oldValue, i < new.Len(), newValue, changes)
+	}
+}
+
+func diffMaps(path string, fd protoreflect.FieldDescriptor, old, new protoreflect.Map, changes *[]FieldChange) {
+	keys := []protoreflect.MapKey{}
+	collect := func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		if !slices.ContainsFunc(keys, func(other protoreflect.MapKey) bool { return other.Value().Equal(k.Value()) }) {
+			keys = append(keys, k)
+		}
+		return true
+	}
+	old.Range(collect)
+	new.Range(collect)
+
+	keyFD := fd.MapKey()
+	slices.SortFunc(keys, func(l, r protoreflect.MapKey) int {
+		return cmp.Compare(formatScalar(keyFD, l.Value()), formatScalar(keyFD, r.Value()))
+	})
+
+	for _, k := range keys {
+		keyPath := fmt.Sprintf("%s[%s]", path, formatScalar(keyFD, k.Value()))
+		diffValues(keyPath, fd.MapValue(), old.Has(k), old.Get(k), new.Has(k), new.Get(k), changes)
+	}
+}
+
+// diffValues compares a singular field, list element or map value, where
+// fd describes the value's type.
+func diffValues(path string, fd protoreflect.FieldDescriptor, oldHas bool, old protoreflect.Value, newHas bool, new protoreflect.Value, changes *[]FieldChange) {
+	if fd.Message() == nil {
+		if oldHas == newHas && old.Equal(new) {
+			return
+		}
+		change := FieldChange{Path: path}
+		if oldHas {
+			change.Old = formatScalar(fd, old)
+		}
+		if newHas {
+			change.New = formatScalar(fd, new)
+		}
+		*changes = append(*changes, change)
+		return
+	}
+
+	// A missing message is compared as an empty one of the same type, so
+	// that every field set on the other side is reported individually.
+	oldMsg, newMsg := messageOrZero(oldHas, old, newHas, new)
+	before := len(*changes)
+	diffMessages(path, oldMsg, newMsg, changes)
+	if len(*changes) == before && oldHas != newHas {
+		change := FieldChange{Path: path}
+		if oldHas {
+			change.Old = "{}"
+		} else {
+			change.New = "{}"
+		}
+		*changes = append(*changes, change)
+	}
+}
+
+func messageOrZero(oldHas bool, old protoreflect.Value, newHas bool, new protoreflect.Value) (protoreflect.Message, protoreflect.Message) {
+	switch {
+	case !oldHas:
+		return new.Message().Type().Zero(), new.Message()
+	case !newHas:
+		return old.Message(), old.Message().Type().Zero()
+	default:
+		return old.Message(), new.Message()
+	}
+}
+
+func formatScalar(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return strconv.Quote(v.String())
+	case protoreflect.BytesKind:
+		return strconv.Quote(string(v.Bytes()))
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
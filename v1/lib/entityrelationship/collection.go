@@ -17,6 +17,8 @@ package entityrelationship
 import (
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 
 	npb "outernetcouncil.org/nmts/v1/proto"
 )
@@ -25,13 +27,26 @@ type Collection struct {
 	Entities map[string]*npb.Entity
 	OutEdges map[string]*RelationshipSet
 	InEdges  map[string]*RelationshipSet
+
+	// Sources, if set, is consulted for the location of each Entity and
+	// Relationship as it's inserted, for error messages and EntitySource.
+	Sources *SourceMap
+
+	// Maps each relationship inserted with a known location to it.
+	relationshipSources map[Relationship]SourceLocation
+
+	// validator, if set, is re-run by the mutation methods (RemoveEntity,
+	// ReplaceEntity and RemoveRelationship). Collections built by a
+	// CollectionBuilder inherit the builder's Validator.
+	validator Validator
 }
 
 func NewCollection() *Collection {
 	return &Collection{
-		Entities: make(map[string]*npb.Entity),
-		OutEdges: make(map[string]*RelationshipSet),
-		InEdges:  make(map[string]*RelationshipSet),
+		Entities:            make(map[string]*npb.Entity),
+		OutEdges:            make(map[string]*RelationshipSet),
+		InEdges:             make(map[string]*RelationshipSet),
+		relationshipSources: make(map[Relationship]SourceLocation),
 	}
 }
 
@@ -52,11 +67,40 @@ func (erColl *Collection) EntityExists(key string) bool {
 	return exists
 }
 
+func (erColl *Collection) RelationshipExists(r Relationship) bool {
+	rs, exists := erColl.OutEdges[r.A]
+	if !exists {
+		return false
+	}
+	_, exists = rs.Relations[r]
+	return exists
+}
+
+// EntitySource returns where the entity with the given ID was defined, if
+// known.
+func (erColl *Collection) EntitySource(key string) (SourceLocation, bool) {
+	return erColl.Sources.Entity(erColl.Entities[key])
+}
+
+// RelationshipSource returns where the given relationship was defined, if
+// known.
+func (erColl *Collection) RelationshipSource(r Relationship) (SourceLocation, bool) {
+	loc, ok := erColl.relationshipSources[r]
+	return loc, ok
+}
+
 func (erColl *Collection) InsertEntity(entity *npb.Entity) error {
 	if entity != nil {
 		key := entity.Id
 		if erColl.EntityExists(key) {
-			return fmt.Errorf("entity already exists: '%v'", key)
+			loc, _ := erColl.Sources.Entity(entity)
+			previous, _ := erColl.EntitySource(key)
+			return &DuplicateEntityError{
+				ID:       key,
+				Kind:     EntityKindStringFromProto(entity),
+				Location: loc,
+				Previous: previous,
+			}
 		}
 		erColl.Entities[key] = entity
 	}
@@ -65,17 +109,27 @@ func (erColl *Collection) InsertEntity(entity *npb.Entity) error {
 }
 
 func (erColl *Collection) CreateRelationshipProto(relationship *npb.Relationship) error {
-	return erColl.CreateRelationship(RelationshipFromProto(relationship))
+	loc, _ := erColl.Sources.Relationship(relationship)
+	return erColl.createRelationship(RelationshipFromProto(relationship), loc)
 }
 
 func (erColl *Collection) CreateRelationship(r Relationship) error {
+	return erColl.createRelationship(r, SourceLocation{})
+}
+
+func (erColl *Collection) createRelationship(r Relationship, loc SourceLocation) error {
 	errs := []error{}
 
 	if !erColl.EntityExists(r.A) {
-		errs = append(errs, fmt.Errorf("relationship references non-existent entity: '%v'", r.A))
+		errs = append(errs, &DanglingEndpointError{Relationship: r, Endpoint: EndpointA, Location: loc})
 	}
 	if !erColl.EntityExists(r.Z) {
-		errs = append(errs, fmt.Errorf("relationship references non-existent entity: '%v'", r.Z))
+		errs = append(errs, &DanglingEndpointError{Relationship: r, Endpoint: EndpointZ, Location: loc})
+	}
+
+	if len(errs) == 0 && erColl.RelationshipExists(r) {
+		previous, _ := erColl.RelationshipSource(r)
+		return fmt.Errorf("relationship already exists in set: '%v'%s", r.String(), describeDuplicate(loc, previous))
 	}
 
 	addRelationship := func(k string, m map[string]*RelationshipSet) error {
@@ -92,7 +146,221 @@ func (erColl *Collection) CreateRelationship(r Relationship) error {
 			addRelationship(r.A, erColl.OutEdges),
 			addRelationship(r.Z, erColl.InEdges),
 		)
+		if !loc.IsZero() {
+			if erColl.relationshipSources == nil {
+				erColl.relationshipSources = make(map[Relationship]SourceLocation)
+			}
+			erColl.relationshipSources[r] = loc
+		}
 	}
 
 	return errors.Join(errs...)
 }
+
+// describeLocation returns a parenthetical naming loc for appending to an
+// error message, or nothing if loc is unknown.
+func describeLocation(loc SourceLocation) string {
+	if loc.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf(" (at %v)", loc)
+}
+
+// describeDuplicate returns a parenthetical naming both the location of a
+// duplicate definition and that of the previous one, omitting whichever is
+// unknown.
+func describeDuplicate(loc, previous SourceLocation) string {
+	if previous.IsZero() {
+		return describeLocation(loc)
+	}
+	return fmt.Sprintf(" (at %v, previously defined at %v)", loc, previous)
+}
+
+// ToFragment returns a Fragment holding every entity in the collection,
+// ordered by ID, and every relationship, ordered by A, kind and Z. The
+// entities are shared with the collection, not copied.
+func (erColl *Collection) ToFragment() *npb.Fragment {
+	fragment := &npb.Fragment{
+		Entity:       make([]*npb.Entity, 0, len(erColl.Entities)),
+		Relationship: make([]*npb.Relationship, 0, erColl.NumRelationships()),
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(erColl.Entities)) {
+		fragment.Entity = append(fragment.Entity, erColl.Entities[key])
+	}
+	for _, r := range erColl.Relationships() {
+		fragment.Relationship = append(fragment.Relationship, r.ToProto())
+	}
+	return fragment
+}
+
+// Relationships returns every relationship in the collection, ordered by A,
+// kind and Z.
+func (erColl *Collection) Relationships() []Relationship {
+	rels := make([]Relationship, 0, erColl.NumRelationships())
+	for _, rs := range erColl.OutEdges {
+		for r := range rs.Relations {
+			rels = append(rels, r)
+		}
+	}
+	slices.SortFunc(rels, CompareRelationships)
+	return rels
+}
+
+// IncidentRelationships returns every relationship that has the entity with
+// the given ID as its A or Z, ordered by A, kind and Z.
+func (erColl *Collection) IncidentRelationships(key string) []Relationship {
+	seen := map[Relationship]struct{}{}
+	for _, rs := range []*RelationshipSet{erColl.OutEdges[key], erColl.InEdges[key]} {
+		if rs == nil {
+			continue
+		}
+		for r := range rs.Relations {
+			seen[r] = struct{}{}
+		}
+	}
+
+	rels := make([]Relationship, 0, len(seen))
+	for r := range seen {
+		rels = append(rels, r)
+	}
+	slices.SortFunc(rels, CompareRelationships)
+	return rels
+}
+
+// RemoveEntity removes the entity with the given ID. If cascade is false,
+// removal fails while any relationship still references the entity;
+// otherwise those relationships are removed as well. If the configured
+// Validator rejects the resulting collection, it is left unchanged.
+func (erColl *Collection) RemoveEntity(key string, cascade bool) error {
+	entity, exists := erColl.Entities[key]
+	if !exists {
+		return fmt.Errorf("entity does not exist: '%v'", key)
+	}
+
+	incident := erColl.IncidentRelationships(key)
+	if len(incident) > 0 && !cascade {
+		return fmt.Errorf("entity '%v' is still referenced by %d relationship(s), e.g. '%v'", key, len(incident), incident[0].String())
+	}
+
+	sources := map[Relationship]SourceLocation{}
+	for _, r := range incident {
+		if loc, ok := erColl.relationshipSources[r]; ok {
+			sources[r] = loc
+		}
+		erColl.unindexRelationship(r)
+	}
+	delete(erColl.Entities, key)
+
+	if err := erColl.validateCollection(); err != nil {
+		erColl.Entities[key] = entity
+		for _, r := range incident {
+			erColl.indexRelationship(r, sources[r])
+		}
+		return fmt.Errorf("removing entity '%v': %w", key, err)
+	}
+	return nil
+}
+
+// ReplaceEntity replaces the existing entity that has the same ID as the
+// given one. The entity kind may not change. The configured Validator is
+// re-run against the new entity, every relationship incident to it, and the
+// resulting collection; if any check fails, the collection is left
+// unchanged.
+func (erColl *Collection) ReplaceEntity(entity *npb.Entity) error {
+	if entity == nil {
+		return fmt.Errorf("entity MUST NOT be nil")
+	}
+	key := entity.GetId()
+	old, exists := erColl.Entities[key]
+	if !exists {
+		return fmt.Errorf("entity does not exist: '%v'", key)
+	}
+	if oldKind, newKind := EntityKindStringFromProto(old), EntityKindStringFromProto(entity); oldKind != newKind {
+		return fmt.Errorf("entity '%v' cannot change kind from %s to %s", key, oldKind, newKind)
+	}
+
+	if erColl.validator != nil {
+		if err := erColl.validator.ValidateEntity(erColl, entity); err != nil {
+			return err
+		}
+	}
+
+	erColl.Entities[key] = entity
+
+	errs := []error{}
+	if erColl.validator != nil {
+		for _, r := range erColl.IncidentRelationships(key) {
+			errs = append(errs, erColl.validator.ValidateRelationship(erColl, r))
+		}
+	}
+	errs = append(errs, erColl.validateCollection())
+	if err := errors.Join(errs...); err != nil {
+		erColl.Entities[key] = old
+		return fmt.Errorf("replacing entity '%v': %w", key, err)
+	}
+	return nil
+}
+
+// RemoveRelationship removes the given relationship. If the configured
+// Validator rejects the resulting collection, it is left unchanged.
+func (erColl *Collection) RemoveRelationship(r Relationship) error {
+	if !erColl.RelationshipExists(r) {
+		return fmt.Errorf("relationship does not exist: '%v'", r.String())
+	}
+
+	loc, _ := erColl.RelationshipSource(r)
+	erColl.unindexRelationship(r)
+
+	if err := erColl.validateCollection(); err != nil {
+		erColl.indexRelationship(r, loc)
+		return fmt.Errorf("removing relationship '%v': %w", r.String(), err)
+	}
+	return nil
+}
+
+func (erColl *Collection) validateCollection() error {
+	if erColl.validator == nil {
+		return nil
+	}
+	return erColl.validator.ValidateCollection(erColl)
+}
+
+// indexRelationship adds r, which must not already be present, to both
+// edge indexes.
+func (erColl *Collection) indexRelationship(r Relationship, loc SourceLocation) {
+	addToSet(erColl.OutEdges, r.A, r)
+	addToSet(erColl.InEdges, r.Z, r)
+	if !loc.IsZero() {
+		if erColl.relationshipSources == nil {
+			erColl.relationshipSources = make(map[Relationship]SourceLocation)
+		}
+		erColl.relationshipSources[r] = loc
+	}
+}
+
+// unindexRelationship removes r from both edge indexes, dropping any
+// RelationshipSet left empty.
+func (erColl *Collection) unindexRelationship(r Relationship) {
+	removeFromSet(erColl.OutEdges, r.A, r)
+	removeFromSet(erColl.InEdges, r.Z, r)
+	delete(erColl.relationshipSources, r)
+}
+
+func addToSet(m map[string]*RelationshipSet, k string, r Relationship) {
+	rs, exists := m[k]
+	if !exists {
+		rs = NewRelationshipSet()
+		m[k] = rs
+	}
+	rs.Relations[r] = struct{}{}
+}
+
+func removeFromSet(m map[string]*RelationshipSet, k string, r Relationship) {
+	if rs, exists := m[k]; exists {
+		delete(rs.Relations, r)
+		if len(rs.Relations) == 0 {
+			delete(m, k)
+		}
+	}
+}
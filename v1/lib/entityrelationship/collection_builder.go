@@ -16,6 +16,7 @@ package entityrelationship
 
 import (
 	"errors"
+	"fmt"
 
 	npb "outernetcouncil.org/nmts/v1/proto"
 )
@@ -34,8 +35,9 @@ type Validator interface {
 }
 
 type CollectionBuilder struct {
-	erColl    *Collection
-	validator Validator
+	erColl      *Collection
+	validator   Validator
+	mergePolicy MergePolicy
 }
 
 func NewNonValidatingCollectionBuilder() *CollectionBuilder {
@@ -43,12 +45,28 @@ func NewNonValidatingCollectionBuilder() *CollectionBuilder {
 }
 
 func NewCollectionBuilder(v Validator) *CollectionBuilder {
+	erColl := NewCollection()
+	erColl.validator = v
 	return &CollectionBuilder{
-		erColl:    NewCollection(),
+		erColl:    erColl,
 		validator: v,
 	}
 }
 
+// SetSourceMap sets where the builder looks up the locations of the
+// entities and relationships it's given, typically as recorded by a
+// FragmentReader. Locations are included in errors and retained by the
+// built Collection.
+func (builder *CollectionBuilder) SetSourceMap(sources *SourceMap) {
+	builder.erColl.Sources = sources
+}
+
+// SetMergePolicy sets how entities whose ID was already inserted are
+// handled. The default, MergeError, rejects them.
+func (builder *CollectionBuilder) SetMergePolicy(p MergePolicy) {
+	builder.mergePolicy = p
+}
+
 func (builder *CollectionBuilder) Build() (*Collection, error) {
 	if builder.validator != nil {
 		if err := builder.validator.ValidateCollection(builder.erColl); err != nil {
@@ -69,13 +87,7 @@ func (builder *CollectionBuilder) InsertFragments(fragments ...*npb.Fragment) er
 	for _, fragment := range fragments {
 		if fragment.Entity != nil {
 			for _, entity := range fragment.Entity {
-				if builder.validator != nil {
-					if err := builder.validator.ValidateEntity(builder.erColl, entity); err != nil {
-						errs = append(errs, err)
-						continue
-					}
-				}
-				errs = append(errs, builder.erColl.InsertEntity(entity))
+				errs = append(errs, builder.insertEntity(entity))
 			}
 		}
 	}
@@ -86,14 +98,54 @@ func (builder *CollectionBuilder) InsertFragments(fragments ...*npb.Fragment) er
 				rel := RelationshipFromProto(relationship)
 				if builder.validator != nil {
 					if err := builder.validator.ValidateRelationship(builder.erColl, rel); err != nil {
-						errs = append(errs, err)
+						loc, _ := builder.erColl.Sources.Relationship(relationship)
+						errs = append(errs, withLocation(loc, err))
 						continue
 					}
 				}
-				errs = append(errs, builder.erColl.CreateRelationship(rel))
+				errs = append(errs, builder.erColl.CreateRelationshipProto(relationship))
 			}
 		}
 	}
 
 	return errors.Join(errs...)
 }
+
+func (builder *CollectionBuilder) insertEntity(entity *npb.Entity) error {
+	loc, _ := builder.erColl.Sources.Entity(entity)
+
+	existing, exists := builder.erColl.Entities[entity.GetId()]
+	if !exists || builder.mergePolicy == MergeError {
+		if builder.validator != nil {
+			if err := builder.validator.ValidateEntity(builder.erColl, entity); err != nil {
+				return withLocation(loc, err)
+			}
+		}
+		return builder.erColl.InsertEntity(entity)
+	}
+
+	previous, _ := builder.erColl.EntitySource(entity.GetId())
+	merged, err := builder.mergePolicy.Merge(existing, entity)
+	if err != nil {
+		return fmt.Errorf("merging entity '%v'%s: %w", entity.GetId(), describeDuplicate(loc, previous), err)
+	}
+	if builder.validator != nil {
+		if err := builder.validator.ValidateEntity(builder.erColl, merged); err != nil {
+			return withLocation(loc, err)
+		}
+	}
+	if merged != entity && merged != existing {
+		// A deep merge is attributed to the entity's first definition.
+		builder.erColl.Sources.SetEntity(merged, previous)
+	}
+	builder.erColl.Entities[entity.GetId()] = merged
+	return nil
+}
+
+// withLocation prefixes err with loc, if known.
+func withLocation(loc SourceLocation, err error) error {
+	if loc.IsZero() {
+		return err
+	}
+	return fmt.Errorf("%v: %w", loc, err)
+}
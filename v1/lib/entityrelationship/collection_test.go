@@ -0,0 +1,238 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entityrelationship_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+const mutationFragment = `
+entity { id: "platform" ek_platform{} }
+entity { id: "node" ek_network_node{} }
+entity { id: "port" ek_port{} }
+entity { id: "loop" ek_port{} }
+relationship { a: "platform" kind: RK_CONTAINS z: "node" }
+relationship { a: "node" kind: RK_CONTAINS z: "port" }
+relationship { a: "loop" kind: RK_TRAVERSES z: "loop" }
+`
+
+var errTooFewRelationships = errors.New("too few relationships")
+var errBadLabel = errors.New("bad label")
+
+// minRelationshipsValidator rejects entities labelled "bad" and collections
+// with fewer than min relationships.
+type minRelationshipsValidator struct {
+	min int
+}
+
+func (minRelationshipsValidator) ValidateEntity(_ *entityrelationship.Collection, e *npb.Entity) error {
+	if _, bad := e.GetLabels()["bad"]; bad {
+		return errBadLabel
+	}
+	return nil
+}
+
+func (minRelationshipsValidator) ValidateRelationship(*entityrelationship.Collection, entityrelationship.Relationship) error {
+	return nil
+}
+
+func (v minRelationshipsValidator) ValidateCollection(erColl *entityrelationship.Collection) error {
+	if erColl.NumRelationships() < v.min {
+		return errTooFewRelationships
+	}
+	return nil
+}
+
+func buildMutationCollection(t *testing.T, v entityrelationship.Validator) *entityrelationship.Collection {
+	t.Helper()
+	builder := entityrelationship.NewCollectionBuilder(v)
+	if err := builder.InsertFragments(mustUnmarshalFragment(t, mutationFragment)); err != nil {
+		t.Fatalf("InsertFragments: %v", err)
+	}
+	erColl, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	return erColl
+}
+
+// checkIndexes verifies that OutEdges and InEdges hold exactly the same
+// relationships, each under its own endpoints, with no empty sets.
+func checkIndexes(t *testing.T, erColl *entityrelationship.Collection) {
+	t.Helper()
+	collect := func(m map[string]*entityrelationship.RelationshipSet, key func(entityrelationship.Relationship) string) []entityrelationship.Relationship {
+		rels := []entityrelationship.Relationship{}
+		for k, rs := range m {
+			if len(rs.Relations) == 0 {
+				t.Errorf("empty relationship set left for %q", k)
+			}
+			for r := range rs.Relations {
+				if key(r) != k {
+					t.Errorf("relationship %v indexed under %q", r.String(), k)
+				}
+				if !erColl.EntityExists(r.A) || !erColl.EntityExists(r.Z) {
+					t.Errorf("relationship %v references a removed entity", r.String())
+				}
+				rels = append(rels, r)
+			}
+		}
+		slices.SortFunc(rels, entityrelationship.CompareRelationships)
+		return rels
+	}
+	out := collect(erColl.OutEdges, func(r entityrelationship.Relationship) string { return r.A })
+	in := collect(erColl.InEdges, func(r entityrelationship.Relationship) string { return r.Z })
+	if !slices.Equal(out, in) {
+		t.Errorf("OutEdges %v and InEdges %v differ", out, in)
+	}
+}
+
+func TestRemoveEntity(t *testing.T) {
+	erColl := buildMutationCollection(t, nil)
+
+	if err := erColl.RemoveEntity("node", false); err == nil {
+		t.Errorf("RemoveEntity without cascade succeeded for a referenced entity")
+	}
+	if !erColl.EntityExists("node") || erColl.NumRelationships() != 3 {
+		t.Errorf("failed RemoveEntity modified the collection")
+	}
+
+	if err := erColl.RemoveEntity("node", true); err != nil {
+		t.Fatalf("RemoveEntity with cascade: %v", err)
+	}
+	if erColl.EntityExists("node") {
+		t.Errorf("entity still exists after removal")
+	}
+	if got := erColl.NumRelationships(); got != 1 {
+		t.Errorf("wanted 1 relationship after cascade, got %d", got)
+	}
+	checkIndexes(t, erColl)
+
+	if err := erColl.RemoveEntity("loop", true); err != nil {
+		t.Fatalf("RemoveEntity of self-referencing entity: %v", err)
+	}
+	if got := erColl.NumRelationships(); got != 0 {
+		t.Errorf("wanted no relationships after removing self-loop, got %d", got)
+	}
+	checkIndexes(t, erColl)
+
+	if err := erColl.RemoveEntity("port", false); err != nil {
+		t.Errorf("RemoveEntity of unreferenced entity: %v", err)
+	}
+	if err := erColl.RemoveEntity("port", false); err == nil {
+		t.Errorf("RemoveEntity of missing entity succeeded")
+	}
+}
+
+func TestRemoveEntityRollsBackOnValidationFailure(t *testing.T) {
+	erColl := buildMutationCollection(t, minRelationshipsValidator{min: 3})
+
+	err := erColl.RemoveEntity("node", true)
+	if !errors.Is(err, errTooFewRelationships) {
+		t.Fatalf("wanted %v, got %v", errTooFewRelationships, err)
+	}
+	if !erColl.EntityExists("node") {
+		t.Errorf("entity removed despite validation failure")
+	}
+	if got := erColl.IncidentRelationships("node"); len(got) != 2 {
+		t.Errorf("wanted 2 relationships restored, got %v", got)
+	}
+	checkIndexes(t, erColl)
+}
+
+func TestReplaceEntity(t *testing.T) {
+	erColl := buildMutationCollection(t, minRelationshipsValidator{})
+
+	replacement := mustUnmarshalEntity(t, `id: "port" labels { key: "role" value: "uplink" } ek_port{}`)
+	if err := erColl.ReplaceEntity(replacement); err != nil {
+		t.Fatalf("ReplaceEntity: %v", err)
+	}
+	if erColl.Entities["port"] != replacement {
+		t.Errorf("entity was not replaced")
+	}
+	if got := erColl.IncidentRelationships("port"); len(got) != 1 {
+		t.Errorf("relationships changed by ReplaceEntity: %v", got)
+	}
+
+	err := erColl.ReplaceEntity(mustUnmarshalEntity(t, `id: "port" ek_interface{}`))
+	if err == nil || !strings.Contains(err.Error(), "cannot change kind") {
+		t.Errorf("wanted kind change error, got %v", err)
+	}
+
+	err = erColl.ReplaceEntity(mustUnmarshalEntity(t, `id: "port" labels { key: "bad" value: "" } ek_port{}`))
+	if !errors.Is(err, errBadLabel) {
+		t.Errorf("wanted %v, got %v", errBadLabel, err)
+	}
+	if erColl.Entities["port"] != replacement {
+		t.Errorf("entity replaced despite validation failure")
+	}
+
+	if err := erColl.ReplaceEntity(mustUnmarshalEntity(t, `id: "missing" ek_port{}`)); err == nil {
+		t.Errorf("ReplaceEntity of missing entity succeeded")
+	}
+}
+
+func TestRemoveRelationship(t *testing.T) {
+	erColl := buildMutationCollection(t, minRelationshipsValidator{min: 2})
+
+	loop := entityrelationship.Relationship{A: "loop", Kind: npb.RK_RK_TRAVERSES, Z: "loop"}
+	if err := erColl.RemoveRelationship(loop); err != nil {
+		t.Fatalf("RemoveRelationship: %v", err)
+	}
+	if erColl.RelationshipExists(loop) {
+		t.Errorf("relationship still exists after removal")
+	}
+	checkIndexes(t, erColl)
+
+	if err := erColl.RemoveRelationship(loop); err == nil {
+		t.Errorf("RemoveRelationship of missing relationship succeeded")
+	}
+
+	contains := entityrelationship.Relationship{A: "node", Kind: npb.RK_RK_CONTAINS, Z: "port"}
+	if err := erColl.RemoveRelationship(contains); !errors.Is(err, errTooFewRelationships) {
+		t.Errorf("wanted %v, got %v", errTooFewRelationships, err)
+	}
+	if !erColl.RelationshipExists(contains) {
+		t.Errorf("relationship removed despite validation failure")
+	}
+	checkIndexes(t, erColl)
+}
+
+func TestToFragmentIsOrdered(t *testing.T) {
+	erColl := buildMutationCollection(t, nil)
+
+	got := erColl.ToFragment()
+	if want := []string{"loop", "node", "platform", "port"}; !slices.Equal(want, entityIDs(got)) {
+		t.Errorf("wanted entities %v, got %v", want, entityIDs(got))
+	}
+	rels := []string{}
+	for _, r := range got.GetRelationship() {
+		rel := entityrelationship.RelationshipFromProto(r)
+		rels = append(rels, rel.String())
+	}
+	want := []string{
+		"loop->RK_TRAVERSES->loop",
+		"node->RK_CONTAINS->port",
+		"platform->RK_CONTAINS->node",
+	}
+	if !slices.Equal(want, rels) {
+		t.Errorf("wanted relationships %v, got %v", want, rels)
+	}
+}
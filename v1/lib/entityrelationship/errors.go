@@ -0,0 +1,71 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entityrelationship
+
+import (
+	"fmt"
+)
+
+// DuplicateEntityError reports an entity whose ID is already in use.
+type DuplicateEntityError struct {
+	ID string
+	// Kind is the EK string of the rejected definition.
+	Kind string
+	// Location and Previous are where the rejected definition and the
+	// existing one were defined, if known.
+	Location, Previous SourceLocation
+}
+
+func (e *DuplicateEntityError) Error() string {
+	return fmt.Sprintf("entity already exists: '%v'%s", e.ID, describeDuplicate(e.Location, e.Previous))
+}
+
+// Endpoint identifies one end of a relationship.
+type Endpoint int
+
+const (
+	EndpointA Endpoint = iota
+	EndpointZ
+)
+
+func (e Endpoint) String() string {
+	if e == EndpointZ {
+		return "Z"
+	}
+	return "A"
+}
+
+// DanglingEndpointError reports a relationship that references an entity
+// which does not exist.
+type DanglingEndpointError struct {
+	Relationship Relationship
+	// Endpoint is the end of Relationship that references the missing
+	// entity. A relationship with both ends missing is reported twice.
+	Endpoint Endpoint
+	// Location is where the relationship was defined, if known.
+	Location SourceLocation
+}
+
+// ID returns the ID of the missing entity.
+func (e *DanglingEndpointError) ID() string {
+	if e.Endpoint == EndpointZ {
+		return e.Relationship.Z
+	}
+	return e.Relationship.A
+}
+
+func (e *DanglingEndpointError) Error() string {
+	return fmt.Sprintf("relationship references non-existent entity: '%v'%s", e.ID(), describeLocation(e.Location))
+}
@@ -0,0 +1,63 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entityrelationship_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+func TestCollectionBuilderErrorsAreTyped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txtpb")
+	mustWriteFile(t, path, []byte(`entity { id: "node" ek_network_node{} }
+entity { id: "node" ek_network_node{} }
+relationship { a: "missing" kind: RK_CONTAINS z: "node" }
+`))
+	f, sources := readWithSources(t, path)
+
+	builder := entityrelationship.NewNonValidatingCollectionBuilder()
+	builder.SetSourceMap(sources)
+	err := builder.InsertFragments(f)
+
+	var duplicate *entityrelationship.DuplicateEntityError
+	if !errors.As(err, &duplicate) {
+		t.Fatalf("error %v is not a DuplicateEntityError", err)
+	}
+	wantDuplicate := entityrelationship.DuplicateEntityError{
+		ID:       "node",
+		Kind:     "EK_NETWORK_NODE",
+		Location: entityrelationship.SourceLocation{File: path, Line: 2, Column: 1},
+		Previous: entityrelationship.SourceLocation{File: path, Line: 1, Column: 1},
+	}
+	if *duplicate != wantDuplicate {
+		t.Errorf("unexpected DuplicateEntityError; want: %+v; got: %+v", wantDuplicate, *duplicate)
+	}
+
+	var dangling *entityrelationship.DanglingEndpointError
+	if !errors.As(err, &dangling) {
+		t.Fatalf("error %v is not a DanglingEndpointError", err)
+	}
+	if dangling.ID() != "missing" || dangling.Endpoint != entityrelationship.EndpointA {
+		t.Errorf("unexpected DanglingEndpointError; want missing entity 'missing' at A; got: '%v' at %v", dangling.ID(), dangling.Endpoint)
+	}
+	wantRelationship := entityrelationship.Relationship{A: "missing", Kind: npb.RK_RK_CONTAINS, Z: "node"}
+	if dangling.Relationship != wantRelationship {
+		t.Errorf("unexpected relationship in DanglingEndpointError; want: %v; got: %v", wantRelationship, dangling.Relationship)
+	}
+}
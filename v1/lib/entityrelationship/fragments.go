@@ -15,33 +15,324 @@
 package entityrelationship
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
 
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
 	npb "outernetcouncil.org/nmts/v1/proto"
 )
 
-func ReadFragmentFiles(fragmentFilenames []string) (*npb.Fragment, error) {
-	g := &npb.Fragment{}
+// StdinPath is the path that, when given to a FragmentReader, reads a
+// Fragment from standard input.
+const StdinPath = "-"
+
+// stdinSourceName names standard input in SourceLocations.
+const stdinSourceName = "<stdin>"
+
+// FragmentFormat identifies the encoding of a serialized Fragment.
+type FragmentFormat int
+
+const (
+	// FormatUnknown means the format could not be determined from a
+	// filename; the content has to be inspected instead.
+	FormatUnknown FragmentFormat = iota
+	FormatText
+	FormatBinary
+	FormatJSON
+)
+
+func (f FragmentFormat) String() string {
+	switch f {
+	case FormatText:
+		return "text"
+	case FormatBinary:
+		return "binary"
+	case FormatJSON:
+		return "json"
+	default:
+		return "unknown"
+	}
+}
+
+var fragmentFormatsByExtension = map[string]FragmentFormat{
+	".txtpb":     FormatText,
+	".textpb":    FormatText,
+	".textproto": FormatText,
+	".pbtxt":     FormatText,
+	".binpb":     FormatBinary,
+	".pb":        FormatBinary,
+	".json":      FormatJSON,
+}
+
+// FragmentFormatFromFilename returns the format implied by the filename's
+// extension, or FormatUnknown if the extension is not recognized.
+func FragmentFormatFromFilename(filename string) FragmentFormat {
+	return fragmentFormatsByExtension[strings.ToLower(filepath.Ext(filename))]
+}
+
+// SniffFragmentFormat guesses the format of a serialized Fragment from its
+// content. Binary is assumed for anything that isn't printable UTF-8, JSON
+// for printable content that starts with an object, and text otherwise.
+func SniffFragmentFormat(data []byte) FragmentFormat {
+	if !utf8.Valid(data) || bytes.ContainsFunc(data, isNonTextControl) {
+		return FormatBinary
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return FormatJSON
+	}
+	return FormatText
+}
+
+func isNonTextControl(r rune) bool {
+	return r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f' && r != '\v'
+}
+
+// UnmarshalFragment parses data in the given format. FormatUnknown sniffs
+// the format from the content.
+func UnmarshalFragment(data []byte, format FragmentFormat) (*npb.Fragment, error) {
+	fragment := &npb.Fragment{}
+	if err := UnmarshalMessage(data, format, fragment); err != nil {
+		return nil, err
+	}
+	return fragment, nil
+}
 
-	for _, f := range fragmentFilenames {
-		data, err := os.ReadFile(f)
+// UnmarshalMessage is UnmarshalFragment for any message, such as a Patch.
+func UnmarshalMessage(data []byte, format FragmentFormat, m proto.Message) error {
+	if format == FormatUnknown {
+		format = SniffFragmentFormat(data)
+	}
+
+	switch format {
+	case FormatText:
+		return prototext.Unmarshal(data, m)
+	case FormatBinary:
+		return proto.Unmarshal(data, m)
+	case FormatJSON:
+		return protojson.Unmarshal(data, m)
+	default:
+		return fmt.Errorf("unsupported fragment format: %v", format)
+	}
+}
+
+// MarshalFragment serializes fragment in the given format. The output is
+// canonical: the same Fragment always produces the same bytes, whichever
+// binary produced them. Text and JSON are multi-line with two-space
+// indentation.
+func MarshalFragment(fragment *npb.Fragment, format FragmentFormat) ([]byte, error) {
+	return MarshalMessage(fragment, format)
+}
+
+// MarshalMessage is MarshalFragment for any message, such as a Patch.
+//
+// The protobuf text and JSON encoders deliberately vary their whitespace
+// between builds, so their output is normalized here.
+func MarshalMessage(m proto.Message, format FragmentFormat) ([]byte, error) {
+	switch format {
+	case FormatText:
+		data, err := prototext.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(m)
 		if err != nil {
-			return nil, fmt.Errorf("reading %q: %w", f, err)
+			return nil, err
+		}
+		return canonicalizeTextSpacing(data), nil
+	case FormatBinary:
+		return proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	case FormatJSON:
+		data, err := protojson.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		compact := &bytes.Buffer{}
+		if err := json.Compact(compact, data); err != nil {
+			return nil, err
+		}
+		indented := &bytes.Buffer{}
+		if err := json.Indent(indented, compact.Bytes(), "", "  "); err != nil {
+			return nil, err
+		}
+		indented.WriteByte('\n')
+		return indented.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported fragment format: %v", format)
+	}
+}
+
+// canonicalizeTextSpacing removes the extra space the text encoder may
+// insert after a field name's colon. Multi-line output puts every field on
+// its own line as `<indent><name>: <value>`, and names (including bracketed
+// extension names) never contain spaces or colons, so the first colon on
+// each line ends the name.
+func canonicalizeTextSpacing(data []byte) []byte {
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if colon := bytes.IndexByte(line, ':'); colon >= 0 && bytes.HasPrefix(line[colon:], []byte(":  ")) {
+			lines[i] = slices.Delete(line, colon+1, colon+2)
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// ExpandFragmentPaths resolves the given paths into the list of files to
+// read. Directories are walked recursively and contribute every file with
+// a recognized Fragment extension, in lexical order. Paths that don't exist
+// but contain glob metacharacters are expanded with filepath.Glob; matched
+// directories are walked as above. StdinPath and plain files are passed
+// through unchanged, whatever their extension.
+func ExpandFragmentPaths(paths []string) ([]string, error) {
+	expanded := []string{}
+
+	for _, p := range paths {
+		if p == StdinPath {
+			expanded = append(expanded, p)
+			continue
 		}
-		subg := &npb.Fragment{}
-		if err := prototext.Unmarshal(data, subg); err != nil {
-			return nil, fmt.Errorf("parsing %q: %w", f, err)
+
+		matches := []string{p}
+		if _, err := os.Stat(p); err != nil && strings.ContainsAny(p, "*?[") {
+			if matches, err = filepath.Glob(p); err != nil {
+				return nil, fmt.Errorf("expanding %q: %w", p, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("expanding %q: no matching files", p)
+			}
 		}
 
-		for _, e := range subg.GetEntity() {
-			g.Entity = append(g.Entity, e)
+		for _, m := range matches {
+			files, err := expandFragmentPath(m)
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, files...)
 		}
-		for _, r := range subg.GetRelationship() {
-			g.Relationship = append(g.Relationship, r)
+	}
+
+	return expanded, nil
+}
+
+func expandFragmentPath(p string) ([]string, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, fmt.Errorf("reading %q: %w", p, err)
+	}
+	if !info.IsDir() {
+		return []string{p}, nil
+	}
+
+	files := []string{}
+	err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
 		}
+		if !d.IsDir() && FragmentFormatFromFilename(path) != FormatUnknown {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking %q: %w", p, err)
+	}
+	return files, nil
+}
+
+// FragmentReader reads Fragments from files, directories, globs and
+// standard input, in any of the supported formats.
+type FragmentReader struct {
+	// Stdin is read when StdinPath is given; nil means os.Stdin.
+	Stdin io.Reader
+
+	// Sources, if set, receives the SourceLocation of every Entity and
+	// Relationship read.
+	Sources *SourceMap
+
+	// Namespace, if set, is applied to the Fragment returned by each call
+	// to ReadFragments, so relationships may refer to entities defined in
+	// any of the files read together.
+	Namespace *Namespace
+
+	stdinRead bool
+}
+
+// ReadFragments expands paths as described by ExpandFragmentPaths and
+// returns a single Fragment holding every Entity and Relationship read, in
+// input order.
+func (fr *FragmentReader) ReadFragments(paths []string) (*npb.Fragment, error) {
+	filenames, err := ExpandFragmentPaths(paths)
+	if err != nil {
+		return nil, err
 	}
 
+	g := &npb.Fragment{}
+
+	for _, f := range filenames {
+		subg, err := fr.readFragment(f)
+		if err != nil {
+			return nil, err
+		}
+
+		g.Entity = append(g.Entity, subg.GetEntity()...)
+		g.Relationship = append(g.Relationship, subg.GetRelationship()...)
+	}
+
+	if fr.Namespace != nil {
+		if err := fr.Namespace.Apply(g); err != nil {
+			return nil, fmt.Errorf("namespacing %v: %w", paths, err)
+		}
+	}
 	return g, nil
 }
+
+func (fr *FragmentReader) readFragment(filename string) (*npb.Fragment, error) {
+	var data []byte
+	var err error
+	sourceName := filename
+	if filename == StdinPath {
+		data, err = fr.readStdin()
+		sourceName = stdinSourceName
+	} else {
+		data, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %q: %w", filename, err)
+	}
+
+	format := FragmentFormatFromFilename(filename)
+	if format == FormatUnknown {
+		format = SniffFragmentFormat(data)
+	}
+	fragment, err := UnmarshalFragment(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", filename, err)
+	}
+
+	fr.Sources.recordFragment(sourceName, data, format, fragment)
+	return fragment, nil
+}
+
+func (fr *FragmentReader) readStdin() ([]byte, error) {
+	if fr.stdinRead {
+		return nil, fmt.Errorf("standard input may only be read once")
+	}
+	fr.stdinRead = true
+
+	stdin := fr.Stdin
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	return io.ReadAll(stdin)
+}
+
+// ReadFragmentFiles reads the given files, directories or globs (see
+// FragmentReader) into a single Fragment.
+func ReadFragmentFiles(fragmentFilenames []string) (*npb.Fragment, error) {
+	return (&FragmentReader{}).ReadFragments(fragmentFilenames)
+}
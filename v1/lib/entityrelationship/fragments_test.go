@@ -0,0 +1,228 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entityrelationship_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+func mustUnmarshalFragment(t *testing.T, txtPb string) *npb.Fragment {
+	t.Helper()
+	f := new(npb.Fragment)
+	if err := prototext.Unmarshal([]byte(txtPb), f); err != nil {
+		t.Fatalf("failed to parse %s: %q", txtPb, err)
+	}
+	return f
+}
+
+func mustWriteFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("creating directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+}
+
+func entityIDs(f *npb.Fragment) []string {
+	ids := []string{}
+	for _, e := range f.GetEntity() {
+		ids = append(ids, e.GetId())
+	}
+	return ids
+}
+
+func TestSniffFragmentFormat(t *testing.T) {
+	f := mustUnmarshalFragment(t, `entity { id: "node" ek_network_node{} }`)
+	binary, err := proto.Marshal(f)
+	if err != nil {
+		t.Fatalf("marshaling binary: %v", err)
+	}
+	json, err := protojson.Marshal(f)
+	if err != nil {
+		t.Fatalf("marshaling JSON: %v", err)
+	}
+
+	for _, tc := range []struct {
+		desc string
+		data []byte
+		want entityrelationship.FragmentFormat
+	}{
+		{desc: "text", data: []byte(`entity { id: "node" ek_network_node{} }`), want: entityrelationship.FormatText},
+		{desc: "commented text", data: []byte("# {comment}\nentity {}\n"), want: entityrelationship.FormatText},
+		{desc: "empty", data: nil, want: entityrelationship.FormatText},
+		{desc: "binary", data: binary, want: entityrelationship.FormatBinary},
+		{desc: "json", data: json, want: entityrelationship.FormatJSON},
+		{desc: "indented json", data: []byte("\n  {\"entity\": []}"), want: entityrelationship.FormatJSON},
+	} {
+		if got := entityrelationship.SniffFragmentFormat(tc.data); got != tc.want {
+			t.Errorf("SniffFragmentFormat(%s): wanted %v, got %v", tc.desc, tc.want, got)
+		}
+	}
+}
+
+func TestReadFragmentsAllFormats(t *testing.T) {
+	dir := t.TempDir()
+	text := `entity { id: "text" ek_network_node{} }`
+	binary, err := proto.Marshal(mustUnmarshalFragment(t, `entity { id: "binary" ek_platform{} }`))
+	if err != nil {
+		t.Fatalf("marshaling binary: %v", err)
+	}
+	json, err := protojson.Marshal(mustUnmarshalFragment(t, `entity { id: "json" ek_port{} }`))
+	if err != nil {
+		t.Fatalf("marshaling JSON: %v", err)
+	}
+
+	mustWriteFile(t, filepath.Join(dir, "a.txtpb"), []byte(text))
+	mustWriteFile(t, filepath.Join(dir, "b.binpb"), binary)
+	mustWriteFile(t, filepath.Join(dir, "c.json"), json)
+	// No recognized extension: the format is sniffed from the content.
+	mustWriteFile(t, filepath.Join(dir, "sniffed"), binary)
+
+	fr := &entityrelationship.FragmentReader{}
+	got, err := fr.ReadFragments([]string{
+		filepath.Join(dir, "a.txtpb"),
+		filepath.Join(dir, "b.binpb"),
+		filepath.Join(dir, "c.json"),
+		filepath.Join(dir, "sniffed"),
+	})
+	if err != nil {
+		t.Fatalf("ReadFragments: %v", err)
+	}
+	if want := []string{"text", "binary", "json", "binary"}; !slices.Equal(want, entityIDs(got)) {
+		t.Errorf("ReadFragments: wanted entities %v, got %v", want, entityIDs(got))
+	}
+}
+
+func TestReadFragmentsDirectoriesAndGlobs(t *testing.T) {
+	dir := t.TempDir()
+	mustWriteFile(t, filepath.Join(dir, "top.txtpb"), []byte(`entity { id: "top" ek_platform{} }`))
+	mustWriteFile(t, filepath.Join(dir, "nested", "deeper", "leaf.txtpb"), []byte(`entity { id: "leaf" ek_port{} }`))
+	mustWriteFile(t, filepath.Join(dir, "nested", "README.md"), []byte(`not a fragment`))
+	mustWriteFile(t, filepath.Join(dir, "globbed", "one.txtpb"), []byte(`entity { id: "one" ek_port{} }`))
+	mustWriteFile(t, filepath.Join(dir, "globbed", "two.txtpb"), []byte(`entity { id: "two" ek_port{} }`))
+
+	fr := &entityrelationship.FragmentReader{}
+	got, err := fr.ReadFragments([]string{
+		filepath.Join(dir, "nested"),
+		filepath.Join(dir, "top.txtpb"),
+		filepath.Join(dir, "glob*", "*.txtpb"),
+	})
+	if err != nil {
+		t.Fatalf("ReadFragments: %v", err)
+	}
+	if want := []string{"leaf", "top", "one", "two"}; !slices.Equal(want, entityIDs(got)) {
+		t.Errorf("ReadFragments: wanted entities %v, got %v", want, entityIDs(got))
+	}
+
+	if _, err := fr.ReadFragments([]string{filepath.Join(dir, "*.nothing")}); err == nil {
+		t.Errorf("ReadFragments with an unmatched glob: wanted error, got nil")
+	}
+}
+
+func TestReadFragmentsStdin(t *testing.T) {
+	fr := &entityrelationship.FragmentReader{
+		Stdin: strings.NewReader(`entity { id: "stdin" ek_network_node{} }`),
+	}
+	got, err := fr.ReadFragments([]string{entityrelationship.StdinPath})
+	if err != nil {
+		t.Fatalf("ReadFragments: %v", err)
+	}
+	if want := []string{"stdin"}; !slices.Equal(want, entityIDs(got)) {
+		t.Errorf("ReadFragments: wanted entities %v, got %v", want, entityIDs(got))
+	}
+
+	if _, err := fr.ReadFragments([]string{entityrelationship.StdinPath}); err == nil {
+		t.Errorf("ReadFragments reading stdin twice: wanted error, got nil")
+	}
+}
+
+func TestReadFragmentsParseError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bad.json")
+	mustWriteFile(t, path, []byte(`entity { id: "not json" }`))
+
+	_, err := entityrelationship.ReadFragmentFiles([]string{path})
+	if err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("ReadFragmentFiles(%s): wanted an error naming the file, got %v", path, err)
+	}
+}
+
+func TestMarshalFragmentTextIsCanonical(t *testing.T) {
+	f := mustUnmarshalFragment(t, `
+entity { id: "node" labels { key: "note" value: "a:  b" } ek_network_node{} }
+relationship { a: "node" kind: RK_CONTAINS z: "port" }
+`)
+	want := `entity: {
+  id: "node"
+  labels: {
+    key: "note"
+    value: "a:  b"
+  }
+  ek_network_node: {}
+}
+relationship: {
+  kind: RK_CONTAINS
+  a: "node"
+  z: "port"
+}
+`
+	got, err := entityrelationship.MarshalFragment(f, entityrelationship.FormatText)
+	if err != nil {
+		t.Fatalf("MarshalFragment: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("MarshalFragment: wanted\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestMarshalFragmentRoundTrip(t *testing.T) {
+	f := mustUnmarshalFragment(t, `
+entity { id: "node" labels { key: "env" value: "prod" } ek_network_node{} }
+entity { id: "port" ek_port{} }
+relationship { a: "node" kind: RK_CONTAINS z: "port" }
+`)
+	for _, format := range []entityrelationship.FragmentFormat{
+		entityrelationship.FormatText,
+		entityrelationship.FormatBinary,
+		entityrelationship.FormatJSON,
+	} {
+		data, err := entityrelationship.MarshalFragment(f, format)
+		if err != nil {
+			t.Fatalf("MarshalFragment(%v): %v", format, err)
+		}
+		if format != entityrelationship.FormatBinary && strings.Contains(string(data), ":  ") {
+			t.Errorf("MarshalFragment(%v) left unnormalized spacing:\n%s", format, data)
+		}
+		got, err := entityrelationship.UnmarshalFragment(data, entityrelationship.FormatUnknown)
+		if err != nil {
+			t.Fatalf("UnmarshalFragment(%v): %v", format, err)
+		}
+		if !proto.Equal(f, got) {
+			t.Errorf("%v round trip: wanted %v, got %v", format, f, got)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entityrelationship
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// MergePolicy determines how a CollectionBuilder handles an entity whose ID
+// was already inserted.
+type MergePolicy int
+
+const (
+	// MergeError rejects every duplicate entity.
+	MergeError MergePolicy = iota
+
+	// MergeIdenticalOnly accepts a duplicate that is equal to the entity
+	// already inserted, and rejects any other.
+	MergeIdenticalOnly
+
+	// MergeLastWins replaces the entity already inserted with the
+	// duplicate.
+	MergeLastWins
+
+	// MergeDeep merges the duplicate into the entity already inserted
+	// with proto.Merge semantics: singular fields set in the duplicate
+	// overwrite, repeated fields are appended and map entries are added.
+	// It is an error for a scalar field or map entry to be set to
+	// different values in both, or for different fields of a oneof to be
+	// set.
+	MergeDeep
+)
+
+var mergePolicyNames = []string{
+	MergeError:         "error",
+	MergeIdenticalOnly: "identical",
+	MergeLastWins:      "last-wins",
+	MergeDeep:          "deep",
+}
+
+func (p MergePolicy) String() string {
+	if p < 0 || int(p) >= len(mergePolicyNames) {
+		return fmt.Sprintf("MergePolicy(%d)", int(p))
+	}
+	return mergePolicyNames[p]
+}
+
+// ParseMergePolicy returns the MergePolicy with the given name, as returned
+// by MergePolicy.String.
+func ParseMergePolicy(name string) (MergePolicy, error) {
+	for p, n := range mergePolicyNames {
+		if n == name {
+			return MergePolicy(p), nil
+		}
+	}
+	return MergeError, fmt.Errorf("unknown merge policy '%v', expected one of: %v", name, strings.Join(mergePolicyNames, ", "))
+}
+
+// Merge resolves the duplicate entity against the entity already inserted
+// with the same ID, returning the entity to keep. Neither argument is
+// modified. The entity kind may never change.
+func (p MergePolicy) Merge(existing, duplicate *npb.Entity) (*npb.Entity, error) {
+	if oldKind, newKind := EntityKindStringFromProto(existing), EntityKindStringFromProto(duplicate); oldKind != newKind {
+		return nil, fmt.Errorf("entity cannot change kind from %s to %s", oldKind, newKind)
+	}
+
+	switch p {
+	case MergeIdenticalOnly:
+		if !proto.Equal(existing, duplicate) {
+			return nil, fmt.Errorf("entity differs from its previous definition")
+		}
+		return existing, nil
+	case MergeLastWins:
+		return duplicate, nil
+	case MergeDeep:
+		if err := mergeConflicts(existing.ProtoReflect(), duplicate.ProtoReflect(), ""); err != nil {
+			return nil, err
+		}
+		merged := proto.Clone(existing).(*npb.Entity)
+		proto.Merge(merged, duplicate)
+		return merged, nil
+	default:
+		return nil, fmt.Errorf("entity already exists")
+	}
+}
+
+// mergeConflicts reports every field that proto.Merge would silently
+// overwrite when merging src into dst.
+func mergeConflicts(dst, src protoreflect.Message, path string) error {
+	errs := []error{}
+
+	src.Range(func(fd protoreflect.FieldDescriptor, srcValue protoreflect.Value) bool {
+		fieldPath := joinFieldPath(path, fieldName(fd))
+
+		if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			if set := dst.WhichOneof(oneof); set != nil && set.Number() != fd.Number() {
+				errs = append(errs, fmt.Errorf("conflicting fields set in oneof '%v': %v and %v", joinFieldPath(path, string(oneof.Name())), set.Name(), fd.Name()))
+				return true
+			}
+		}
+		if !dst.Has(fd) {
+			return true
+		}
+		dstValue := dst.Get(fd)
+
+		switch {
+		case fd.IsList():
+			// Lists are appended, so never conflict.
+		case fd.IsMap():
+			srcValue.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				if dstValue.Map().Has(k) && !dstValue.Map().Get(k).Equal(v) {
+					errs = append(errs, fmt.Errorf("conflicting values for '%v[%v]'", fieldPath, k.Interface()))
+				}
+				return true
+			})
+		case fd.Message() != nil:
+			errs = append(errs, mergeConflicts(dstValue.Message(), srcValue.Message(), fieldPath))
+		default:
+			if !dstValue.Equal(srcValue) {
+				errs = append(errs, fmt.Errorf("conflicting values for '%v': %v and %v", fieldPath, dstValue.Interface(), srcValue.Interface()))
+			}
+		}
+		return true
+	})
+
+	return errors.Join(errs...)
+}
+
+func fieldName(fd protoreflect.FieldDescriptor) string {
+	if fd.IsExtension() {
+		return "[" + string(fd.FullName()) + "]"
+	}
+	return string(fd.Name())
+}
+
+func joinFieldPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,162 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entityrelationship_test
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"outernetcouncil.org/nmts/v1/lib/entityrelationship"
+)
+
+const physicalFragment = `
+entity { id: "platform" ek_platform{ name: "sat-1" } }
+`
+
+const labelsFragment = `
+entity { id: "platform" labels { key: "owner" value: "ops" } ek_platform{} }
+`
+
+var mergePolicyTestCases = []struct {
+	desc      string
+	policy    entityrelationship.MergePolicy
+	fragments []string
+	want      string
+	wantErr   string
+}{
+	{
+		desc:      "error rejects duplicates",
+		policy:    entityrelationship.MergeError,
+		fragments: []string{physicalFragment, physicalFragment},
+		wantErr:   "entity already exists",
+	},
+	{
+		desc:      "identical accepts equal duplicates",
+		policy:    entityrelationship.MergeIdenticalOnly,
+		fragments: []string{physicalFragment, physicalFragment},
+		want:      `id: "platform" ek_platform{ name: "sat-1" }`,
+	},
+	{
+		desc:      "identical rejects differing duplicates",
+		policy:    entityrelationship.MergeIdenticalOnly,
+		fragments: []string{physicalFragment, labelsFragment},
+		wantErr:   "differs from its previous definition",
+	},
+	{
+		desc:      "last wins",
+		policy:    entityrelationship.MergeLastWins,
+		fragments: []string{physicalFragment, labelsFragment},
+		want:      `id: "platform" labels { key: "owner" value: "ops" } ek_platform{}`,
+	},
+	{
+		desc:      "deep merge combines fields",
+		policy:    entityrelationship.MergeDeep,
+		fragments: []string{physicalFragment, labelsFragment},
+		want:      `id: "platform" labels { key: "owner" value: "ops" } ek_platform{ name: "sat-1" }`,
+	},
+	{
+		desc:      "deep merge accepts equal scalars",
+		policy:    entityrelationship.MergeDeep,
+		fragments: []string{physicalFragment, physicalFragment, labelsFragment, labelsFragment},
+		want:      `id: "platform" labels { key: "owner" value: "ops" } ek_platform{ name: "sat-1" }`,
+	},
+	{
+		desc:   "deep merge detects scalar conflicts",
+		policy: entityrelationship.MergeDeep,
+		fragments: []string{
+			physicalFragment,
+			`entity { id: "platform" ek_platform{ name: "sat-2" } }`,
+		},
+		wantErr: "conflicting values for 'ek_platform.name': sat-1 and sat-2",
+	},
+	{
+		desc:   "deep merge detects map entry conflicts",
+		policy: entityrelationship.MergeDeep,
+		fragments: []string{
+			labelsFragment,
+			`entity { id: "platform" labels { key: "owner" value: "eng" } ek_platform{} }`,
+		},
+		wantErr: "conflicting values for 'labels[owner]'",
+	},
+	{
+		desc:   "kind may not change",
+		policy: entityrelationship.MergeLastWins,
+		fragments: []string{
+			physicalFragment,
+			`entity { id: "platform" ek_port{} }`,
+		},
+		wantErr: "cannot change kind from EK_PLATFORM to EK_PORT",
+	},
+}
+
+func TestMergePolicies(t *testing.T) {
+	for _, tc := range mergePolicyTestCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			builder := entityrelationship.NewNonValidatingCollectionBuilder()
+			builder.SetMergePolicy(tc.policy)
+			for _, f := range tc.fragments {
+				if err := builder.InsertFragments(mustUnmarshalFragment(t, f)); err != nil {
+					if tc.wantErr == "" || !strings.Contains(err.Error(), tc.wantErr) {
+						t.Fatalf("wanted error containing %q, got %v", tc.wantErr, err)
+					}
+					return
+				}
+			}
+			if tc.wantErr != "" {
+				t.Fatalf("wanted error containing %q, got none", tc.wantErr)
+			}
+
+			erColl, err := builder.Build()
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+			if want, got := mustUnmarshalEntity(t, tc.want), erColl.Entities["platform"]; !proto.Equal(want, got) {
+				t.Errorf("wanted %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestMergeDeepDoesNotModifyInputs(t *testing.T) {
+	first := mustUnmarshalFragment(t, physicalFragment)
+	second := mustUnmarshalFragment(t, labelsFragment)
+	firstCopy, secondCopy := proto.Clone(first), proto.Clone(second)
+
+	builder := entityrelationship.NewNonValidatingCollectionBuilder()
+	builder.SetMergePolicy(entityrelationship.MergeDeep)
+	if err := builder.InsertFragments(first, second); err != nil {
+		t.Fatalf("InsertFragments: %v", err)
+	}
+	if !proto.Equal(first, firstCopy) || !proto.Equal(second, secondCopy) {
+		t.Errorf("deep merge modified its input fragments")
+	}
+}
+
+func TestParseMergePolicy(t *testing.T) {
+	for _, p := range []entityrelationship.MergePolicy{
+		entityrelationship.MergeError,
+		entityrelationship.MergeIdenticalOnly,
+		entityrelationship.MergeLastWins,
+		entityrelationship.MergeDeep,
+	} {
+		if got, err := entityrelationship.ParseMergePolicy(p.String()); err != nil || got != p {
+			t.Errorf("ParseMergePolicy(%q): wanted %v, got %v, %v", p.String(), p, got, err)
+		}
+	}
+	if _, err := entityrelationship.ParseMergePolicy("bogus"); err == nil {
+		t.Errorf("ParseMergePolicy accepted an unknown policy")
+	}
+}
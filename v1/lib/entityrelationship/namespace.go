@@ -0,0 +1,105 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entityrelationship
+
+import (
+	"errors"
+	"fmt"
+
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// Namespace rewrites the IDs in a Fragment so that the same sub-topology
+// can be imported many times without collisions.
+type Namespace struct {
+	// MapID returns the rewritten form of an ID.
+	MapID func(id string) string
+
+	// External lists the IDs of entities defined outside the Fragment that
+	// its relationships may refer to. They are never rewritten.
+	External []string
+}
+
+// PrefixNamespace returns a Namespace that prepends prefix to every ID
+// other than those listed in external.
+func PrefixNamespace(prefix string, external ...string) *Namespace {
+	return &Namespace{
+		MapID:    func(id string) string { return prefix + id },
+		External: external,
+	}
+}
+
+// Apply rewrites, in place, the ID of every entity in fragment and the A
+// and Z of every relationship that refers to one of them. Relationship
+// endpoints listed in External are left unchanged; any other endpoint that
+// isn't defined in fragment is an error. Rewritten IDs must remain unique
+// and distinct from the external IDs. On error, fragment is not modified.
+func (ns *Namespace) Apply(fragment *npb.Fragment) error {
+	external := make(map[string]bool, len(ns.External))
+	for _, id := range ns.External {
+		external[id] = true
+	}
+
+	errs := []error{}
+	mapped := map[string]string{}
+	mappedFrom := map[string]string{}
+	for _, entity := range fragment.GetEntity() {
+		id := entity.GetId()
+		if external[id] {
+			errs = append(errs, fmt.Errorf("entity '%v' is defined in the fragment but declared external", id))
+			continue
+		}
+		if _, exists := mapped[id]; exists {
+			// Duplicates are left for the CollectionBuilder's MergePolicy.
+			continue
+		}
+
+		newID := ns.MapID(id)
+		if external[newID] {
+			errs = append(errs, fmt.Errorf("entity '%v' maps to external ID '%v'", id, newID))
+		}
+		if other, exists := mappedFrom[newID]; exists {
+			errs = append(errs, fmt.Errorf("entities '%v' and '%v' both map to '%v'", other, id, newID))
+		}
+		mapped[id] = newID
+		mappedFrom[newID] = id
+	}
+
+	for _, relationship := range fragment.GetRelationship() {
+		for _, id := range []string{relationship.GetA(), relationship.GetZ()} {
+			if _, local := mapped[id]; !local && !external[id] {
+				r := RelationshipFromProto(relationship)
+				errs = append(errs, fmt.Errorf("relationship '%v' references '%v', which is neither defined in the fragment nor declared external", r.String(), id))
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	for _, entity := range fragment.GetEntity() {
+		entity.Id = mapped[entity.GetId()]
+	}
+	for _, relationship := range fragment.GetRelationship() {
+		if newID, local := mapped[relationship.GetA()]; local {
+			relationship.A = newID
+		}
+		if newID, local := mapped[relationship.GetZ()]; local {
+			relationship.Z = newID
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entityrelationship_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"outernetcouncil.org/nmts/v1/lib/entityrelationship"
+)
+
+const terminalFragment = `
+entity { id: "platform" ek_platform{} }
+entity { id: "node" ek_network_node{} }
+relationship { a: "platform" kind: RK_CONTAINS z: "node" }
+relationship { a: "sdn-agent" kind: RK_CONTROLS z: "node" }
+`
+
+func TestNamespaceApply(t *testing.T) {
+	f := mustUnmarshalFragment(t, terminalFragment)
+	if err := entityrelationship.PrefixNamespace("ut1/", "sdn-agent").Apply(f); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	want := mustUnmarshalFragment(t, `
+entity { id: "ut1/platform" ek_platform{} }
+entity { id: "ut1/node" ek_network_node{} }
+relationship { a: "ut1/platform" kind: RK_CONTAINS z: "ut1/node" }
+relationship { a: "sdn-agent" kind: RK_CONTROLS z: "ut1/node" }
+`)
+	if !proto.Equal(want, f) {
+		t.Errorf("wanted %v, got %v", want, f)
+	}
+}
+
+func TestNamespaceApplyMapID(t *testing.T) {
+	f := mustUnmarshalFragment(t, terminalFragment)
+	ns := &entityrelationship.Namespace{
+		MapID:    strings.ToUpper,
+		External: []string{"sdn-agent"},
+	}
+	if err := ns.Apply(f); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got := entityIDs(f); got[0] != "PLATFORM" || got[1] != "NODE" {
+		t.Errorf("unexpected entity IDs: %v", got)
+	}
+}
+
+var namespaceErrorTestCases = []struct {
+	desc    string
+	ns      *entityrelationship.Namespace
+	wantErr string
+}{
+	{
+		desc:    "undeclared external reference",
+		ns:      entityrelationship.PrefixNamespace("ut1/"),
+		wantErr: "'sdn-agent', which is neither defined in the fragment nor declared external",
+	},
+	{
+		desc: "colliding IDs",
+		ns: &entityrelationship.Namespace{
+			MapID:    func(string) string { return "same" },
+			External: []string{"sdn-agent"},
+		},
+		wantErr: "entities 'platform' and 'node' both map to 'same'",
+	},
+	{
+		desc:    "defined entity declared external",
+		ns:      entityrelationship.PrefixNamespace("ut1/", "sdn-agent", "node"),
+		wantErr: "entity 'node' is defined in the fragment but declared external",
+	},
+}
+
+func TestNamespaceApplyErrors(t *testing.T) {
+	for _, tc := range namespaceErrorTestCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			f := mustUnmarshalFragment(t, terminalFragment)
+			err := tc.ns.Apply(f)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("wanted error containing %q, got %v", tc.wantErr, err)
+			}
+			if !proto.Equal(mustUnmarshalFragment(t, terminalFragment), f) {
+				t.Errorf("failed Apply modified the fragment: %v", f)
+			}
+		})
+	}
+}
+
+func TestFragmentReaderNamespace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terminal.txtpb")
+	mustWriteFile(t, path, []byte(terminalFragment))
+
+	builder := entityrelationship.NewNonValidatingCollectionBuilder()
+	sources := entityrelationship.NewSourceMap()
+	builder.SetSourceMap(sources)
+	if err := builder.InsertFragments(mustUnmarshalFragment(t, `entity { id: "sdn-agent" ek_sdn_agent{} }`)); err != nil {
+		t.Fatalf("InsertFragments: %v", err)
+	}
+
+	// Instantiate the same file twice under different prefixes.
+	for _, prefix := range []string{"ut1/", "ut2/"} {
+		fr := &entityrelationship.FragmentReader{
+			Sources:   sources,
+			Namespace: entityrelationship.PrefixNamespace(prefix, "sdn-agent"),
+		}
+		f, err := fr.ReadFragments([]string{path})
+		if err != nil {
+			t.Fatalf("ReadFragments: %v", err)
+		}
+		if err := builder.InsertFragments(f); err != nil {
+			t.Fatalf("InsertFragments: %v", err)
+		}
+	}
+
+	erColl, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if got := erColl.NumEntities(); got != 5 {
+		t.Errorf("wanted 5 entities, got %d", got)
+	}
+	if got := erColl.NumRelationships(); got != 4 {
+		t.Errorf("wanted 4 relationships, got %d", got)
+	}
+	want := entityrelationship.SourceLocation{File: path, Line: 3, Column: 1}
+	if got, _ := erColl.EntitySource("ut2/node"); got != want {
+		t.Errorf("source of namespaced entity: wanted %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entityrelationship
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// Apply applies the patch to the collection, in the order documented on
+// the Patch message. Added and replaced entities, added relationships, and
+// the relationships incident to replaced entities are checked with the
+// configured Validator, as is the resulting collection. If any change
+// cannot be applied or any check fails, every error is returned and the
+// collection is left unchanged.
+func (erColl *Collection) Apply(patch *npb.Patch) error {
+	patched := erColl.clone()
+	if err := patched.applyPatch(patch); err != nil {
+		return err
+	}
+	*erColl = *patched
+	return nil
+}
+
+// clone returns a copy of the collection that can be modified without
+// affecting the original. Entities are shared, not copied.
+func (erColl *Collection) clone() *Collection {
+	cloneSets := func(m map[string]*RelationshipSet) map[string]*RelationshipSet {
+		clone := make(map[string]*RelationshipSet, len(m))
+		for k, rs := range m {
+			clone[k] = &RelationshipSet{Relations: maps.Clone(rs.Relations)}
+		}
+		return clone
+	}
+
+	return &Collection{
+		Entities:            maps.Clone(erColl.Entities),
+		OutEdges:            cloneSets(erColl.OutEdges),
+		InEdges:             cloneSets(erColl.InEdges),
+		Sources:             erColl.Sources,
+		relationshipSources: maps.Clone(erColl.relationshipSources),
+		validator:           erColl.validator,
+	}
+}
+
+func (erColl *Collection) applyPatch(patch *npb.Patch) error {
+	errs := []error{}
+	validateEntity := func(entity *npb.Entity) error {
+		if erColl.validator == nil {
+			return nil
+		}
+		return erColl.validator.ValidateEntity(erColl, entity)
+	}
+	validateRelationship := func(r Relationship) error {
+		if erColl.validator == nil {
+			return nil
+		}
+		return erColl.validator.ValidateRelationship(erColl, r)
+	}
+
+	for _, rel := range patch.GetRemoveRelationship() {
+		r := RelationshipFromProto(rel)
+		if !erColl.RelationshipExists(r) {
+			errs = append(errs, fmt.Errorf("cannot remove relationship that does not exist: '%v'", r.String()))
+			continue
+		}
+		erColl.unindexRelationship(r)
+	}
+
+	for _, key := range patch.GetRemoveEntity() {
+		if !erColl.EntityExists(key) {
+			errs = append(errs, fmt.Errorf("cannot remove entity that does not exist: '%v'", key))
+			continue
+		}
+		if incident := erColl.IncidentRelationships(key); len(incident) > 0 {
+			errs = append(errs, fmt.Errorf("cannot remove entity '%v' while it is still referenced by %d relationship(s), e.g. '%v'", key, len(incident), incident[0].String()))
+			continue
+		}
+		delete(erColl.Entities, key)
+	}
+
+	replaced := []string{}
+	for _, entity := range patch.GetReplaceEntity() {
+		key := entity.GetId()
+		old, exists := erColl.Entities[key]
+		if !exists {
+			errs = append(errs, fmt.Errorf("cannot replace entity that does not exist: '%v'", key))
+			continue
+		}
+		if oldKind, newKind := EntityKindStringFromProto(old), EntityKindStringFromProto(entity); oldKind != newKind {
+			errs = append(errs, fmt.Errorf("entity '%v' cannot change kind from %s to %s", key, oldKind, newKind))
+			continue
+		}
+		if err := validateEntity(entity); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		erColl.Entities[key] = entity
+		replaced = append(replaced, key)
+	}
+
+	for _, entity := range patch.GetAddEntity() {
+		if err := validateEntity(entity); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, erColl.InsertEntity(entity))
+	}
+
+	checked := map[Relationship]struct{}{}
+	for _, rel := range patch.GetAddRelationship() {
+		r := RelationshipFromProto(rel)
+		if err := erColl.CreateRelationship(r); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		checked[r] = struct{}{}
+		errs = append(errs, validateRelationship(r))
+	}
+
+	// The relationships of a replaced entity are re-checked against its new
+	// definition, unless they were already checked.
+	for _, key := range replaced {
+		for _, r := range erColl.IncidentRelationships(key) {
+			if _, exists := checked[r]; !exists {
+				checked[r] = struct{}{}
+				errs = append(errs, validateRelationship(r))
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	return erColl.validateCollection()
+}
@@ -0,0 +1,136 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entityrelationship_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+
+	"outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+func mustUnmarshalPatch(t *testing.T, txtPb string) *npb.Patch {
+	t.Helper()
+	patch := &npb.Patch{}
+	if err := prototext.Unmarshal([]byte(txtPb), patch); err != nil {
+		t.Fatalf("unable to unmarshal patch: %v", err)
+	}
+	return patch
+}
+
+func TestApply(t *testing.T) {
+	erColl := buildMutationCollection(t, minRelationshipsValidator{})
+
+	// Turns "port" into an interface, which requires detaching it first.
+	patch := mustUnmarshalPatch(t, `
+remove_relationship { a: "node" kind: RK_CONTAINS z: "port" }
+remove_entity: "port"
+replace_entity { id: "node" labels { key: "role" value: "router" } ek_network_node{} }
+add_entity { id: "port" ek_interface{} }
+add_relationship { a: "node" kind: RK_CONTAINS z: "port" }
+add_relationship { a: "port" kind: RK_TRAVERSES z: "loop" }
+`)
+	if err := erColl.Apply(patch); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	want := mustUnmarshalFragment(t, `
+entity { id: "loop" ek_port{} }
+entity { id: "node" labels { key: "role" value: "router" } ek_network_node{} }
+entity { id: "platform" ek_platform{} }
+entity { id: "port" ek_interface{} }
+relationship { a: "loop" kind: RK_TRAVERSES z: "loop" }
+relationship { a: "node" kind: RK_CONTAINS z: "port" }
+relationship { a: "platform" kind: RK_CONTAINS z: "node" }
+relationship { a: "port" kind: RK_TRAVERSES z: "loop" }
+`)
+	if got := erColl.ToFragment(); !proto.Equal(want, got) {
+		t.Errorf("unexpected collection after Apply; want: %v; got: %v", want, got)
+	}
+	checkIndexes(t, erColl)
+}
+
+var applyErrorTestCases = []struct {
+	desc      string
+	validator entityrelationship.Validator
+	patch     string
+	wantErr   error
+	wantSub   []string
+}{
+	{
+		desc:    "remove missing relationship",
+		patch:   `remove_relationship { a: "platform" kind: RK_CONTAINS z: "port" }`,
+		wantSub: []string{"cannot remove relationship that does not exist"},
+	},
+	{
+		desc:    "remove referenced entity",
+		patch:   `remove_entity: "port"`,
+		wantSub: []string{"cannot remove entity 'port' while it is still referenced"},
+	},
+	{
+		desc:    "replace changes kind",
+		patch:   `replace_entity { id: "port" ek_interface{} }`,
+		wantSub: []string{"cannot change kind"},
+	},
+	{
+		desc:    "add duplicate entity and dangling relationship",
+		patch:   `add_entity { id: "node" ek_network_node{} } add_relationship { a: "node" kind: RK_CONTAINS z: "missing" }`,
+		wantSub: []string{"entity already exists: 'node'", "non-existent entity: 'missing'"},
+	},
+	{
+		desc:      "entity rejected by validator",
+		validator: minRelationshipsValidator{},
+		patch:     `add_entity { id: "new" labels { key: "bad" value: "" } ek_port{} }`,
+		wantErr:   errBadLabel,
+	},
+	{
+		desc:      "collection rejected by validator",
+		validator: minRelationshipsValidator{min: 3},
+		patch:     `remove_relationship { a: "loop" kind: RK_TRAVERSES z: "loop" } add_entity { id: "new" ek_port{} }`,
+		wantErr:   errTooFewRelationships,
+	},
+}
+
+func TestApplyIsAllOrNothing(t *testing.T) {
+	for _, tc := range applyErrorTestCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			erColl := buildMutationCollection(t, tc.validator)
+			before := erColl.ToFragment()
+
+			err := erColl.Apply(mustUnmarshalPatch(t, tc.patch))
+			if err == nil {
+				t.Fatalf("Apply succeeded")
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("wanted %v, got %v", tc.wantErr, err)
+			}
+			for _, sub := range tc.wantSub {
+				if !strings.Contains(err.Error(), sub) {
+					t.Errorf("error %q does not mention %q", err, sub)
+				}
+			}
+
+			if got := erColl.ToFragment(); !proto.Equal(before, got) {
+				t.Errorf("collection changed by failed Apply; want: %v; got: %v", before, got)
+			}
+			checkIndexes(t, erColl)
+		})
+	}
+}
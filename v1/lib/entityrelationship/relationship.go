@@ -15,6 +15,7 @@
 package entityrelationship
 
 import (
+	"cmp"
 	"fmt"
 
 	npb "outernetcouncil.org/nmts/v1/proto"
@@ -42,6 +43,26 @@ func (r *Relationship) ToProto() *npb.Relationship {
 	}
 }
 
+// CompareRelationships orders relationships by A, then kind (by enum value),
+// then Z.
+func CompareRelationships(l, r Relationship) int {
+	return cmp.Or(
+		cmp.Compare(l.A, r.A),
+		cmp.Compare(l.Kind, r.Kind),
+		cmp.Compare(l.Z, r.Z),
+	)
+}
+
+// ParseRelationshipKind returns the relationship kind with the given name, e.g. "RK_CONTAINS".
+// RK_UNSPECIFIED isn't a kind a relationship may have, so it's rejected like an unknown name.
+func ParseRelationshipKind(name string) (npb.RK, error) {
+	rk, ok := npb.RK_value[name]
+	if !ok || npb.RK(rk) == npb.RK_RK_UNSPECIFIED {
+		return npb.RK_RK_UNSPECIFIED, fmt.Errorf("unknown relationship kind '%v'", name)
+	}
+	return npb.RK(rk), nil
+}
+
 func (r *Relationship) String() string {
 	return fmt.Sprintf("%v->%s->%v", r.A, r.Kind.String(), r.Z)
 }
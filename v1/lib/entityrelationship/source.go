@@ -0,0 +1,292 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entityrelationship
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// SourceLocation identifies where an Entity or Relationship was defined.
+type SourceLocation struct {
+	File string
+	// Line and Column are 1-based, and zero when the source format doesn't
+	// provide positions (e.g. binary fragments).
+	Line, Column int
+}
+
+func (loc SourceLocation) IsZero() bool {
+	return loc == SourceLocation{}
+}
+
+func (loc SourceLocation) String() string {
+	switch {
+	case loc.IsZero():
+		return "<unknown>"
+	case loc.Line == 0:
+		return loc.File
+	default:
+		return fmt.Sprintf("%s:%d:%d", loc.File, loc.Line, loc.Column)
+	}
+}
+
+// SourceMap records the SourceLocation of individual Entity and
+// Relationship messages. Messages are keyed by identity rather than value,
+// so two identical definitions in different files keep distinct locations.
+//
+// A nil *SourceMap is valid and records nothing.
+type SourceMap struct {
+	entities      map[*npb.Entity]SourceLocation
+	relationships map[*npb.Relationship]SourceLocation
+}
+
+func NewSourceMap() *SourceMap {
+	return &SourceMap{
+		entities:      make(map[*npb.Entity]SourceLocation),
+		relationships: make(map[*npb.Relationship]SourceLocation),
+	}
+}
+
+func (sm *SourceMap) SetEntity(e *npb.Entity, loc SourceLocation) {
+	if sm != nil && e != nil {
+		sm.entities[e] = loc
+	}
+}
+
+func (sm *SourceMap) SetRelationship(r *npb.Relationship, loc SourceLocation) {
+	if sm != nil && r != nil {
+		sm.relationships[r] = loc
+	}
+}
+
+func (sm *SourceMap) Entity(e *npb.Entity) (SourceLocation, bool) {
+	if sm == nil || e == nil {
+		return SourceLocation{}, false
+	}
+	loc, ok := sm.entities[e]
+	return loc, ok
+}
+
+func (sm *SourceMap) Relationship(r *npb.Relationship) (SourceLocation, bool) {
+	if sm == nil || r == nil {
+		return SourceLocation{}, false
+	}
+	loc, ok := sm.relationships[r]
+	return loc, ok
+}
+
+// recordFragment records locations for every Entity and Relationship in a
+// fragment parsed from data in the given format. Positions are only
+// available for text and JSON; elsewhere only the file is recorded.
+func (sm *SourceMap) recordFragment(file string, data []byte, format FragmentFormat, fragment *npb.Fragment) {
+	if sm == nil {
+		return
+	}
+
+	var positions map[string][]int
+	switch format {
+	case FormatText:
+		positions = textFieldOffsets(data)
+	case FormatJSON:
+		positions = jsonFieldOffsets(data)
+	}
+
+	lines := newLineIndex(data)
+	locate := func(field string, i, n int) SourceLocation {
+		offsets := positions[field]
+		if len(offsets) != n {
+			// The scan disagreed with the parser; don't guess.
+			return SourceLocation{File: file}
+		}
+		line, col := lines.position(offsets[i])
+		return SourceLocation{File: file, Line: line, Column: col}
+	}
+
+	for i, e := range fragment.GetEntity() {
+		sm.SetEntity(e, locate("entity", i, len(fragment.GetEntity())))
+	}
+	for i, r := range fragment.GetRelationship() {
+		sm.SetRelationship(r, locate("relationship", i, len(fragment.GetRelationship())))
+	}
+}
+
+// lineIndex converts byte offsets into 1-based line and column numbers.
+// Columns count runes, not bytes.
+type lineIndex struct {
+	data   []byte
+	starts []int
+}
+
+func newLineIndex(data []byte) *lineIndex {
+	starts := []int{0}
+	for i, c := range data {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &lineIndex{data: data, starts: starts}
+}
+
+func (li *lineIndex) position(offset int) (line, col int) {
+	line = sort.Search(len(li.starts), func(i int) bool { return li.starts[i] > offset })
+	col = utf8.RuneCount(li.data[li.starts[line-1]:offset]) + 1
+	return line, col
+}
+
+// textFieldOffsets scans a text format Fragment and returns, for each
+// top-level field name, the byte offsets at which each of its values
+// begins: the field name for `entity { ... }`, and the opening brace of
+// each element for the list form `entity: [{ ... }, { ... }]`.
+func textFieldOffsets(data []byte) map[string][]int {
+	offsets := map[string][]int{}
+
+	depth := 0
+	inList := false
+	field, fieldOffset := "", -1
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '#':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '"' || c == '\'':
+			i++
+			for i < len(data) && data[i] != c && data[i] != '\n' {
+				if data[i] == '\\' {
+					i++
+				}
+				i++
+			}
+		case c == '{' || c == '<':
+			if depth == 0 {
+				if inList {
+					offsets[field] = append(offsets[field], i)
+				} else if fieldOffset >= 0 {
+					offsets[field] = append(offsets[field], fieldOffset)
+					fieldOffset = -1
+				}
+			}
+			depth++
+		case c == '}' || c == '>':
+			depth--
+		case c == '[' && depth == 0:
+			if fieldOffset >= 0 {
+				inList = true
+				fieldOffset = -1
+			} else {
+				// An extension field name, e.g. [foo.bar]; skip it.
+				for i < len(data) && data[i] != ']' {
+					i++
+				}
+			}
+		case c == ']' && depth == 0:
+			inList = false
+		case depth == 0 && isIdentStart(c):
+			start := i
+			for i < len(data) && isIdentPart(data[i]) {
+				i++
+			}
+			field, fieldOffset = string(data[start:i]), start
+			continue
+		}
+		i++
+	}
+
+	return offsets
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// jsonFieldOffsets scans a JSON Fragment and returns, for each top-level
+// array-valued field, the byte offsets at which each of its elements
+// begins. It returns nil if data isn't a JSON object.
+func jsonFieldOffsets(data []byte) map[string][]int {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil
+	}
+
+	offsets := map[string][]int{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		key, _ := tok.(string)
+
+		if tok, err := dec.Token(); err != nil {
+			return nil
+		} else if tok != json.Delim('[') {
+			// A scalar was consumed whole; an object has to be skipped.
+			if tok == json.Delim('{') && skipJSONValue(dec, 1) != nil {
+				return nil
+			}
+			continue
+		}
+		for dec.More() {
+			// InputOffset points just past the previous token, before any
+			// whitespace or separating comma.
+			offset := int(dec.InputOffset())
+			for offset < len(data) && (data[offset] == ',' || isJSONSpace(data[offset])) {
+				offset++
+			}
+			offsets[key] = append(offsets[key], offset)
+
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil
+		}
+	}
+
+	return offsets
+}
+
+func skipJSONValue(dec *json.Decoder, depth int) error {
+	for depth > 0 {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
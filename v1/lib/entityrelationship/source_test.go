@@ -0,0 +1,203 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entityrelationship_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+func readWithSources(t *testing.T, paths ...string) (*npb.Fragment, *entityrelationship.SourceMap) {
+	t.Helper()
+	sources := entityrelationship.NewSourceMap()
+	fr := &entityrelationship.FragmentReader{Sources: sources}
+	f, err := fr.ReadFragments(paths)
+	if err != nil {
+		t.Fatalf("ReadFragments(%v): %v", paths, err)
+	}
+	return f, sources
+}
+
+func TestSourceLocationsText(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txtpb")
+	mustWriteFile(t, path, []byte(`# entity { id: "commented out" }
+entity { id: "one" ek_platform{ name: "entity {" } }
+  entity {
+    id: "two"
+    ek_port{}
+  }
+relationship { a: "one" kind: RK_CONTAINS z: "two" }
+entity: [{ id: "three" ek_port{} }, <id: "four" ek_port{}>]
+`))
+	f, sources := readWithSources(t, path)
+
+	want := map[string]entityrelationship.SourceLocation{
+		"one":   {File: path, Line: 2, Column: 1},
+		"two":   {File: path, Line: 3, Column: 3},
+		"three": {File: path, Line: 8, Column: 10},
+		"four":  {File: path, Line: 8, Column: 37},
+	}
+	for _, e := range f.GetEntity() {
+		got, ok := sources.Entity(e)
+		if !ok || got != want[e.GetId()] {
+			t.Errorf("source of entity %q: wanted %v, got %v (ok=%t)", e.GetId(), want[e.GetId()], got, ok)
+		}
+	}
+
+	wantRel := entityrelationship.SourceLocation{File: path, Line: 7, Column: 1}
+	if got, ok := sources.Relationship(f.GetRelationship()[0]); !ok || got != wantRel {
+		t.Errorf("source of relationship: wanted %v, got %v (ok=%t)", wantRel, got, ok)
+	}
+}
+
+func TestSourceLocationsJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.json")
+	mustWriteFile(t, path, []byte(`{
+  "entity": [
+    {"id": "one", "ekPlatform": {}},
+    {"id": "two", "ekPort": {}}
+  ],
+  "relationship": [{"a": "one", "kind": "RK_CONTAINS", "z": "two"}]
+}`))
+	f, sources := readWithSources(t, path)
+
+	want := map[string]entityrelationship.SourceLocation{
+		"one": {File: path, Line: 3, Column: 5},
+		"two": {File: path, Line: 4, Column: 5},
+	}
+	for _, e := range f.GetEntity() {
+		if got, ok := sources.Entity(e); !ok || got != want[e.GetId()] {
+			t.Errorf("source of entity %q: wanted %v, got %v (ok=%t)", e.GetId(), want[e.GetId()], got, ok)
+		}
+	}
+
+	wantRel := entityrelationship.SourceLocation{File: path, Line: 6, Column: 20}
+	if got, ok := sources.Relationship(f.GetRelationship()[0]); !ok || got != wantRel {
+		t.Errorf("source of relationship: wanted %v, got %v (ok=%t)", wantRel, got, ok)
+	}
+}
+
+func TestSourceLocationsBinaryAndStdin(t *testing.T) {
+	data, err := proto.Marshal(mustUnmarshalFragment(t, `entity { id: "binary" ek_platform{} }`))
+	if err != nil {
+		t.Fatalf("marshaling binary: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "a.binpb")
+	mustWriteFile(t, path, data)
+
+	sources := entityrelationship.NewSourceMap()
+	fr := &entityrelationship.FragmentReader{
+		Stdin:   strings.NewReader(`entity { id: "stdin" ek_port{} }`),
+		Sources: sources,
+	}
+	f, err := fr.ReadFragments([]string{path, entityrelationship.StdinPath})
+	if err != nil {
+		t.Fatalf("ReadFragments: %v", err)
+	}
+
+	want := []entityrelationship.SourceLocation{
+		{File: path},
+		{File: "<stdin>", Line: 1, Column: 1},
+	}
+	for i, e := range f.GetEntity() {
+		if got, ok := sources.Entity(e); !ok || got != want[i] {
+			t.Errorf("source of entity %q: wanted %v, got %v (ok=%t)", e.GetId(), want[i], got, ok)
+		}
+	}
+}
+
+func TestCollectionBuilderErrorsNameSources(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txtpb")
+	second := filepath.Join(dir, "second.txtpb")
+	mustWriteFile(t, first, []byte(`entity { id: "node" ek_network_node{} }
+entity { id: "interface" ek_interface{} }
+relationship { a: "node" kind: RK_CONTAINS z: "interface" }
+`))
+	mustWriteFile(t, second, []byte(`
+entity { id: "node" ek_network_node{} }
+relationship { a: "node" kind: RK_CONTAINS z: "interface" }
+relationship { a: "node" kind: RK_CONTAINS z: "missing" }
+`))
+	f, sources := readWithSources(t, first, second)
+
+	builder := entityrelationship.NewNonValidatingCollectionBuilder()
+	builder.SetSourceMap(sources)
+	err := builder.InsertFragments(f)
+	if err == nil {
+		t.Fatalf("InsertFragments: wanted errors, got nil")
+	}
+
+	for _, want := range []string{
+		"entity already exists: 'node' (at " + second + ":2:1, previously defined at " + first + ":1:1)",
+		"relationship already exists in set: 'node->RK_CONTAINS->interface' (at " + second + ":3:1, previously defined at " + first + ":3:1)",
+		"relationship references non-existent entity: 'missing' (at " + second + ":4:1)",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("InsertFragments error %q does not contain %q", err, want)
+		}
+	}
+
+	coll, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	wantLoc := entityrelationship.SourceLocation{File: first, Line: 1, Column: 1}
+	if got, ok := coll.EntitySource("node"); !ok || got != wantLoc {
+		t.Errorf("EntitySource(node): wanted %v, got %v (ok=%t)", wantLoc, got, ok)
+	}
+	if got, ok := coll.EntitySource("missing"); ok {
+		t.Errorf("EntitySource(missing): wanted no location, got %v", got)
+	}
+}
+
+type rejectingValidator struct{}
+
+func (rejectingValidator) ValidateEntity(*entityrelationship.Collection, *npb.Entity) error {
+	return errRejected
+}
+
+func (rejectingValidator) ValidateRelationship(*entityrelationship.Collection, entityrelationship.Relationship) error {
+	return errRejected
+}
+
+func (rejectingValidator) ValidateCollection(*entityrelationship.Collection) error {
+	return nil
+}
+
+var errRejected = errors.New("rejected")
+
+func TestCollectionBuilderValidatorErrorsNameSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txtpb")
+	mustWriteFile(t, path, []byte(`entity { id: "node" ek_network_node{} }
+relationship { a: "node" kind: RK_CONTAINS z: "node" }
+`))
+	f, sources := readWithSources(t, path)
+
+	builder := entityrelationship.NewCollectionBuilder(rejectingValidator{})
+	builder.SetSourceMap(sources)
+	err := builder.InsertFragments(f)
+	for _, want := range []string{path + ":1:1: rejected", path + ":2:1: rejected"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("InsertFragments error %v does not contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// Components returns the connected components of the graph: the sets of nodes joined to each other
+// by relationships, in either direction, of the given kinds, or of any kind if none are given. Each
+// component holds its nodes' IDs, sorted, and the components are ordered largest first, and then
+// by their first ID. A node with no relationships of the given kinds is a component by itself.
+// Relationships with an endpoint that isn't in the graph are ignored.
+func (g *Graph) Components(kinds ...npb.RK) [][]string {
+	components := [][]string{}
+	seen := map[string]bool{}
+	for _, id := range slices.Sorted(maps.Keys(g.nodes)) {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		component := []string{id}
+		for i := 0; i < len(component); i++ {
+			for _, neighbor := range g.neighborsVia(component[i], kinds) {
+				if !seen[neighbor] && g.nodes[neighbor] != nil {
+					seen[neighbor] = true
+					component = append(component, neighbor)
+				}
+			}
+		}
+		slices.Sort(component)
+		components = append(components, component)
+	}
+
+	slices.SortStableFunc(components, func(l, r []string) int {
+		return cmp.Compare(len(r), len(l))
+	})
+	return components
+}
+
+// neighborsVia returns the IDs of the nodes joined to the node with the given ID by relationships,
+// in either direction, of the given kinds, or of any kind if none are given.
+func (g *Graph) neighborsVia(id string, kinds []npb.RK) []string {
+	if len(kinds) == 0 {
+		return g.Neighbors(id)
+	}
+	neighbors := []string{}
+	for _, rk := range kinds {
+		for neighbor := range g.OutNeighbors(id, rk) {
+			neighbors = append(neighbors, neighbor)
+		}
+		for neighbor := range g.InNeighbors(id, rk) {
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors
+}
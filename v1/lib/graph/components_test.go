@@ -0,0 +1,76 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+func TestComponents(t *testing.T) {
+	g := New()
+	mustUpsertEntities(t, g, testGraph.entities)
+	mustAddRelationships(t, g, testGraph.relationships)
+	mustAddRelationships(t, g, []string{`a: "orphan" kind: RK_CONTAINS z: "missing"`})
+
+	for _, tc := range []struct {
+		desc  string
+		kinds []npb.RK
+		want  [][]string
+	}{
+		{
+			desc: "any kind",
+			want: [][]string{
+				{"agent", "demodulator", "interface", "modulator", "node", "port"},
+				{"orphan"},
+			},
+		},
+		{
+			desc:  "contains",
+			kinds: []npb.RK{npb.RK_RK_CONTAINS},
+			want: [][]string{
+				{"agent", "interface", "node"},
+				{"demodulator"},
+				{"modulator"},
+				{"orphan"},
+				{"port"},
+			},
+		},
+		{
+			desc:  "traverses and originates",
+			kinds: []npb.RK{npb.RK_RK_TRAVERSES, npb.RK_RK_ORIGINATES},
+			want: [][]string{
+				{"interface", "modulator", "port"},
+				{"agent"},
+				{"demodulator"},
+				{"node"},
+				{"orphan"},
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if diff := gcmp.Diff(tc.want, g.Components(tc.kinds...)); diff != "" {
+				t.Errorf("unexpected components (-want +got): %s", diff)
+			}
+		})
+	}
+
+	if got := New().Components(); len(got) != 0 {
+		t.Errorf("empty graph has components %v", got)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"errors"
+	"maps"
+	"slices"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// FromCollection returns a graph holding every entity and relationship in
+// the given collection. Entities are shared with the collection, not copied.
+func FromCollection(erColl *er.Collection) (*Graph, error) {
+	g := New()
+	errs := []error{}
+
+	for _, key := range slices.Sorted(maps.Keys(erColl.Entities)) {
+		if _, err := g.UpsertEntity(erColl.Entities[key]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, r := range erColl.Relationships() {
+		if _, err := g.AddRelationship(r.ToProto()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// ToCollection returns a collection holding every entity and relationship
+// in the graph. Entities are shared with the graph, not copied. No
+// Validator is run, but unlike a Graph, a Collection cannot hold
+// relationships whose endpoints are missing, so those are reported as
+// errors.
+func ToCollection(g *Graph) (*er.Collection, error) {
+	builder := er.NewNonValidatingCollectionBuilder()
+	if err := builder.InsertFragments(g.ToFragment()); err != nil {
+		return nil, err
+	}
+	return builder.Build()
+}
+
+// ToFragment returns a Fragment holding every entity in the graph, ordered
+// by ID, and every relationship, ordered by A, kind and Z. The entities and
+// relationships are shared with the graph, not copied.
+func (g *Graph) ToFragment() *npb.Fragment {
+	fragment := &npb.Fragment{
+		Entity: make([]*npb.Entity, 0, len(g.nodes)),
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(g.nodes)) {
+		fragment.Entity = append(fragment.Entity, g.nodes[id].GetEntity())
+	}
+	for edge := range g.AllEdges() {
+		fragment.Relationship = append(fragment.Relationship, edge.GetRelationship())
+	}
+	slices.SortFunc(fragment.Relationship, func(l, r *npb.Relationship) int {
+		return er.CompareRelationships(er.RelationshipFromProto(l), er.RelationshipFromProto(r))
+	})
+	return fragment
+}
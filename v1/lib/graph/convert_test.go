@@ -0,0 +1,102 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// Deliberately out of order, so that sorting is exercised.
+const conversionFragment = `
+entity { id: "port" ek_port{} }
+entity { id: "node" ek_network_node{} }
+entity { id: "platform" ek_platform{} labels { key: "env" value: "prod" } }
+relationship { a: "platform" kind: RK_CONTAINS z: "node" }
+relationship { a: "node" kind: RK_CONTAINS z: "port" }
+relationship { a: "node" kind: RK_ORIGINATES z: "port" }
+relationship { a: "port" kind: RK_TRAVERSES z: "port" }
+`
+
+// Relationship kinds are ordered by enum value, not name.
+const canonicalConversionFragment = `
+entity { id: "node" ek_network_node{} }
+entity { id: "platform" ek_platform{} labels { key: "env" value: "prod" } }
+entity { id: "port" ek_port{} }
+relationship { a: "node" kind: RK_ORIGINATES z: "port" }
+relationship { a: "node" kind: RK_CONTAINS z: "port" }
+relationship { a: "platform" kind: RK_CONTAINS z: "node" }
+relationship { a: "port" kind: RK_TRAVERSES z: "port" }
+`
+
+// mustBuildCollection builds a collection from a fragment without validating it. The tests of this
+// package can't use utilities/testing, which imports it.
+func mustBuildCollection(t *testing.T, txtPb string) *er.Collection {
+	fragment := &npb.Fragment{}
+	mustUnmarshal(t, txtPb, fragment)
+	builder := er.NewNonValidatingCollectionBuilder()
+	if err := builder.InsertFragments(fragment); err != nil {
+		t.Fatalf("unable to insert fragment: %v", err)
+	}
+	erColl, err := builder.Build()
+	if err != nil {
+		t.Fatalf("unable to build collection: %v", err)
+	}
+	return erColl
+}
+
+func TestCollectionGraphRoundTrip(t *testing.T) {
+	want := &npb.Fragment{}
+	mustUnmarshal(t, canonicalConversionFragment, want)
+
+	erColl := mustBuildCollection(t, conversionFragment)
+	if diff := gcmp.Diff(want, erColl.ToFragment(), protocmp.Transform()); diff != "" {
+		t.Errorf("unexpected Collection.ToFragment (-want +got): %s", diff)
+	}
+
+	g, err := FromCollection(erColl)
+	if err != nil {
+		t.Fatalf("FromCollection: %v", err)
+	}
+	if diff := gcmp.Diff(want, g.ToFragment(), protocmp.Transform()); diff != "" {
+		t.Errorf("unexpected Graph.ToFragment (-want +got): %s", diff)
+	}
+	if g.Node("platform").GetEntity() != erColl.Entities["platform"] {
+		t.Errorf("FromCollection copied the entity instead of sharing it")
+	}
+
+	roundTripped, err := ToCollection(g)
+	if err != nil {
+		t.Fatalf("ToCollection: %v", err)
+	}
+	if diff := gcmp.Diff(want, roundTripped.ToFragment(), protocmp.Transform()); diff != "" {
+		t.Errorf("unexpected round-tripped collection (-want +got): %s", diff)
+	}
+}
+
+func TestToCollectionRejectsDanglingRelationships(t *testing.T) {
+	g := New()
+	mustUpsertEntities(t, g, []string{`id: "node" ek_network_node{}`})
+	mustAddRelationships(t, g, []string{`a: "node" kind: RK_CONTAINS z: "missing"`})
+
+	if _, err := ToCollection(g); err == nil {
+		t.Errorf("ToCollection succeeded with a dangling relationship")
+	}
+}
@@ -15,9 +15,11 @@
 package graph
 
 import (
+	"errors"
 	"fmt"
 	"iter"
 	"maps"
+	"slices"
 
 	"github.com/samber/lo"
 
@@ -91,7 +93,7 @@ func (e *Edge) Same(other *Edge) bool {
 }
 
 // Graph represents a graph of NMTS entites and relationships.
-// NOTE: Graph is not thread-safe.
+// NOTE: Graph is not thread-safe; see Shared for a Graph that can be read concurrently.
 type Graph struct {
 	// Maps node ID to the node
 	nodes map[string]*Node
@@ -99,15 +101,40 @@ type Graph struct {
 	// Maps entity kind string -> node ID for all nodes of that kind -> the Node
 	nodesByKind map[string]map[string]*Node
 
+	// Maps label key -> label value -> node ID for all nodes with that label -> the Node
+	nodesByLabel map[string]map[string]map[string]*Node
+
 	// Maps node ID -> adjacent node ID -> all edges connecting them, regardless of direction
 	edges map[string]map[string][]*Edge
+
+	// Map node ID -> relationship kind -> all edges of that kind from (outEdges) or to (inEdges) it
+	outEdges map[string]map[npb.RK][]*Edge
+	inEdges  map[string]map[npb.RK][]*Edge
+
+	// Whether relationships' endpoints must be loaded; see SetIntegrity.
+	integrity Integrity
+
+	// If set, the inner index maps are shared with a snapshot until first written; see derive.
+	owned *ownership
+
+	// Notified of every change outside of an Update; see Watch.
+	watchers *watchers
+
+	// If set, changes are recorded here to be published when the enclosing Update or batch succeeds.
+	pending *[]Event
+
+	// If set, the graph as it was when the current batch began; see Begin.
+	tx *Graph
 }
 
 func New() *Graph {
 	return &Graph{
-		nodes:       map[string]*Node{},
-		nodesByKind: map[string]map[string]*Node{},
-		edges:       map[string]map[string][]*Edge{},
+		nodes:        map[string]*Node{},
+		nodesByKind:  map[string]map[string]*Node{},
+		nodesByLabel: map[string]map[string]map[string]*Node{},
+		edges:        map[string]map[string][]*Edge{},
+		outEdges:     map[string]map[npb.RK][]*Edge{},
+		inEdges:      map[string]map[npb.RK][]*Edge{},
 	}
 }
 
@@ -115,9 +142,13 @@ func New() *Graph {
 // the same Node and Edge instances as the original.
 func Clone(g *Graph) *Graph {
 	return &Graph{
-		nodes:       maps.Clone(g.nodes),
-		nodesByKind: cloneMapOfMaps(g.nodesByKind),
-		edges:       cloneMapOfMaps(g.edges),
+		nodes:        maps.Clone(g.nodes),
+		nodesByKind:  cloneMapOfMaps(g.nodesByKind),
+		nodesByLabel: cloneLabelIndex(g.nodesByLabel),
+		edges:        cloneMapOfMaps(g.edges),
+		outEdges:     cloneMapOfMaps(g.outEdges),
+		inEdges:      cloneMapOfMaps(g.inEdges),
+		integrity:    g.integrity,
 	}
 }
 
@@ -135,12 +166,20 @@ func (g *Graph) UpsertEntity(entity *npb.Entity) (*Node, error) {
 		return nil, fmt.Errorf("node for ID %s already existed and had a different EK; old EK: %s, new EK: %s", node.GetID(), node.GetKind(), newEK)
 	}
 
+	var previous *npb.Entity
+	if old := g.nodes[entity.GetId()]; old != nil {
+		g.unindexLabels(old)
+		previous = old.GetEntity()
+	}
+
 	node := &Node{
 		entity: entity,
 		kind:   newEK,
 	}
 	g.nodes[node.GetID()] = node
+	g.indexLabels(node)
 
+	g.ownKind(newEK)
 	nodesOfKind := g.nodesByKind[newEK]
 	if nodesOfKind == nil {
 		nodesOfKind = map[string]*Node{}
@@ -148,26 +187,42 @@ func (g *Graph) UpsertEntity(entity *npb.Entity) (*Node, error) {
 	nodesOfKind[node.GetID()] = node
 	g.nodesByKind[newEK] = nodesOfKind
 
+	g.publish(Event{Type: EntityUpserted, Entity: entity, Previous: previous})
 	return node, nil
 }
 
+// RemoveEntity removes the node with the given ID. What becomes of its edges depends on the graph's
+// integrity mode: by default they're left in place.
 func (g *Graph) RemoveEntity(id string) error {
 	node := g.nodes[id]
 	if node == nil {
 		return fmt.Errorf("no corresponding node found")
 	}
+	if err := g.releaseEdges(id); err != nil {
+		return err
+	}
 
 	delete(g.nodes, id)
+	g.unindexLabels(node)
 
+	g.ownKind(node.GetKind())
 	nodesOfKind := g.nodesByKind[node.GetKind()]
 	delete(nodesOfKind, node.GetID())
 	if len(nodesOfKind) == 0 {
 		delete(g.nodesByKind, node.GetKind())
 	}
+
+	g.publish(Event{Type: EntityRemoved, Entity: node.GetEntity()})
 	return nil
 }
 
+// AddRelationship adds the given relationship to the graph. It fails if the graph already contains
+// an edge representing the same relationship, or if the graph's integrity mode requires the
+// relationship's endpoints to be loaded and they aren't.
 func (g *Graph) AddRelationship(relationship *npb.Relationship) (*Edge, error) {
+	if !g.hasEndpoints(relationship) {
+		return nil, errors.Join(g.missingEndpoints(er.RelationshipFromProto(relationship))...)
+	}
 	edge, added := g.TryAddRelationship(relationship)
 	if !added {
 		return nil, fmt.Errorf(
@@ -187,6 +242,7 @@ func (g *Graph) RemoveRelationship(relationship *npb.Relationship) error {
 
 	removedAnEdge := false
 	removeMappingToEdge := func(x, y string) {
+		g.ownEdges(x)
 		edgesByNeighbor := g.edges[x]
 		if edgesByNeighbor == nil {
 			return
@@ -214,6 +270,10 @@ func (g *Graph) RemoveRelationship(relationship *npb.Relationship) error {
 	if !removedAnEdge {
 		return fmt.Errorf("no corresponding edge found")
 	}
+	removeDirectedEdge(g.outEdges, edgeToRemove.GetA(), edgeToRemove)
+	removeDirectedEdge(g.inEdges, edgeToRemove.GetZ(), edgeToRemove)
+
+	g.publish(Event{Type: RelationshipRemoved, Relationship: er.RelationshipFromProto(relationship)})
 	return nil
 }
 
@@ -229,11 +289,15 @@ func (g *Graph) Neighbors(id string) []string {
 
 // TryAddRelationship adds the given relationship to the graph. It returns the new edge and true if
 // the relationship was added, or nil and false if the graph already contained an edge representing
-// the same relationship (as determined by Edge.Same). Unlike AddRelationship, the duplicate path
+// the same relationship (as determined by Edge.Same) or the graph's integrity mode requires the
+// relationship's endpoints to be loaded and they aren't. Unlike AddRelationship, the duplicate path
 // performs no allocations, making it suitable for bulk loads where duplicates are expected.
 func (g *Graph) TryAddRelationship(relationship *npb.Relationship) (*Edge, bool) {
 	// The probe stays a stack value so the duplicate path allocates nothing.
 	probe := Edge{relationship: relationship}
+	if !g.hasEndpoints(relationship) {
+		return nil, false
+	}
 	for _, existing := range g.Edges(probe.GetA(), probe.GetZ()) {
 		if probe.Same(existing) {
 			return nil, false
@@ -244,14 +308,13 @@ func (g *Graph) TryAddRelationship(relationship *npb.Relationship) (*Edge, bool)
 	}
 
 	addMappingToEdge := func(x, y string) {
+		g.ownEdges(x)
 		edgesByNeighbor := g.edges[x]
 		if edgesByNeighbor == nil {
 			edgesByNeighbor = map[string][]*Edge{}
 		}
-		edgesToY := edgesByNeighbor[y]
-		if edgesToY == nil {
-			edgesToY = []*Edge{}
-		}
+		// Clipping makes append copy, so the slice may be shared with clones and snapshots.
+		edgesToY := slices.Clip(edgesByNeighbor[y])
 		edgesToY = append(edgesToY, edge)
 		edgesByNeighbor[y] = edgesToY
 		g.edges[x] = edgesByNeighbor
@@ -261,7 +324,10 @@ func (g *Graph) TryAddRelationship(relationship *npb.Relationship) (*Edge, bool)
 		// Self-loops live in a single (A, A) slot; mirroring would store them twice.
 		addMappingToEdge(edge.GetZ(), edge.GetA())
 	}
+	addDirectedEdge(g.outEdges, edge.GetA(), edge)
+	addDirectedEdge(g.inEdges, edge.GetZ(), edge)
 
+	g.publish(Event{Type: RelationshipAdded, Relationship: er.RelationshipFromProto(relationship)})
 	return edge, true
 }
 
@@ -281,6 +347,39 @@ func (g *Graph) AllNeighbors(id string) iter.Seq2[string, []*Edge] {
 	}
 }
 
+// OutNeighbors returns an iterator over the edges of the given kind from the node with the given
+// ID, each paired with the ID of the node it leads to, in the order they were added. If rk is
+// RK_UNSPECIFIED, edges of every kind are yielded, ordered by kind. The graph must not be modified
+// during iteration.
+//
+// NOTE: Like Neighbors, this is based entirely on the relationships that have been loaded into the
+// graph; the yielded IDs may not correspond to actual graph nodes.
+func (g *Graph) OutNeighbors(id string, rk npb.RK) iter.Seq2[string, *Edge] {
+	return directedNeighbors(g.outEdges[id], rk, (*Edge).GetZ)
+}
+
+// InNeighbors is like OutNeighbors, but yields the edges of the given kind to the node with the
+// given ID, each paired with the ID of the node it comes from.
+func (g *Graph) InNeighbors(id string, rk npb.RK) iter.Seq2[string, *Edge] {
+	return directedNeighbors(g.inEdges[id], rk, (*Edge).GetA)
+}
+
+func directedNeighbors(edgesByKind map[npb.RK][]*Edge, rk npb.RK, neighbor func(*Edge) string) iter.Seq2[string, *Edge] {
+	return func(yield func(string, *Edge) bool) {
+		kinds := []npb.RK{rk}
+		if rk == npb.RK_RK_UNSPECIFIED {
+			kinds = slices.Sorted(maps.Keys(edgesByKind))
+		}
+		for _, kind := range kinds {
+			for _, edge := range edgesByKind[kind] {
+				if !yield(neighbor(edge), edge) {
+					return
+				}
+			}
+		}
+	}
+}
+
 // AllNodesOfKind returns an iterator over all nodes of the given kind. The graph must not be
 // modified during iteration.
 func (g *Graph) AllNodesOfKind(ek string) iter.Seq[*Node] {
@@ -324,6 +423,47 @@ func (g *Graph) Edges(x, y string) []*Edge {
 	return xEdgesByNeighbor[y]
 }
 
+func (g *Graph) indexLabels(node *Node) {
+	for key, value := range node.GetEntity().GetLabels() {
+		g.ownLabel(key)
+		nodesByValue := g.nodesByLabel[key]
+		if nodesByValue == nil {
+			nodesByValue = map[string]map[string]*Node{}
+			g.nodesByLabel[key] = nodesByValue
+		}
+		nodesWithLabel := nodesByValue[value]
+		if nodesWithLabel == nil {
+			nodesWithLabel = map[string]*Node{}
+			nodesByValue[value] = nodesWithLabel
+		}
+		nodesWithLabel[node.GetID()] = node
+	}
+}
+
+func (g *Graph) unindexLabels(node *Node) {
+	for key, value := range node.GetEntity().GetLabels() {
+		g.ownLabel(key)
+		nodesByValue := g.nodesByLabel[key]
+		nodesWithLabel := nodesByValue[value]
+		delete(nodesWithLabel, node.GetID())
+		if len(nodesWithLabel) == 0 {
+			delete(nodesByValue, value)
+		}
+		if len(nodesByValue) == 0 {
+			delete(g.nodesByLabel, key)
+		}
+	}
+}
+
+// cloneLabelIndex is cloneMapOfMaps for the three-level label index.
+func cloneLabelIndex(m map[string]map[string]map[string]*Node) map[string]map[string]map[string]*Node {
+	clone := make(map[string]map[string]map[string]*Node, len(m))
+	for k, v := range m {
+		clone[k] = cloneMapOfMaps(v)
+	}
+	return clone
+}
+
 // cloneMapOfMaps creates a shallow-ish copy of a map of maps. All returned maps
 // are created new, but all map keys and values are set using ordinary
 // assignment.
@@ -334,3 +474,30 @@ func cloneMapOfMaps[A comparable, B comparable, C any](m map[A]map[B]C) map[A]ma
 	}
 	return clone
 }
+
+// addDirectedEdge adds the edge to the node with ID x in the given directed index.
+func addDirectedEdge(index map[string]map[npb.RK][]*Edge, x string, edge *Edge) {
+	edgesByKind := index[x]
+	if edgesByKind == nil {
+		edgesByKind = map[npb.RK][]*Edge{}
+		index[x] = edgesByKind
+	}
+	// As in TryAddRelationship, clipping makes append copy.
+	edgesByKind[edge.GetKind()] = append(slices.Clip(edgesByKind[edge.GetKind()]), edge)
+}
+
+// removeDirectedEdge removes the edge from the node with ID x in the given directed index.
+func removeDirectedEdge(index map[string]map[npb.RK][]*Edge, x string, edge *Edge) {
+	edgesByKind := index[x]
+	kept := lo.Filter(edgesByKind[edge.GetKind()], func(e *Edge, _ int) bool {
+		return !edge.Same(e)
+	})
+	if len(kept) > 0 {
+		edgesByKind[edge.GetKind()] = kept
+		return
+	}
+	delete(edgesByKind, edge.GetKind())
+	if len(edgesByKind) == 0 {
+		delete(index, x)
+	}
+}
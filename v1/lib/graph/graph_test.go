@@ -16,6 +16,7 @@ package graph
 
 import (
 	"cmp"
+	"iter"
 	"slices"
 	"testing"
 
@@ -884,6 +885,131 @@ func TestAllNeighbors(t *testing.T) {
 	}
 }
 
+type directedNeighborsTestCase struct {
+	desc string
+	graphEntities
+	relationshipRemovals []string
+	id                   string
+	rk                   npb.RK
+	wantOut, wantIn      []string
+}
+
+// collectDirected returns the yielded neighbors as "neighbor|RK" strings, after checking each edge
+// leads to or from id as appropriate.
+func collectDirected(t *testing.T, id string, seq iter.Seq2[string, *Edge], out bool) []string {
+	got := []string{}
+	for neighbor, edge := range seq {
+		self, other := edge.GetA(), edge.GetZ()
+		if !out {
+			self, other = other, self
+		}
+		if self != id || neighbor != other {
+			t.Errorf("unexpected neighbor %s of %s for edge %s", neighbor, id, edgeKey(edge))
+		}
+		got = append(got, neighbor+"|"+edge.GetKind().String())
+	}
+	return got
+}
+
+func (tc *directedNeighborsTestCase) Run(t *testing.T) {
+	g := New()
+	mustUpsertEntities(t, g, tc.entities)
+	mustAddRelationships(t, g, tc.relationships)
+	mustRemoveRelationships(t, g, tc.relationshipRemovals)
+
+	if diff := gcmp.Diff(tc.wantOut, collectDirected(t, tc.id, g.OutNeighbors(tc.id, tc.rk), true)); diff != "" {
+		t.Errorf("OutNeighbors(%s, %v) yielded unexpected neighbors (-want +got): %s", tc.id, tc.rk, diff)
+	}
+	if diff := gcmp.Diff(tc.wantIn, collectDirected(t, tc.id, g.InNeighbors(tc.id, tc.rk), false)); diff != "" {
+		t.Errorf("InNeighbors(%s, %v) yielded unexpected neighbors (-want +got): %s", tc.id, tc.rk, diff)
+	}
+}
+
+var directedNeighborsTestCases = []directedNeighborsTestCase{
+	{
+		desc:          "single kind",
+		graphEntities: testGraph,
+		id:            "node",
+		rk:            npb.RK_RK_CONTAINS,
+		wantOut:       []string{"agent|RK_CONTAINS", "interface|RK_CONTAINS"},
+		wantIn:        []string{},
+	},
+	{
+		desc:          "every kind",
+		graphEntities: testGraph,
+		id:            "node",
+		rk:            npb.RK_RK_UNSPECIFIED,
+		wantOut:       []string{"agent|RK_CONTAINS", "interface|RK_CONTAINS"},
+		wantIn:        []string{"agent|RK_CONTROLS"},
+	},
+	{
+		desc:          "every kind ordered by kind",
+		graphEntities: testGraph,
+		id:            "port",
+		rk:            npb.RK_RK_UNSPECIFIED,
+		wantOut:       []string{"modulator|RK_ORIGINATES", "demodulator|RK_TERMINATES"},
+		wantIn:        []string{"interface|RK_TRAVERSES"},
+	},
+	{
+		desc:          "absent node",
+		graphEntities: testGraph,
+		id:            "doesnt_exist",
+		rk:            npb.RK_RK_UNSPECIFIED,
+		wantOut:       []string{},
+		wantIn:        []string{},
+	},
+	{
+		desc:                 "after removal",
+		graphEntities:        testGraph,
+		relationshipRemovals: []string{`a: "node" kind: RK_CONTAINS z: "agent"`, `a: "agent" kind: RK_CONTROLS z: "node"`},
+		id:                   "node",
+		rk:                   npb.RK_RK_UNSPECIFIED,
+		wantOut:              []string{"interface|RK_CONTAINS"},
+		wantIn:               []string{},
+	},
+	{
+		desc: "self-loop",
+		graphEntities: graphEntities{
+			entities:      []string{`id: "port" ek_port{}`},
+			relationships: []string{`a: "port" kind: RK_TRAVERSES z: "port"`},
+		},
+		id:      "port",
+		rk:      npb.RK_RK_TRAVERSES,
+		wantOut: []string{"port|RK_TRAVERSES"},
+		wantIn:  []string{"port|RK_TRAVERSES"},
+	},
+}
+
+func TestDirectedNeighbors(t *testing.T) {
+	for _, tc := range directedNeighborsTestCases {
+		t.Run(tc.desc, tc.Run)
+	}
+}
+
+func TestDirectedNeighborsOfCloneAndSnapshot(t *testing.T) {
+	g := New()
+	mustUpsertEntities(t, g, testGraph.entities)
+	mustAddRelationships(t, g, testGraph.relationships)
+	s := NewShared(Clone(g))
+
+	mustAddRelationships(t, g, []string{`a: "node" kind: RK_CONTAINS z: "port"`})
+	if err := s.Update(func(g *Graph) error {
+		return g.RemoveRelationship(mustUnmarshalRelationship(t, `a: "node" kind: RK_CONTAINS z: "interface"`))
+	}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	want := map[*Graph][]string{
+		g:            {"agent|RK_CONTAINS", "interface|RK_CONTAINS", "port|RK_CONTAINS"},
+		s.Snapshot(): {"agent|RK_CONTAINS"},
+	}
+	for g, wantOut := range want {
+		if diff := gcmp.Diff(wantOut, collectDirected(t, "node", g.OutNeighbors("node", npb.RK_RK_CONTAINS), true)); diff != "" {
+			t.Errorf("OutNeighbors(node, RK_CONTAINS) yielded unexpected neighbors (-want +got): %s", diff)
+		}
+	}
+}
+
 func TestAllNodesOfKind(t *testing.T) {
 	g := New()
 	mustUpsertEntities(t, g, testGraph.entities)
@@ -0,0 +1,132 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// Integrity determines whether a graph may hold relationships whose endpoints aren't loaded.
+type Integrity int
+
+const (
+	// IntegrityNone, the default, lets entities be removed while they have relationships, and
+	// relationships be added between entities that aren't loaded, as when a graph is built from
+	// fragments in no particular order.
+	IntegrityNone Integrity = iota
+
+	// IntegrityCascade removes an entity's relationships along with it, and rejects relationships
+	// between entities that aren't loaded.
+	IntegrityCascade
+
+	// IntegrityStrict refuses to remove an entity while it has relationships, and rejects
+	// relationships between entities that aren't loaded.
+	IntegrityStrict
+)
+
+func (i Integrity) String() string {
+	switch i {
+	case IntegrityNone:
+		return "IntegrityNone"
+	case IntegrityCascade:
+		return "IntegrityCascade"
+	case IntegrityStrict:
+		return "IntegrityStrict"
+	}
+	return "Integrity(unknown)"
+}
+
+// Integrity returns the graph's integrity mode.
+func (g *Graph) Integrity() Integrity {
+	return g.integrity
+}
+
+// SetIntegrity sets the graph's integrity mode, which applies to subsequent changes only; use
+// CheckIntegrity to find any relationships the graph already holds whose endpoints aren't loaded.
+func (g *Graph) SetIntegrity(integrity Integrity) {
+	g.integrity = integrity
+}
+
+// CheckIntegrity returns an er.DanglingEndpointError for each end of each relationship in the graph
+// whose entity isn't loaded, ordered by relationship, or nil if there are none.
+func (g *Graph) CheckIntegrity() error {
+	relationships := []er.Relationship{}
+	for edge := range g.AllEdges() {
+		relationships = append(relationships, er.RelationshipFromProto(edge.GetRelationship()))
+	}
+	slices.SortFunc(relationships, er.CompareRelationships)
+
+	errs := []error{}
+	for _, r := range relationships {
+		errs = append(errs, g.missingEndpoints(r)...)
+	}
+	return errors.Join(errs...)
+}
+
+// missingEndpoints returns an er.DanglingEndpointError for each end of r that isn't in the graph.
+func (g *Graph) missingEndpoints(r er.Relationship) []error {
+	errs := []error{}
+	if g.Node(r.A) == nil {
+		errs = append(errs, &er.DanglingEndpointError{Relationship: r, Endpoint: er.EndpointA})
+	}
+	if g.Node(r.Z) == nil {
+		errs = append(errs, &er.DanglingEndpointError{Relationship: r, Endpoint: er.EndpointZ})
+	}
+	return errs
+}
+
+// hasEndpoints returns whether the relationship may be added under the graph's integrity mode.
+func (g *Graph) hasEndpoints(relationship *npb.Relationship) bool {
+	if g.integrity == IntegrityNone {
+		return true
+	}
+	return g.nodes[relationship.GetA()] != nil && g.nodes[relationship.GetZ()] != nil
+}
+
+// releaseEdges prepares the node with the given ID for removal under the graph's integrity mode.
+func (g *Graph) releaseEdges(id string) error {
+	switch g.integrity {
+	case IntegrityStrict:
+		if neighbors := len(g.edges[id]); neighbors > 0 {
+			return stillReferencedError(id, neighbors)
+		}
+	case IntegrityCascade:
+		incident := []*npb.Relationship{}
+		for _, edges := range g.AllNeighbors(id) {
+			for _, edge := range edges {
+				incident = append(incident, edge.GetRelationship())
+			}
+		}
+		// Sorted so that watchers see the removals in a predictable order.
+		slices.SortFunc(incident, func(l, r *npb.Relationship) int {
+			return er.CompareRelationships(er.RelationshipFromProto(l), er.RelationshipFromProto(r))
+		})
+		for _, relationship := range incident {
+			if err := g.RemoveRelationship(relationship); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func stillReferencedError(id string, neighbors int) error {
+	return fmt.Errorf("cannot remove entity '%v' while it still has relationships with %d entities", id, neighbors)
+}
@@ -0,0 +1,211 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+)
+
+type integrityTestCase struct {
+	desc      string
+	integrity Integrity
+	remove    string
+	wantErr   string
+	// Relationships left after the removal, as "A|RK|Z" strings.
+	wantRelationships []string
+	wantEvents        []string
+}
+
+func (tc *integrityTestCase) Run(t *testing.T) {
+	g := New()
+	g.SetIntegrity(tc.integrity)
+	mustUpsertEntities(t, g, testGraph.entities)
+	mustAddRelationships(t, g, testGraph.relationships)
+	batches := [][]Event{}
+	g.Watch(Filter{}, func(events []Event) {
+		batches = append(batches, events)
+	})
+
+	err := g.RemoveEntity(tc.remove)
+	if tc.wantErr == "" && err != nil {
+		t.Fatalf("RemoveEntity(%s): %v", tc.remove, err)
+	}
+	if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+		t.Errorf("RemoveEntity(%s) returned error %v; want error containing %q", tc.remove, err, tc.wantErr)
+	}
+
+	gotRelationships := []string{}
+	for _, r := range g.ToFragment().GetRelationship() {
+		gotRelationships = append(gotRelationships, edgeKey(&Edge{relationship: r}))
+	}
+	if diff := gcmp.Diff(tc.wantRelationships, gotRelationships); diff != "" {
+		t.Errorf("unexpected relationships after RemoveEntity (-want +got): %s", diff)
+	}
+	if diff := gcmp.Diff(tc.wantEvents, describeBatches(batches)); diff != "" {
+		t.Errorf("unexpected events (-want +got): %s", diff)
+	}
+}
+
+var integrityTestCases = []integrityTestCase{
+	{
+		desc:      "none leaves edges",
+		integrity: IntegrityNone,
+		remove:    "port",
+		wantRelationships: []string{
+			"agent|RK_CONTROLS|node",
+			"interface|RK_TRAVERSES|port",
+			"node|RK_CONTAINS|agent",
+			"node|RK_CONTAINS|interface",
+			"port|RK_ORIGINATES|modulator",
+			"port|RK_TERMINATES|demodulator",
+		},
+		wantEvents: []string{"EntityRemoved port"},
+	},
+	{
+		desc:      "cascade removes edges",
+		integrity: IntegrityCascade,
+		remove:    "port",
+		wantRelationships: []string{
+			"agent|RK_CONTROLS|node",
+			"node|RK_CONTAINS|agent",
+			"node|RK_CONTAINS|interface",
+		},
+		wantEvents: []string{
+			"RelationshipRemoved interface->RK_TRAVERSES->port",
+			"RelationshipRemoved port->RK_ORIGINATES->modulator",
+			"RelationshipRemoved port->RK_TERMINATES->demodulator",
+			"EntityRemoved port",
+		},
+	},
+	{
+		desc:      "strict refuses",
+		integrity: IntegrityStrict,
+		remove:    "port",
+		wantErr:   "cannot remove entity 'port' while it still has relationships with 3 entities",
+		wantRelationships: []string{
+			"agent|RK_CONTROLS|node",
+			"interface|RK_TRAVERSES|port",
+			"node|RK_CONTAINS|agent",
+			"node|RK_CONTAINS|interface",
+			"port|RK_ORIGINATES|modulator",
+			"port|RK_TERMINATES|demodulator",
+		},
+		wantEvents: []string{},
+	},
+	{
+		desc:      "strict removes entity without edges",
+		integrity: IntegrityStrict,
+		remove:    "orphan",
+		wantRelationships: []string{
+			"agent|RK_CONTROLS|node",
+			"interface|RK_TRAVERSES|port",
+			"node|RK_CONTAINS|agent",
+			"node|RK_CONTAINS|interface",
+			"port|RK_ORIGINATES|modulator",
+			"port|RK_TERMINATES|demodulator",
+		},
+		wantEvents: []string{"EntityRemoved orphan"},
+	},
+}
+
+func TestIntegrityRemoveEntity(t *testing.T) {
+	for _, tc := range integrityTestCases {
+		t.Run(tc.desc, tc.Run)
+	}
+}
+
+func TestIntegrityAddRelationship(t *testing.T) {
+	for _, integrity := range []Integrity{IntegrityCascade, IntegrityStrict} {
+		t.Run(integrity.String(), func(t *testing.T) {
+			g := New()
+			g.SetIntegrity(integrity)
+			mustUpsertEntities(t, g, []string{`id: "node" ek_network_node{}`})
+			r := mustUnmarshalRelationship(t, `a: "node" kind: RK_CONTAINS z: "missing"`)
+
+			_, err := g.AddRelationship(r)
+			var dangling *er.DanglingEndpointError
+			if !errors.As(err, &dangling) || dangling.ID() != "missing" || dangling.Endpoint != er.EndpointZ {
+				t.Errorf("AddRelationship returned %v; want a DanglingEndpointError for Z 'missing'", err)
+			}
+			if edge, added := g.TryAddRelationship(r); added || edge != nil {
+				t.Errorf("TryAddRelationship added a relationship with a missing endpoint")
+			}
+			if got := g.Neighbors("node"); len(got) != 0 {
+				t.Errorf("unexpected neighbors of node: %v", got)
+			}
+		})
+	}
+}
+
+func TestIntegrityIsKeptByCloneAndUpdate(t *testing.T) {
+	g := New()
+	g.SetIntegrity(IntegrityStrict)
+	if got := Clone(g).Integrity(); got != IntegrityStrict {
+		t.Errorf("Clone has integrity %v; want IntegrityStrict", got)
+	}
+	g.Update(func(next *Graph) error {
+		if got := next.Integrity(); got != IntegrityStrict {
+			t.Errorf("Update's graph has integrity %v; want IntegrityStrict", got)
+		}
+		return nil
+	})
+	NewShared(g).Update(func(next *Graph) error {
+		if got := next.Integrity(); got != IntegrityStrict {
+			t.Errorf("Shared.Update's graph has integrity %v; want IntegrityStrict", got)
+		}
+		return nil
+	})
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	g := New()
+	mustUpsertEntities(t, g, testGraph.entities)
+	mustAddRelationships(t, g, testGraph.relationships)
+	if err := g.CheckIntegrity(); err != nil {
+		t.Errorf("CheckIntegrity of a complete graph: %v", err)
+	}
+
+	mustAddRelationships(t, g, []string{`a: "ghost" kind: RK_CONTROLS z: "phantom"`})
+	mustRemoveEntities(t, g, []string{"port"})
+
+	err := g.CheckIntegrity()
+	if err == nil {
+		t.Fatalf("CheckIntegrity succeeded for a graph with dangling relationships")
+	}
+	got := []string{}
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var dangling *er.DanglingEndpointError
+		if !errors.As(err, &dangling) {
+			t.Fatalf("error %v is not a DanglingEndpointError", err)
+		}
+		got = append(got, dangling.Relationship.String()+" "+dangling.Endpoint.String())
+	}
+	want := []string{
+		"ghost->RK_CONTROLS->phantom A",
+		"ghost->RK_CONTROLS->phantom Z",
+		"interface->RK_TRAVERSES->port Z",
+		"port->RK_ORIGINATES->modulator A",
+		"port->RK_TERMINATES->demodulator A",
+	}
+	if diff := gcmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected dangling endpoints (-want +got): %s", diff)
+	}
+}
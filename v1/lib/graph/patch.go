@@ -0,0 +1,141 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"errors"
+	"fmt"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// Validator checks entities and relationships as they're added to a graph,
+// e.g. validation.DefaultGraphValidator.
+type Validator interface {
+	ValidateEntity(*Graph, *npb.Entity) error
+	ValidateRelationship(*Graph, er.Relationship) error
+}
+
+// Apply applies the patch to the graph, in the order documented on the Patch message. If v is
+// not nil, added and replaced entities, added relationships, and the relationships incident to
+// replaced entities are checked with it. If any change cannot be applied or any check fails,
+// every error is returned and the graph is left unchanged. Like any Update, a patch is published
+// to watchers as a single batch.
+func (g *Graph) Apply(patch *npb.Patch, v Validator) error {
+	return g.Update(func(patched *Graph) error {
+		return patched.applyPatch(patch, v)
+	})
+}
+
+func (g *Graph) applyPatch(patch *npb.Patch, v Validator) error {
+	errs := []error{}
+	validateEntity := func(entity *npb.Entity) error {
+		if v == nil {
+			return nil
+		}
+		return v.ValidateEntity(g, entity)
+	}
+	validateRelationship := func(r er.Relationship) error {
+		if v == nil {
+			return nil
+		}
+		return v.ValidateRelationship(g, r)
+	}
+
+	for _, rel := range patch.GetRemoveRelationship() {
+		if err := g.RemoveRelationship(rel); err != nil {
+			errs = append(errs, fmt.Errorf("cannot remove relationship '%v': %w", describeRelationship(rel), err))
+		}
+	}
+
+	for _, id := range patch.GetRemoveEntity() {
+		if neighbors := g.Neighbors(id); len(neighbors) > 0 {
+			errs = append(errs, stillReferencedError(id, len(neighbors)))
+			continue
+		}
+		if err := g.RemoveEntity(id); err != nil {
+			errs = append(errs, fmt.Errorf("cannot remove entity '%v': %w", id, err))
+		}
+	}
+
+	replaced := []string{}
+	for _, entity := range patch.GetReplaceEntity() {
+		id := entity.GetId()
+		if g.Node(id) == nil {
+			errs = append(errs, fmt.Errorf("cannot replace entity that does not exist: '%v'", id))
+			continue
+		}
+		if err := validateEntity(entity); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := g.UpsertEntity(entity); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		replaced = append(replaced, id)
+	}
+
+	for _, entity := range patch.GetAddEntity() {
+		if g.Node(entity.GetId()) != nil {
+			errs = append(errs, fmt.Errorf("cannot add entity that already exists: '%v'", entity.GetId()))
+			continue
+		}
+		if err := validateEntity(entity); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := g.UpsertEntity(entity); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	checked := map[er.Relationship]struct{}{}
+	for _, rel := range patch.GetAddRelationship() {
+		r := er.RelationshipFromProto(rel)
+		if missing := g.missingEndpoints(r); len(missing) > 0 {
+			errs = append(errs, missing...)
+			continue
+		}
+		if _, err := g.AddRelationship(rel); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		checked[r] = struct{}{}
+		errs = append(errs, validateRelationship(r))
+	}
+
+	// The relationships of a replaced entity are re-checked against its new definition, unless
+	// they were already checked.
+	for _, id := range replaced {
+		for _, edges := range g.AllNeighbors(id) {
+			for _, edge := range edges {
+				r := er.RelationshipFromProto(edge.GetRelationship())
+				if _, exists := checked[r]; !exists {
+					checked[r] = struct{}{}
+					errs = append(errs, validateRelationship(r))
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func describeRelationship(rel *npb.Relationship) string {
+	r := er.RelationshipFromProto(rel)
+	return r.String()
+}
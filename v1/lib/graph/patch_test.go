@@ -0,0 +1,167 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+var errRejected = errors.New("rejected")
+
+// rejectingValidator rejects entities labelled "bad" and RK_CONTROLS
+// relationships.
+type rejectingValidator struct{}
+
+func (rejectingValidator) ValidateEntity(_ *Graph, e *npb.Entity) error {
+	if _, bad := e.GetLabels()["bad"]; bad {
+		return errRejected
+	}
+	return nil
+}
+
+func (rejectingValidator) ValidateRelationship(_ *Graph, r er.Relationship) error {
+	if r.Kind == npb.RK_RK_CONTROLS {
+		return errRejected
+	}
+	return nil
+}
+
+type applyTestCase struct {
+	desc    string
+	patch   string
+	want    string
+	wantErr []string
+}
+
+func (tc *applyTestCase) Run(t *testing.T) {
+	g, err := FromCollection(mustBuildCollection(t, conversionFragment))
+	if err != nil {
+		t.Fatalf("FromCollection: %v", err)
+	}
+	before := g.ToFragment()
+	patch := &npb.Patch{}
+	mustUnmarshal(t, tc.patch, patch)
+
+	err = g.Apply(patch, rejectingValidator{})
+	if len(tc.wantErr) == 0 && err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	for _, sub := range tc.wantErr {
+		if err == nil || !strings.Contains(err.Error(), sub) {
+			t.Errorf("error %v does not mention %q", err, sub)
+		}
+	}
+
+	want := before
+	if tc.want != "" {
+		want = &npb.Fragment{}
+		mustUnmarshal(t, tc.want, want)
+	}
+	if diff := gcmp.Diff(want, g.ToFragment(), protocmp.Transform()); diff != "" {
+		t.Errorf("unexpected graph after Apply (-want +got): %s", diff)
+	}
+}
+
+var applyTestCases = []applyTestCase{
+	{
+		desc: "changes applied in order",
+		patch: `
+remove_relationship { a: "node" kind: RK_CONTAINS z: "port" }
+remove_relationship { a: "node" kind: RK_ORIGINATES z: "port" }
+remove_relationship { a: "port" kind: RK_TRAVERSES z: "port" }
+remove_entity: "port"
+replace_entity { id: "platform" ek_platform{} }
+add_entity { id: "port" ek_interface{} }
+add_relationship { a: "node" kind: RK_CONTAINS z: "port" }
+`,
+		want: `
+entity { id: "node" ek_network_node{} }
+entity { id: "platform" ek_platform{} }
+entity { id: "port" ek_interface{} }
+relationship { a: "node" kind: RK_CONTAINS z: "port" }
+relationship { a: "platform" kind: RK_CONTAINS z: "node" }
+`,
+	},
+	{
+		desc:    "referenced entity",
+		patch:   `remove_entity: "port"`,
+		wantErr: []string{"cannot remove entity 'port' while it still has relationships"},
+	},
+	{
+		desc: "missing entities and relationships",
+		patch: `
+remove_relationship { a: "platform" kind: RK_CONTAINS z: "port" }
+replace_entity { id: "missing" ek_port{} }
+add_entity { id: "node" ek_network_node{} }
+add_relationship { a: "node" kind: RK_CONTAINS z: "missing" }
+`,
+		wantErr: []string{
+			"cannot remove relationship 'platform->RK_CONTAINS->port'",
+			"cannot replace entity that does not exist: 'missing'",
+			"cannot add entity that already exists: 'node'",
+			"relationship references non-existent entity: 'missing'",
+		},
+	},
+	{
+		desc:    "replacement changes kind",
+		patch:   `replace_entity { id: "port" ek_interface{} }`,
+		wantErr: []string{"different EK"},
+	},
+	{
+		desc: "rejected by validator after partial success",
+		patch: `
+add_entity { id: "agent" ek_sdn_agent{} }
+add_entity { id: "bad" labels { key: "bad" value: "" } ek_port{} }
+add_relationship { a: "agent" kind: RK_CONTROLS z: "node" }
+`,
+		wantErr: []string{errRejected.Error()},
+	},
+}
+
+func TestApply(t *testing.T) {
+	for _, tc := range applyTestCases {
+		t.Run(tc.desc, tc.Run)
+	}
+}
+
+func TestApplyLeavesLabelIndexUnchangedOnFailure(t *testing.T) {
+	g, err := FromCollection(mustBuildCollection(t, conversionFragment))
+	if err != nil {
+		t.Fatalf("FromCollection: %v", err)
+	}
+	patch := &npb.Patch{}
+	mustUnmarshal(t, `
+replace_entity { id: "platform" ek_platform{} labels { key: "env" value: "dev" } }
+remove_entity: "missing"
+`, patch)
+
+	if err := g.Apply(patch, nil); err == nil {
+		t.Fatalf("Apply succeeded")
+	}
+	if got := len(g.NodesWithLabel("env", "prod")); got != 1 {
+		t.Errorf("wanted 1 node labelled env=prod after failed Apply, got %d", got)
+	}
+	if got := len(g.NodesWithLabel("env", "dev")); got != 0 {
+		t.Errorf("wanted no nodes labelled env=dev after failed Apply, got %d", got)
+	}
+}
@@ -0,0 +1,540 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"cmp"
+	"container/heap"
+	"slices"
+	"strings"
+
+	set "github.com/deckarep/golang-set/v2"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+)
+
+// WeightFunc returns the cost of traversing the given edge from the given node. Costs must not be
+// negative.
+type WeightFunc func(g *Graph, fromNodeID string, edge *Edge) float64
+
+// HopCount weighs every edge equally, so that the least-cost path is the one with fewest edges.
+func HopCount(*Graph, string, *Edge) float64 {
+	return 1
+}
+
+// Latency weighs an edge by the one-way latency, in seconds, of the EK_LOGICAL_PACKET_LINK or
+// EK_PHYSICAL_MEDIUM_LINK it leads to, so that each link on a path adds its latency once. Edges
+// leading to any other node, and to links without a latency, cost nothing.
+func Latency(g *Graph, fromNodeID string, edge *Edge) float64 {
+	entity := g.Node(otherEnd(edge, fromNodeID)).GetEntity()
+	if link := entity.GetEkLogicalPacketLink(); link != nil {
+		return link.GetLatency().AsDuration().Seconds()
+	}
+	if link := entity.GetEkPhysicalMediumLink(); link != nil {
+		return link.GetLatency().AsDuration().Seconds()
+	}
+	return 0
+}
+
+// Path is a path through a graph.
+type Path struct {
+	// Steps holds the steps of the path, starting with the node it starts from, as returned by
+	// BreadthFirst.PathTo.
+	Steps []Step
+
+	// Cost is the sum of the weights of the path's edges.
+	Cost float64
+}
+
+// IDs returns the IDs of the nodes along the path, in order.
+func (p Path) IDs() []string {
+	ids := make([]string, len(p.Steps))
+	for i, step := range p.Steps {
+		ids[i] = step.ID
+	}
+	return ids
+}
+
+// Relationships returns the relationships the path traverses, in order; none for the zero Path.
+func (p Path) Relationships() []er.Relationship {
+	relationships := []er.Relationship{}
+	if len(p.Steps) == 0 {
+		return relationships
+	}
+	for _, step := range p.Steps[1:] {
+		relationships = append(relationships, er.RelationshipFromProto(step.Via.GetRelationship()))
+	}
+	return relationships
+}
+
+// Disjointness is what the paths of a disjoint pair may not share.
+type Disjointness int
+
+const (
+	// NodeDisjoint paths share no nodes other than those they start and end at.
+	NodeDisjoint Disjointness = iota
+	// LinkDisjoint paths share no edges, i.e. relationships.
+	LinkDisjoint
+)
+
+func (d Disjointness) String() string {
+	switch d {
+	case NodeDisjoint:
+		return "NodeDisjoint"
+	case LinkDisjoint:
+		return "LinkDisjoint"
+	}
+	return "Disjointness(unknown)"
+}
+
+// PathFinder finds least-cost paths between nodes. Paths never pass through the same node twice.
+// Of paths of equal cost, the one with fewer edges is preferred, and any remaining ties are broken
+// the same way every time, so results are reproducible.
+type PathFinder struct {
+	// Weight gives the cost of each edge. If nil, HopCount is used.
+	Weight WeightFunc
+
+	// Traverse is called to determine whether the given Edge may be traversed from the given Node.
+	// If nil, every edge may be traversed, in either direction.
+	Traverse TraverseFunc
+
+	// ExcludedNodes holds the IDs of nodes that paths may not pass through, start or end at.
+	ExcludedNodes set.Set[string]
+
+	// ExcludedRelationships holds relationships that paths may not traverse.
+	ExcludedRelationships set.Set[er.Relationship]
+}
+
+// Shortest returns the least-cost path from the node with ID from to the node with ID to, and
+// false if there is none.
+func (pf *PathFinder) Shortest(g *Graph, from, to string) (Path, bool) {
+	return pf.search(g, nil, nil).shortest(from, to)
+}
+
+// KShortest returns up to k of the least-cost paths from the node with ID from to the node with ID
+// to, in order of cost, using Yen's algorithm. Paths that pass through the same nodes along
+// different relationships are distinct.
+func (pf *PathFinder) KShortest(g *Graph, from, to string, k int) []Path {
+	paths := []Path{}
+	first, ok := pf.Shortest(g, from, to)
+	if !ok || k < 1 {
+		return paths
+	}
+	paths = append(paths, first)
+	seen := map[string]bool{first.key(): true}
+	candidates := []Path{}
+
+	for len(paths) < k {
+		last := paths[len(paths)-1]
+		for i := range len(last.Steps) - 1 {
+			root := last.Steps[:i+1]
+
+			// The spur path may not leave the root the way any path found so far does, nor return
+			// to it.
+			relationships := set.NewSet[er.Relationship]()
+			for _, p := range paths {
+				if len(p.Steps) > i+1 && sameSteps(p.Steps[:i+1], root) {
+					relationships.Add(er.RelationshipFromProto(p.Steps[i+1].Via.GetRelationship()))
+				}
+			}
+			nodes := set.NewSet[string]()
+			for _, step := range root[:i] {
+				nodes.Add(step.ID)
+			}
+
+			s := pf.search(g, nodes, relationships)
+			spur, ok := s.shortest(root[i].ID, to)
+			if !ok {
+				continue
+			}
+			candidate := s.join(root, spur)
+			if key := candidate.key(); !seen[key] {
+				seen[key] = true
+				candidates = append(candidates, candidate)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+
+		slices.SortFunc(candidates, comparePaths)
+		paths = append(paths, candidates[0])
+		candidates = candidates[1:]
+	}
+	return paths
+}
+
+// DisjointPair returns the pair of paths from the node with ID from to the node with ID to that are
+// disjoint as given and have the least total cost, cheaper path first, and false if there is no
+// such pair. The pair is found as a minimum-cost flow, so it's found even where the least-cost path
+// isn't part of any disjoint pair.
+func (pf *PathFinder) DisjointPair(g *Graph, from, to string, d Disjointness) (Path, Path, bool) {
+	s := pf.search(g, nil, nil)
+	if from == to || !s.usable(from) || !s.usable(to) {
+		return Path{}, Path{}, false
+	}
+	network := s.flowNetwork(from, d)
+	if _, ok := network.index[to]; !ok {
+		return Path{}, Path{}, false
+	}
+	source, sink := network.out(from), network.in(to)
+	for range 2 {
+		if !network.augment(source, sink) {
+			return Path{}, Path{}, false
+		}
+	}
+
+	first := s.toPath(network.takePath(source, sink))
+	second := s.toPath(network.takePath(source, sink))
+	if comparePaths(second, first) < 0 {
+		first, second = second, first
+	}
+	return first, second, true
+}
+
+func (p Path) key() string {
+	if len(p.Steps) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(p.Steps[0].ID)
+	for _, r := range p.Relationships() {
+		sb.WriteString("\x00" + r.String())
+	}
+	return sb.String()
+}
+
+func comparePaths(l, r Path) int {
+	return cmp.Or(
+		cmp.Compare(l.Cost, r.Cost),
+		cmp.Compare(len(l.Steps), len(r.Steps)),
+		slices.Compare(l.IDs(), r.IDs()),
+		strings.Compare(l.key(), r.key()),
+	)
+}
+
+func sameSteps(l, r []Step) bool {
+	return slices.EqualFunc(l, r, func(x, y Step) bool {
+		return x.ID == y.ID && (x.Via == nil) == (y.Via == nil) && (x.Via == nil || x.Via.Same(y.Via))
+	})
+}
+
+// otherEnd returns the ID of the node at the end of the edge opposite the node with the given ID.
+func otherEnd(edge *Edge, id string) string {
+	if edge.GetA() == id {
+		return edge.GetZ()
+	}
+	return edge.GetA()
+}
+
+// search holds the state of a single search by a PathFinder, which may exclude more than the
+// PathFinder does.
+type search struct {
+	pf            *PathFinder
+	g             *Graph
+	nodes         set.Set[string]
+	relationships set.Set[er.Relationship]
+}
+
+func (pf *PathFinder) search(g *Graph, nodes set.Set[string], relationships set.Set[er.Relationship]) *search {
+	s := &search{pf: pf, g: g, nodes: set.NewSet[string](), relationships: set.NewSet[er.Relationship]()}
+	for _, excluded := range []set.Set[string]{pf.ExcludedNodes, nodes} {
+		if excluded != nil {
+			s.nodes = s.nodes.Union(excluded)
+		}
+	}
+	for _, excluded := range []set.Set[er.Relationship]{pf.ExcludedRelationships, relationships} {
+		if excluded != nil {
+			s.relationships = s.relationships.Union(excluded)
+		}
+	}
+	return s
+}
+
+func (s *search) usable(id string) bool {
+	return s.g.Node(id) != nil && !s.nodes.Contains(id)
+}
+
+func (s *search) weight(from string, edge *Edge) float64 {
+	if s.pf.Weight == nil {
+		return HopCount(s.g, from, edge)
+	}
+	return s.pf.Weight(s.g, from, edge)
+}
+
+type arc struct {
+	to     string
+	edge   *Edge
+	weight float64
+}
+
+// arcs returns the edges that may be traversed from the node with the given ID, in a reproducible
+// order.
+func (s *search) arcs(id string) []arc {
+	arcs := []arc{}
+	for _, neighbor := range slices.Sorted(slices.Values(s.g.Neighbors(id))) {
+		if neighbor == id || !s.usable(neighbor) {
+			continue
+		}
+		edges := slices.SortedFunc(slices.Values(s.g.Edges(id, neighbor)), func(l, r *Edge) int {
+			return er.CompareRelationships(er.RelationshipFromProto(l.GetRelationship()), er.RelationshipFromProto(r.GetRelationship()))
+		})
+		for _, edge := range edges {
+			if s.relationships.Contains(er.RelationshipFromProto(edge.GetRelationship())) {
+				continue
+			}
+			if s.pf.Traverse != nil && !s.pf.Traverse(s.g, id, edge) {
+				continue
+			}
+			arcs = append(arcs, arc{to: neighbor, edge: edge, weight: s.weight(id, edge)})
+		}
+	}
+	return arcs
+}
+
+// distance orders paths by cost and then by number of edges.
+type distance struct {
+	cost float64
+	hops int
+}
+
+func (d distance) plus(o distance) distance {
+	return distance{cost: d.cost + o.cost, hops: d.hops + o.hops}
+}
+
+func (d distance) less(o distance) bool {
+	return d.cost < o.cost || d.cost == o.cost && d.hops < o.hops
+}
+
+type queued struct {
+	id       string
+	distance distance
+}
+
+type queue []queued
+
+func (q queue) Len() int { return len(q) }
+func (q queue) Less(i, j int) bool {
+	return q[i].distance.less(q[j].distance) || q[i].distance == q[j].distance && q[i].id < q[j].id
+}
+func (q queue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x any)   { *q = append(*q, x.(queued)) }
+func (q *queue) Pop() any {
+	x := (*q)[len(*q)-1]
+	*q = (*q)[:len(*q)-1]
+	return x
+}
+
+// shortest finds the least-cost path using Dijkstra's algorithm.
+func (s *search) shortest(from, to string) (Path, bool) {
+	if !s.usable(from) || !s.usable(to) {
+		return Path{}, false
+	}
+	distances := map[string]distance{from: {}}
+	steps := map[string]Step{from: {ID: from}}
+	done := set.NewSet[string]()
+	q := &queue{{id: from}}
+	for q.Len() > 0 {
+		current := heap.Pop(q).(queued)
+		if done.Contains(current.id) {
+			continue
+		}
+		done.Add(current.id)
+		if current.id == to {
+			break
+		}
+		for _, a := range s.arcs(current.id) {
+			if done.Contains(a.to) {
+				continue
+			}
+			next := current.distance.plus(distance{cost: a.weight, hops: 1})
+			if known, ok := distances[a.to]; ok && !next.less(known) {
+				continue
+			}
+			distances[a.to] = next
+			steps[a.to] = Step{ID: a.to, Depth: next.hops, From: current.id, Via: a.edge}
+			heap.Push(q, queued{id: a.to, distance: next})
+		}
+	}
+	if !done.Contains(to) {
+		return Path{}, false
+	}
+
+	path := []Step{steps[to]}
+	for path[len(path)-1].ID != from {
+		path = append(path, steps[path[len(path)-1].From])
+	}
+	slices.Reverse(path)
+	return Path{Steps: path, Cost: distances[to].cost}, true
+}
+
+// join returns the path along root and then spur, which starts where root ends.
+func (s *search) join(root []Step, spur Path) Path {
+	steps := slices.Clone(root)
+	for _, step := range spur.Steps[1:] {
+		step.Depth += len(root) - 1
+		steps = append(steps, step)
+	}
+	return s.toPath(steps)
+}
+
+// toPath returns the path along the given steps, with its cost.
+func (s *search) toPath(steps []Step) Path {
+	p := Path{Steps: steps}
+	for _, step := range steps[1:] {
+		p.Cost += s.weight(step.From, step.Via)
+	}
+	return p
+}
+
+// flowArc is an arc of a flowNetwork, or the residual arc opposite one.
+type flowArc struct {
+	to, reverse int
+	capacity    int
+	distance    distance
+	residual    bool
+	// The edge the arc represents, or nil for the arc joining the halves of a split node.
+	edge *Edge
+}
+
+// flowNetwork is a network in which a flow of two units from one node to another, along arcs of unit
+// capacity, traces a pair of disjoint paths. For node-disjoint paths, each node is split into an
+// entrance and an exit joined by a single arc, so that only one path may pass through it.
+type flowNetwork struct {
+	ids   []string
+	index map[string]int
+	split bool
+	arcs  [][]flowArc
+}
+
+func (n *flowNetwork) in(id string) int {
+	return n.index[id] * n.stride()
+}
+
+func (n *flowNetwork) out(id string) int {
+	return n.index[id]*n.stride() + n.stride() - 1
+}
+
+func (n *flowNetwork) stride() int {
+	if n.split {
+		return 2
+	}
+	return 1
+}
+
+func (n *flowNetwork) addArc(from, to int, a flowArc) {
+	a.to, a.capacity, a.reverse = to, 1, len(n.arcs[to])
+	n.arcs[from] = append(n.arcs[from], a)
+	n.arcs[to] = append(n.arcs[to], flowArc{
+		to:       from,
+		reverse:  len(n.arcs[from]) - 1,
+		distance: distance{cost: -a.distance.cost, hops: -a.distance.hops},
+		residual: true,
+	})
+}
+
+// flowNetwork builds the flow network of the part of the graph reachable from the node with ID from.
+func (s *search) flowNetwork(from string, d Disjointness) *flowNetwork {
+	n := &flowNetwork{ids: []string{from}, index: map[string]int{from: 0}, split: d == NodeDisjoint}
+	arcsFrom := map[string][]arc{}
+	for i := 0; i < len(n.ids); i++ {
+		id := n.ids[i]
+		arcsFrom[id] = s.arcs(id)
+		for _, a := range arcsFrom[id] {
+			if _, ok := n.index[a.to]; !ok {
+				n.index[a.to] = len(n.ids)
+				n.ids = append(n.ids, a.to)
+			}
+		}
+	}
+
+	n.arcs = make([][]flowArc, len(n.ids)*n.stride())
+	for _, id := range n.ids {
+		if n.split {
+			n.addArc(n.in(id), n.out(id), flowArc{})
+		}
+		for _, a := range arcsFrom[id] {
+			n.addArc(n.out(id), n.in(a.to), flowArc{edge: a.edge, distance: distance{cost: a.weight, hops: 1}})
+		}
+	}
+	return n
+}
+
+// augment sends one more unit of flow from source to sink along the least-cost path through the
+// residual network, found with the Bellman-Ford algorithm since residual arcs have negative costs.
+// It returns false if there is no such path.
+func (n *flowNetwork) augment(source, sink int) bool {
+	type label struct {
+		distance distance
+		node     int
+		arc      int
+	}
+	labels := make([]*label, len(n.arcs))
+	labels[source] = &label{node: -1}
+	for range len(n.arcs) {
+		changed := false
+		for node, arcs := range n.arcs {
+			if labels[node] == nil {
+				continue
+			}
+			for i, a := range arcs {
+				if a.capacity == 0 {
+					continue
+				}
+				next := labels[node].distance.plus(a.distance)
+				if labels[a.to] == nil || next.less(labels[a.to].distance) {
+					labels[a.to] = &label{distance: next, node: node, arc: i}
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	if labels[sink] == nil {
+		return false
+	}
+
+	for node := sink; node != source; node = labels[node].node {
+		a := &n.arcs[labels[node].node][labels[node].arc]
+		a.capacity--
+		n.arcs[a.to][a.reverse].capacity++
+	}
+	return true
+}
+
+// takePath removes one unit of flow from source to sink, returning the steps of the path it took.
+func (n *flowNetwork) takePath(source, sink int) []Step {
+	steps := []Step{{ID: n.ids[source/n.stride()]}}
+	for node := source; node != sink; {
+		for i := range n.arcs[node] {
+			a := &n.arcs[node][i]
+			// An arc carries flow once its capacity is used up.
+			if a.residual || a.capacity != 0 {
+				continue
+			}
+			a.capacity++
+			n.arcs[a.to][a.reverse].capacity--
+			if a.edge != nil {
+				from := steps[len(steps)-1].ID
+				steps = append(steps, Step{ID: n.ids[a.to/n.stride()], Depth: len(steps), From: from, Via: a.edge})
+			}
+			node = a.to
+			break
+		}
+	}
+	return steps
+}
@@ -0,0 +1,244 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	set "github.com/deckarep/golang-set/v2"
+	gcmp "github.com/google/go-cmp/cmp"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// trapGraph returns routers joined by packet links with the given latencies in milliseconds, e.g.
+// "a-b": 1. The least-latency path from s to t, s a b t, shares a node with every other path from
+// s to t except s a d t, so a disjoint pair can't include it. The second link between b and t,
+// "b-t2", lets link-disjoint paths share b.
+func trapGraph(t *testing.T) *Graph {
+	t.Helper()
+	g := New()
+	for _, id := range []string{"s", "a", "b", "c", "d", "t", "e", "orphan"} {
+		mustUpsertEntities(t, g, []string{fmt.Sprintf(`id: "%s" ek_network_node{}`, id)})
+	}
+	for link, ms := range map[string]int{
+		"s-a": 1, "a-b": 1, "b-t": 1, "b-t2": 5, "a-d": 3, "d-t": 3, "s-c": 3, "c-b": 3, "t-e": 1,
+	} {
+		ends := strings.Split(strings.TrimSuffix(link, "2"), "-")
+		mustUpsertEntities(t, g, []string{
+			fmt.Sprintf(`id: "%s" ek_logical_packet_link{ latency { nanos: %d } }`, link, ms*1000000),
+		})
+		mustAddRelationships(t, g, []string{
+			fmt.Sprintf(`a: "%s" kind: RK_ORIGINATES z: "%s"`, ends[0], link),
+			fmt.Sprintf(`a: "%s" kind: RK_TERMINATES z: "%s"`, ends[1], link),
+		})
+	}
+	return g
+}
+
+// describePath summarizes a path as its IDs and cost, e.g. "s s-a a (1)". Costs below 1 are
+// latencies, which are shown in milliseconds.
+func describePath(p Path) string {
+	cost := p.Cost
+	if cost < 1 {
+		cost *= 1000
+	}
+	return fmt.Sprintf("%s (%.0f)", strings.Join(p.IDs(), " "), cost)
+}
+
+type pathTestCase struct {
+	desc     string
+	finder   PathFinder
+	from, to string
+	k        int
+	want     []string
+}
+
+func (tc *pathTestCase) Run(t *testing.T) {
+	g := trapGraph(t)
+	got := []string{}
+	if tc.k == 0 {
+		if p, ok := tc.finder.Shortest(g, tc.from, tc.to); ok {
+			got = append(got, describePath(p))
+		}
+	} else {
+		for _, p := range tc.finder.KShortest(g, tc.from, tc.to, tc.k) {
+			got = append(got, describePath(p))
+		}
+	}
+	if diff := gcmp.Diff(tc.want, got); diff != "" {
+		t.Errorf("unexpected paths (-want +got): %s", diff)
+	}
+}
+
+var pathTestCases = []pathTestCase{
+	{
+		desc:   "least latency",
+		finder: PathFinder{Weight: Latency},
+		from:   "s",
+		to:     "t",
+		want:   []string{"s s-a a a-b b b-t t (3)"},
+	},
+	{
+		desc: "fewest hops",
+		from: "s",
+		to:   "t",
+		want: []string{"s s-a a a-b b b-t t (6)"},
+	},
+	{
+		desc:   "same node",
+		finder: PathFinder{Weight: Latency},
+		from:   "s",
+		to:     "s",
+		want:   []string{"s (0)"},
+	},
+	{
+		desc:   "unreachable",
+		finder: PathFinder{Weight: Latency},
+		from:   "s",
+		to:     "orphan",
+		want:   []string{},
+	},
+	{
+		desc:   "excluded node",
+		finder: PathFinder{Weight: Latency, ExcludedNodes: set.NewSet("a")},
+		from:   "s",
+		to:     "t",
+		want:   []string{"s s-c c c-b b b-t t (7)"},
+	},
+	{
+		desc:   "excluded end",
+		finder: PathFinder{Weight: Latency, ExcludedNodes: set.NewSet("t")},
+		from:   "s",
+		to:     "t",
+		want:   []string{},
+	},
+	{
+		desc: "excluded relationship",
+		finder: PathFinder{
+			Weight: Latency,
+			ExcludedRelationships: set.NewSet(
+				er.Relationship{A: "t", Kind: npb.RK_RK_TERMINATES, Z: "b-t"},
+				er.Relationship{A: "t", Kind: npb.RK_RK_TERMINATES, Z: "b-t2"},
+			),
+		},
+		from: "s",
+		to:   "t",
+		want: []string{"s s-a a a-d d d-t t (7)"},
+	},
+	{
+		desc: "traverse func",
+		finder: PathFinder{
+			Weight: Latency,
+			Traverse: func(g *Graph, from string, edge *Edge) bool {
+				return edge.GetZ() != "b-t"
+			},
+		},
+		from: "s",
+		to:   "t",
+		want: []string{"s s-a a a-b b b-t2 t (7)"},
+	},
+	{
+		desc:   "k shortest",
+		finder: PathFinder{Weight: Latency},
+		from:   "s",
+		to:     "t",
+		k:      6,
+		want: []string{
+			"s s-a a a-b b b-t t (3)",
+			"s s-a a a-b b b-t2 t (7)",
+			"s s-a a a-d d d-t t (7)",
+			"s s-c c c-b b b-t t (7)",
+			"s s-c c c-b b b-t2 t (11)",
+			"s s-c c c-b b a-b a a-d d d-t t (13)",
+		},
+	},
+	{
+		desc:   "fewer than k",
+		finder: PathFinder{Weight: Latency, ExcludedNodes: set.NewSet("c", "d")},
+		from:   "s",
+		to:     "t",
+		k:      5,
+		want:   []string{"s s-a a a-b b b-t t (3)", "s s-a a a-b b b-t2 t (7)"},
+	},
+}
+
+func TestPathFinder(t *testing.T) {
+	for _, tc := range pathTestCases {
+		t.Run(tc.desc, tc.Run)
+	}
+}
+
+func TestZeroPath(t *testing.T) {
+	g := trapGraph(t)
+	p, ok := (&PathFinder{}).Shortest(g, "s", "missing")
+	if ok {
+		t.Fatalf("Shortest(s, missing) found %v; want no path", p.IDs())
+	}
+	if got := p.Relationships(); len(got) != 0 {
+		t.Errorf("zero Path has relationships %v; want none", got)
+	}
+	if got := p.key(); got != "" {
+		t.Errorf("zero Path has key %q; want \"\"", got)
+	}
+}
+
+func TestDisjointPair(t *testing.T) {
+	g := trapGraph(t)
+	pf := &PathFinder{Weight: Latency}
+	for _, tc := range []struct {
+		d        Disjointness
+		from, to string
+		want     []string
+	}{
+		{
+			d:    NodeDisjoint,
+			from: "s",
+			to:   "t",
+			want: []string{"s s-a a a-d d d-t t (7)", "s s-c c c-b b b-t t (7)"},
+		},
+		{
+			d:    LinkDisjoint,
+			from: "s",
+			to:   "t",
+			want: []string{"s s-a a a-b b b-t t (3)", "s s-c c c-b b b-t2 t (11)"},
+		},
+		{
+			d:    NodeDisjoint,
+			from: "s",
+			to:   "e",
+			want: []string{},
+		},
+		{
+			d:    LinkDisjoint,
+			from: "s",
+			to:   "s",
+			want: []string{},
+		},
+	} {
+		t.Run(fmt.Sprintf("%v %s to %s", tc.d, tc.from, tc.to), func(t *testing.T) {
+			got := []string{}
+			if first, second, ok := pf.DisjointPair(g, tc.from, tc.to, tc.d); ok {
+				got = append(got, describePath(first), describePath(second))
+			}
+			if diff := gcmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected paths (-want +got): %s", diff)
+			}
+		})
+	}
+}
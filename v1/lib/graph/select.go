@@ -0,0 +1,85 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"iter"
+
+	"github.com/samber/lo"
+
+	"outernetcouncil.org/nmts/v1/lib/labels"
+)
+
+// NodesWithLabel returns all nodes whose entity has the given label set to the given value.
+//
+// NOTE: Labels are indexed at upsert time; mutating an entity's labels in place afterwards is not
+// supported.
+func (g *Graph) NodesWithLabel(key, value string) []*Node {
+	return lo.Values(g.nodesByLabel[key][value])
+}
+
+// NodesMatching returns all nodes whose entity's labels match the given selector.
+func (g *Graph) NodesMatching(selector labels.Selector) []*Node {
+	return lo.Filter(lo.Values(g.candidates(selector)), func(n *Node, _ int) bool {
+		return selector.Matches(n.GetEntity().GetLabels())
+	})
+}
+
+// AllNodesMatching returns an iterator over all nodes whose entity's labels match the given
+// selector. The graph must not be modified during iteration.
+func (g *Graph) AllNodesMatching(selector labels.Selector) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for _, node := range g.candidates(selector) {
+			if selector.Matches(node.GetEntity().GetLabels()) && !yield(node) {
+				return
+			}
+		}
+	}
+}
+
+// candidates returns a superset of the nodes matching the selector, using the label index to
+// narrow the set by the most selective requirement that it can answer.
+func (g *Graph) candidates(selector labels.Selector) map[string]*Node {
+	best := g.nodes
+	for _, r := range selector {
+		var nodes map[string]*Node
+		switch r.Operator {
+		case labels.Equals, labels.In:
+			nodes = g.nodesWithAnyValue(r.Key, r.Values)
+		case labels.Exists:
+			nodes = g.nodesWithAnyValue(r.Key, lo.Keys(g.nodesByLabel[r.Key]))
+		default:
+			continue
+		}
+		if len(nodes) < len(best) {
+			best = nodes
+		}
+	}
+	return best
+}
+
+func (g *Graph) nodesWithAnyValue(key string, values []string) map[string]*Node {
+	nodesByValue := g.nodesByLabel[key]
+	if len(values) == 1 {
+		return nodesByValue[values[0]]
+	}
+	nodes := map[string]*Node{}
+	for _, value := range values {
+		for id, node := range nodesByValue[value] {
+			nodes[id] = node
+		}
+	}
+	return nodes
+}
@@ -0,0 +1,134 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"testing"
+
+	set "github.com/deckarep/golang-set/v2"
+
+	"outernetcouncil.org/nmts/v1/lib/labels"
+)
+
+var labeledGraph = graphEntities{
+	entities: []string{
+		`id: "east" ek_network_node{} labels { key: "env" value: "prod" } labels { key: "region" value: "us-east" }`,
+		`id: "west" ek_network_node{} labels { key: "env" value: "prod" } labels { key: "region" value: "us-west" }`,
+		`id: "eu" ek_network_node{} labels { key: "env" value: "prod" } labels { key: "region" value: "eu-west" }`,
+		`id: "old" ek_network_node{} labels { key: "env" value: "prod" } labels { key: "region" value: "us-east" } labels { key: "deprecated" value: "" }`,
+		`id: "dev" ek_network_node{} labels { key: "env" value: "dev" }`,
+		`id: "unlabeled" ek_network_node{}`,
+	},
+	relationships: []string{
+		`a: "east" kind: RK_CONTAINS z: "west"`,
+		`a: "west" kind: RK_CONTAINS z: "eu"`,
+		`a: "east" kind: RK_CONTAINS z: "old"`,
+		`a: "old" kind: RK_CONTAINS z: "dev"`,
+	},
+}
+
+func mustParseSelector(t *testing.T, selector string) labels.Selector {
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		t.Fatalf("unable to parse selector %q: %v", selector, err)
+	}
+	return sel
+}
+
+type nodesMatchingTestCase struct {
+	desc     string
+	selector string
+	want     set.Set[string]
+}
+
+func (tc *nodesMatchingTestCase) Run(t *testing.T) {
+	g := New()
+	mustUpsertEntities(t, g, labeledGraph.entities)
+	sel := mustParseSelector(t, tc.selector)
+
+	if got := idsSet(g.NodesMatching(sel)); !tc.want.Equal(got) {
+		t.Errorf("NodesMatching(%q) returned unexpected IDs; want: %v; got: %v", tc.selector, tc.want, got)
+	}
+
+	got := set.NewSet[string]()
+	for node := range g.AllNodesMatching(sel) {
+		got.Add(node.GetID())
+	}
+	if !tc.want.Equal(got) {
+		t.Errorf("AllNodesMatching(%q) yielded unexpected IDs; want: %v; got: %v", tc.selector, tc.want, got)
+	}
+}
+
+var nodesMatchingTestCases = []nodesMatchingTestCase{
+	{
+		desc:     "empty selector matches everything",
+		selector: "",
+		want:     set.NewSet("east", "west", "eu", "old", "dev", "unlabeled"),
+	},
+	{
+		desc:     "equality",
+		selector: "env=dev",
+		want:     set.NewSet("dev"),
+	},
+	{
+		desc:     "set membership and absence",
+		selector: "env=prod,region in (us-east,us-west),!deprecated",
+		want:     set.NewSet("east", "west"),
+	},
+	{
+		desc:     "existence",
+		selector: "region",
+		want:     set.NewSet("east", "west", "eu", "old"),
+	},
+	{
+		desc:     "negative requirements only",
+		selector: "region notin (us-east,us-west)",
+		want:     set.NewSet("eu", "dev", "unlabeled"),
+	},
+	{
+		desc:     "no matches",
+		selector: "env=staging",
+		want:     set.NewSet[string](),
+	},
+}
+
+func TestNodesMatching(t *testing.T) {
+	for _, tc := range nodesMatchingTestCases {
+		t.Run(tc.desc, tc.Run)
+	}
+}
+
+func TestLabelIndexFollowsMutations(t *testing.T) {
+	g := New()
+	mustUpsertEntities(t, g, labeledGraph.entities)
+	clone := Clone(g)
+
+	mustUpsertEntities(t, g, []string{`id: "east" ek_network_node{} labels { key: "env" value: "dev" }`})
+	mustRemoveEntities(t, g, []string{"dev"})
+
+	if got, want := idsSet(g.NodesWithLabel("env", "dev")), set.NewSet("east"); !want.Equal(got) {
+		t.Errorf("NodesWithLabel(env, dev) after mutation; want: %v; got: %v", want, got)
+	}
+	if got, want := idsSet(g.NodesWithLabel("region", "us-east")), set.NewSet("old"); !want.Equal(got) {
+		t.Errorf("NodesWithLabel(region, us-east) after mutation; want: %v; got: %v", want, got)
+	}
+	if _, exists := g.nodesByLabel["env"]["dev"]["dev"]; exists {
+		t.Errorf("removed node is still indexed")
+	}
+
+	if got, want := idsSet(clone.NodesWithLabel("env", "dev")), set.NewSet("dev"); !want.Equal(got) {
+		t.Errorf("clone's label index changed with the original; want: %v; got: %v", want, got)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+)
+
+// Shared is a Graph that any number of goroutines may read while updates are applied to it.
+//
+// Readers take a Snapshot, which is a consistent view of the graph as of the last completed
+// update, and which is never modified, so it may be read without locking for as long as the reader
+// likes. Writers call Update, which applies a batch of changes to a private copy-on-write copy of
+// the current snapshot and then publishes it as the next snapshot. Updates are serialized, and
+// never block readers. Watchers added with Watch are notified of each update once it's published.
+//
+// The copy made by Update is a shallow copy of the snapshot's top-level indices, so, as with Begin,
+// each Update costs time and memory proportional to the number of nodes in the graph. The per-node,
+// per-kind and per-label maps inside them are shared with the snapshot until first written, so
+// beyond that the cost grows with the number of distinct nodes, kinds and labels the batch touches.
+// Clone shares every Node and Edge too, but copies every one of those inner maps, which is what
+// Update saves: on the 21,000-node graph of BenchmarkSharedUpdate and BenchmarkCloneUpdate, an
+// Update takes about a tenth of the time and a sixth of the memory of a Clone, in a few hundred
+// allocations rather than tens of thousands.
+type Shared struct {
+	writer  sync.Mutex
+	current atomic.Pointer[Graph]
+
+	// Guards watchers, whose subscriptions are replaced rather than modified, so they may be
+	// notified without holding it.
+	watchMu  sync.Mutex
+	watchers watchers
+}
+
+// NewShared returns a Shared whose first snapshot is g. The caller must not modify g afterwards.
+func NewShared(g *Graph) *Shared {
+	s := &Shared{}
+	s.current.Store(g)
+	return s
+}
+
+// Snapshot returns the graph as of the last completed Update. The returned graph MUST NOT be
+// modified.
+func (s *Shared) Snapshot() *Graph {
+	return s.current.Load()
+}
+
+// Update calls fn with a copy of the current snapshot. If fn returns nil, the copy becomes the
+// current snapshot; otherwise it's discarded, and fn's error is returned. fn must not retain the
+// graph it's given, nor any snapshot taken from it.
+func (s *Shared) Update(fn func(*Graph) error) error {
+	s.writer.Lock()
+	defer s.writer.Unlock()
+
+	next := s.current.Load().derive()
+	next.pending = &[]Event{}
+	if err := fn(next); err != nil {
+		return err
+	}
+	events := *next.pending
+	next.pending = nil
+	s.current.Store(next)
+
+	s.watchMu.Lock()
+	watchers := s.watchers
+	s.watchMu.Unlock()
+	watchers.notify(events)
+	return nil
+}
+
+// Watch is like Graph.Watch, but fn is called with the changes made by each Update, after the
+// snapshot they produce is published. fn is called by the goroutine calling Update, and must not
+// call Update itself.
+func (s *Shared) Watch(filter Filter, fn func([]Event)) (cancel func()) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	cancelLocked := s.watchers.add(filter, fn)
+	return func() {
+		s.watchMu.Lock()
+		defer s.watchMu.Unlock()
+		cancelLocked()
+	}
+}
+
+// ownership records which of a derived graph's inner index maps have been copied from the graph
+// it was derived from, and so may be modified in place.
+type ownership struct {
+	kinds  map[string]struct{}
+	labels map[string]struct{}
+	edges  map[string]struct{}
+}
+
+// derive returns a copy of g whose top-level index maps are shallow copies of g's, which takes time
+// proportional to the number of nodes, and which shares g's inner index maps, each of which is
+// copied before it's first modified. g itself must not be modified afterwards, as that would show
+// through the copy.
+func (g *Graph) derive() *Graph {
+	return &Graph{
+		nodes:        maps.Clone(g.nodes),
+		nodesByKind:  maps.Clone(g.nodesByKind),
+		nodesByLabel: maps.Clone(g.nodesByLabel),
+		edges:        maps.Clone(g.edges),
+		outEdges:     maps.Clone(g.outEdges),
+		inEdges:      maps.Clone(g.inEdges),
+		integrity:    g.integrity,
+		owned: &ownership{
+			kinds:  map[string]struct{}{},
+			labels: map[string]struct{}{},
+			edges:  map[string]struct{}{},
+		},
+	}
+}
+
+// ownKind ensures the nodes of the given kind may be modified in place.
+func (g *Graph) ownKind(ek string) {
+	if g.owned == nil {
+		return
+	}
+	if _, owned := g.owned.kinds[ek]; !owned {
+		if nodes, exists := g.nodesByKind[ek]; exists {
+			g.nodesByKind[ek] = maps.Clone(nodes)
+		}
+		g.owned.kinds[ek] = struct{}{}
+	}
+}
+
+// ownLabel ensures the index of the given label key may be modified in place.
+func (g *Graph) ownLabel(key string) {
+	if g.owned == nil {
+		return
+	}
+	if _, owned := g.owned.labels[key]; !owned {
+		if nodesByValue, exists := g.nodesByLabel[key]; exists {
+			g.nodesByLabel[key] = cloneMapOfMaps(nodesByValue)
+		}
+		g.owned.labels[key] = struct{}{}
+	}
+}
+
+// ownEdges ensures the edges of the node with the given ID may be modified in place, in both the
+// undirected and directed indices. The slices of edges are never modified in place, so they remain
+// shared.
+func (g *Graph) ownEdges(id string) {
+	if g.owned == nil {
+		return
+	}
+	if _, owned := g.owned.edges[id]; !owned {
+		if edgesByNeighbor, exists := g.edges[id]; exists {
+			g.edges[id] = maps.Clone(edgesByNeighbor)
+		}
+		if edgesByKind, exists := g.outEdges[id]; exists {
+			g.outEdges[id] = maps.Clone(edgesByKind)
+		}
+		if edgesByKind, exists := g.inEdges[id]; exists {
+			g.inEdges[id] = maps.Clone(edgesByKind)
+		}
+		g.owned.edges[id] = struct{}{}
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+func mustNewShared(t *testing.T) *Shared {
+	t.Helper()
+	g, err := FromCollection(mustBuildCollection(t, conversionFragment))
+	if err != nil {
+		t.Fatalf("FromCollection: %v", err)
+	}
+	return NewShared(g)
+}
+
+// updateConversionGraph modifies every index of a graph built from conversionFragment.
+func updateConversionGraph(g *Graph) error {
+	if _, err := g.UpsertEntity(&npb.Entity{
+		Id:     "platform",
+		Labels: map[string]string{"env": "dev"},
+		Kind:   &npb.Entity_EkPlatform{},
+	}); err != nil {
+		return err
+	}
+	if _, err := g.UpsertEntity(&npb.Entity{Id: "port2", Kind: &npb.Entity_EkPort{}}); err != nil {
+		return err
+	}
+	if _, err := g.AddRelationship(&npb.Relationship{A: "node", Kind: npb.RK_RK_CONTAINS, Z: "port2"}); err != nil {
+		return err
+	}
+	if _, err := g.AddRelationship(&npb.Relationship{A: "node", Kind: npb.RK_RK_TRAVERSES, Z: "port"}); err != nil {
+		return err
+	}
+	if err := g.RemoveRelationship(&npb.Relationship{A: "port", Kind: npb.RK_RK_TRAVERSES, Z: "port"}); err != nil {
+		return err
+	}
+	return g.RemoveEntity("platform")
+}
+
+func TestSharedSnapshotIsUnchangedByUpdate(t *testing.T) {
+	s := mustNewShared(t)
+	before := s.Snapshot()
+	wantBefore := Clone(before).ToFragment()
+
+	if err := s.Update(updateConversionGraph); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if diff := gcmp.Diff(wantBefore, before.ToFragment(), protocmp.Transform()); diff != "" {
+		t.Errorf("earlier snapshot changed by Update (-want +got): %s", diff)
+	}
+	if got := len(before.NodesWithLabel("env", "prod")); got != 1 {
+		t.Errorf("wanted 1 node labelled env=prod in earlier snapshot, got %d", got)
+	}
+	if got := len(before.NodesOfKind("EK_PORT")); got != 1 {
+		t.Errorf("wanted 1 EK_PORT node in earlier snapshot, got %d", got)
+	}
+
+	want := Clone(before)
+	if err := updateConversionGraph(want); err != nil {
+		t.Fatalf("updating clone: %v", err)
+	}
+	after := s.Snapshot()
+	if diff := gcmp.Diff(want.ToFragment(), after.ToFragment(), protocmp.Transform()); diff != "" {
+		t.Errorf("unexpected snapshot after Update (-want +got): %s", diff)
+	}
+	if got := len(after.NodesWithLabel("env", "prod")); got != 0 {
+		t.Errorf("wanted no nodes labelled env=prod after Update, got %d", got)
+	}
+	if got := len(after.Edges("port", "port")); got != 0 {
+		t.Errorf("wanted no port->port edges after Update, got %d", got)
+	}
+}
+
+func TestSharedFailedUpdatePublishesNothing(t *testing.T) {
+	s := mustNewShared(t)
+	before := s.Snapshot()
+	want := before.ToFragment()
+
+	err := s.Update(func(g *Graph) error {
+		if err := updateConversionGraph(g); err != nil {
+			return err
+		}
+		return g.RemoveEntity("missing")
+	})
+	if err == nil {
+		t.Fatalf("Update succeeded")
+	}
+
+	if s.Snapshot() != before {
+		t.Errorf("failed Update published a new snapshot")
+	}
+	if diff := gcmp.Diff(want, before.ToFragment(), protocmp.Transform()); diff != "" {
+		t.Errorf("snapshot changed by failed Update (-want +got): %s", diff)
+	}
+}
+
+// TestSharedConcurrentReaders is most useful under the race detector: go test -race.
+func TestSharedConcurrentReaders(t *testing.T) {
+	const (
+		batches   = 200
+		batchSize = 5
+		readers   = 8
+	)
+	s := NewShared(New())
+	if err := s.Update(func(g *Graph) error {
+		_, err := g.UpsertEntity(&npb.Entity{Id: "node", Kind: &npb.Entity_EkNetworkNode{}})
+		return err
+	}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	// Every batch adds ports that are all contained by "node" and labelled with the batch number,
+	// so each snapshot must hold whole batches, and agree with itself in every index.
+	checkSnapshot := func(g *Graph) error {
+		ports := g.NodesOfKind("EK_PORT")
+		if len(ports)%batchSize != 0 {
+			return fmt.Errorf("snapshot has %d ports, which is not a whole number of batches", len(ports))
+		}
+		if got := len(g.Neighbors("node")); got != len(ports) {
+			return fmt.Errorf("snapshot has %d ports but node has %d neighbors", len(ports), got)
+		}
+		for _, port := range ports {
+			if got := len(g.Edges("node", port.GetID())); got != 1 {
+				return fmt.Errorf("wanted 1 edge from node to %s, got %d", port.GetID(), got)
+			}
+			batch := port.GetEntity().GetLabels()["batch"]
+			if got := len(g.NodesWithLabel("batch", batch)); got != batchSize {
+				return fmt.Errorf("wanted %d ports labelled batch=%s, got %d", batchSize, batch, got)
+			}
+		}
+		return nil
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if err := checkSnapshot(s.Snapshot()); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	for b := range batches {
+		err := s.Update(func(g *Graph) error {
+			for i := range batchSize {
+				id := fmt.Sprintf("port-%d-%d", b, i)
+				if _, err := g.UpsertEntity(&npb.Entity{
+					Id:     id,
+					Labels: map[string]string{"batch": strconv.Itoa(b)},
+					Kind:   &npb.Entity_EkPort{},
+				}); err != nil {
+					return err
+				}
+				if _, err := g.AddRelationship(&npb.Relationship{A: "node", Kind: npb.RK_RK_CONTAINS, Z: id}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if got := len(s.Snapshot().NodesOfKind("EK_PORT")); got != batches*batchSize {
+		t.Errorf("wanted %d ports after all updates, got %d", batches*batchSize, got)
+	}
+	if err := checkSnapshot(s.Snapshot()); err != nil {
+		t.Error(err)
+	}
+}
+
+// benchmarkLargeGraph returns a graph of nodes, each containing ports, all of which are labelled.
+func benchmarkLargeGraph(b *testing.B) *Graph {
+	b.Helper()
+	const (
+		nodes        = 1000
+		portsPerNode = 20
+	)
+	g := New()
+	for n := range nodes {
+		node := fmt.Sprintf("node-%d", n)
+		if _, err := g.UpsertEntity(&npb.Entity{
+			Id:     node,
+			Labels: map[string]string{"env": "prod"},
+			Kind:   &npb.Entity_EkNetworkNode{},
+		}); err != nil {
+			b.Fatalf("unable to upsert entity: %v", err)
+		}
+		for p := range portsPerNode {
+			port := fmt.Sprintf("%s-port-%d", node, p)
+			if _, err := g.UpsertEntity(&npb.Entity{Id: port, Kind: &npb.Entity_EkPort{}}); err != nil {
+				b.Fatalf("unable to upsert entity: %v", err)
+			}
+			if _, err := g.AddRelationship(&npb.Relationship{A: node, Kind: npb.RK_RK_CONTAINS, Z: port}); err != nil {
+				b.Fatalf("unable to add relationship: %v", err)
+			}
+		}
+	}
+	return g
+}
+
+// benchmarkBatch adds a port to a node of a graph from benchmarkLargeGraph.
+func benchmarkBatch(g *Graph, i int) error {
+	node := fmt.Sprintf("node-%d", i%1000)
+	port := fmt.Sprintf("%s-extra-%d", node, i)
+	if _, err := g.UpsertEntity(&npb.Entity{Id: port, Kind: &npb.Entity_EkPort{}}); err != nil {
+		return err
+	}
+	_, err := g.AddRelationship(&npb.Relationship{A: node, Kind: npb.RK_RK_CONTAINS, Z: port})
+	return err
+}
+
+func BenchmarkSharedUpdate(b *testing.B) {
+	s := NewShared(benchmarkLargeGraph(b))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.Update(func(g *Graph) error { return benchmarkBatch(g, i) }); err != nil {
+			b.Fatalf("Update: %v", err)
+		}
+	}
+}
+
+// BenchmarkCloneUpdate publishes snapshots by cloning the whole graph for every batch.
+func BenchmarkCloneUpdate(b *testing.B) {
+	current := benchmarkLargeGraph(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		next := Clone(current)
+		if err := benchmarkBatch(next, i); err != nil {
+			b.Fatalf("batch: %v", err)
+		}
+		current = next
+	}
+}
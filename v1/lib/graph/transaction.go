@@ -0,0 +1,119 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// Begin starts a batch of changes, which ends with either Commit or Rollback. Until then, the graph
+// may be read and modified as usual, but watchers aren't notified of the changes.
+//
+// Beginning a batch costs time proportional to the number of nodes in the graph; the changes in it
+// then copy only those parts of the graph's indices that they modify.
+func (g *Graph) Begin() error {
+	if g.tx != nil {
+		return fmt.Errorf("a batch is already in progress")
+	}
+	saved := *g
+	*g = *g.derive()
+	g.watchers = saved.watchers
+	g.pending = &[]Event{}
+	g.tx = &saved
+	return nil
+}
+
+// Commit ends the batch started by Begin. If v is not nil, the entities upserted in the batch, the
+// relationships added in it, and the relationships incident to the upserted entities are checked
+// with it, e.g. with validation.DefaultGraphValidator. If any check fails, the batch is rolled back
+// and every error is returned; otherwise watchers are notified of the batch's changes as one batch.
+func (g *Graph) Commit(v Validator) error {
+	if g.tx == nil {
+		return fmt.Errorf("no batch is in progress")
+	}
+	events := *g.pending
+	if v != nil {
+		if err := g.validateBatch(events, v); err != nil {
+			g.Rollback()
+			return err
+		}
+	}
+
+	saved := g.tx
+	g.tx = nil
+	g.pending = saved.pending
+	g.owned = saved.owned
+	g.publish(events...)
+	return nil
+}
+
+// Rollback ends the batch started by Begin, restoring the graph, including its integrity mode, to
+// exactly as it was when the batch began. Watchers added during the batch remain.
+func (g *Graph) Rollback() error {
+	if g.tx == nil {
+		return fmt.Errorf("no batch is in progress")
+	}
+	watchers := g.watchers
+	*g = *g.tx
+	g.watchers = watchers
+	return nil
+}
+
+// validateBatch checks the entities and relationships still in the graph that the events added.
+func (g *Graph) validateBatch(events []Event, v Validator) error {
+	upserted := map[string]struct{}{}
+	relationships := map[er.Relationship]struct{}{}
+	for _, e := range events {
+		switch e.Type {
+		case EntityUpserted:
+			upserted[e.Entity.GetId()] = struct{}{}
+		case RelationshipAdded:
+			relationships[e.Relationship] = struct{}{}
+		}
+	}
+
+	errs := []error{}
+	for _, id := range slices.Sorted(maps.Keys(upserted)) {
+		node := g.Node(id)
+		if node == nil {
+			continue
+		}
+		errs = append(errs, v.ValidateEntity(g, node.GetEntity()))
+		for _, edges := range g.AllNeighbors(id) {
+			for _, edge := range edges {
+				relationships[er.RelationshipFromProto(edge.GetRelationship())] = struct{}{}
+			}
+		}
+	}
+
+	for _, r := range slices.SortedFunc(maps.Keys(relationships), er.CompareRelationships) {
+		if !g.containsRelationship(r) {
+			continue
+		}
+		errs = append(errs, v.ValidateRelationship(g, r))
+	}
+	return errors.Join(errs...)
+}
+
+func (g *Graph) containsRelationship(r er.Relationship) bool {
+	probe := Edge{relationship: &npb.Relationship{A: r.A, Kind: r.Kind, Z: r.Z}}
+	return slices.ContainsFunc(g.Edges(r.A, r.Z), probe.Same)
+}
@@ -0,0 +1,325 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// graphState summarizes every index of a graph, so that tests can check a rollback restores all of
+// them and not just what ToFragment reports.
+type graphState struct {
+	Fragment  *npb.Fragment
+	Kinds     map[string][]string
+	Labels    map[string][]string
+	Neighbors map[string][]string
+	OutAndIn  map[string][]string
+	Integrity Integrity
+}
+
+func stateOf(g *Graph) graphState {
+	state := graphState{
+		Fragment:  g.ToFragment(),
+		Kinds:     map[string][]string{},
+		Labels:    map[string][]string{},
+		Neighbors: map[string][]string{},
+		OutAndIn:  map[string][]string{},
+		Integrity: g.Integrity(),
+	}
+	for _, entity := range state.Fragment.GetEntity() {
+		id := entity.GetId()
+		kind := g.Node(id).GetKind()
+		state.Kinds[kind] = sortedIDs(g.NodesOfKind(kind))
+		for key, value := range entity.GetLabels() {
+			state.Labels[key+"="+value] = sortedIDs(g.NodesWithLabel(key, value))
+		}
+	}
+	for edge := range g.AllEdges() {
+		for _, id := range []string{edge.GetA(), edge.GetZ()} {
+			state.Neighbors[id] = sortedStrings(g.Neighbors(id))
+			directed := []string{}
+			for neighbor, e := range g.OutNeighbors(id, npb.RK_RK_UNSPECIFIED) {
+				directed = append(directed, "out "+neighbor+" "+e.GetKind().String())
+			}
+			for neighbor, e := range g.InNeighbors(id, npb.RK_RK_UNSPECIFIED) {
+				directed = append(directed, "in "+neighbor+" "+e.GetKind().String())
+			}
+			state.OutAndIn[id] = directed
+		}
+	}
+	return state
+}
+
+func sortedIDs(nodes []*Node) []string {
+	ids := []string{}
+	for _, node := range nodes {
+		ids = append(ids, node.GetID())
+	}
+	return sortedStrings(ids)
+}
+
+func sortedStrings(s []string) []string {
+	sorted := append([]string{}, s...)
+	slices.Sort(sorted)
+	return sorted
+}
+
+// rewireModem replaces the port of conversionFragment with an interface, re-wiring its
+// relationships, and relabels the platform.
+func rewireModem(t *testing.T, g *Graph) {
+	t.Helper()
+	mustRemoveRelationships(t, g, []string{
+		`a: "node" kind: RK_CONTAINS z: "port"`,
+		`a: "node" kind: RK_ORIGINATES z: "port"`,
+		`a: "port" kind: RK_TRAVERSES z: "port"`,
+	})
+	mustRemoveEntities(t, g, []string{"port"})
+	mustUpsertEntities(t, g, []string{
+		`id: "iface" ek_interface{}`,
+		`id: "platform" ek_platform{} labels { key: "env" value: "dev" }`,
+	})
+	mustAddRelationships(t, g, []string{`a: "node" kind: RK_CONTAINS z: "iface"`})
+}
+
+type transactionTestCase struct {
+	desc string
+	// Made within the batch, after rewireModem.
+	change    func(*testing.T, *Graph)
+	rollback  bool
+	wantErr   string
+	wantState func(*testing.T) graphState
+	wantBatch []string
+}
+
+func (tc *transactionTestCase) Run(t *testing.T) {
+	g, err := FromCollection(mustBuildCollection(t, conversionFragment))
+	if err != nil {
+		t.Fatalf("FromCollection: %v", err)
+	}
+	batches := [][]Event{}
+	g.Watch(Filter{}, func(events []Event) {
+		batches = append(batches, events)
+	})
+
+	if err := g.Begin(); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	rewireModem(t, g)
+	if tc.change != nil {
+		tc.change(t, g)
+	}
+	if len(batches) > 0 {
+		t.Errorf("watchers notified before the batch ended")
+	}
+	if tc.rollback {
+		err = g.Rollback()
+	} else {
+		err = g.Commit(rejectingValidator{})
+	}
+	if tc.wantErr == "" && err != nil {
+		t.Fatalf("ending batch: %v", err)
+	}
+	if tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr) {
+		t.Errorf("ending batch returned error %v; want %q", err, tc.wantErr)
+	}
+
+	if diff := gcmp.Diff(tc.wantState(t), stateOf(g), protocmp.Transform()); diff != "" {
+		t.Errorf("unexpected graph after batch (-want +got): %s", diff)
+	}
+	if diff := gcmp.Diff(tc.wantBatch, describeBatches(batches)); diff != "" {
+		t.Errorf("unexpected events (-want +got): %s", diff)
+	}
+}
+
+func stateBefore(t *testing.T) graphState {
+	g, err := FromCollection(mustBuildCollection(t, conversionFragment))
+	if err != nil {
+		t.Fatalf("FromCollection: %v", err)
+	}
+	return stateOf(g)
+}
+
+func stateAfterRewiring(t *testing.T) graphState {
+	g, err := FromCollection(mustBuildCollection(t, conversionFragment))
+	if err != nil {
+		t.Fatalf("FromCollection: %v", err)
+	}
+	rewireModem(t, g)
+	return stateOf(g)
+}
+
+var transactionTestCases = []transactionTestCase{
+	{
+		desc:      "commit",
+		wantState: stateAfterRewiring,
+		wantBatch: []string{
+			"RelationshipRemoved node->RK_CONTAINS->port; RelationshipRemoved node->RK_ORIGINATES->port; " +
+				"RelationshipRemoved port->RK_TRAVERSES->port; EntityRemoved port; EntityUpserted iface; " +
+				"EntityUpserted platform (replaced); RelationshipAdded node->RK_CONTAINS->iface",
+		},
+	},
+	{
+		desc:      "rollback",
+		rollback:  true,
+		wantState: stateBefore,
+		wantBatch: []string{},
+	},
+	{
+		desc: "rejected entity",
+		change: func(t *testing.T, g *Graph) {
+			mustUpsertEntities(t, g, []string{`id: "node" ek_network_node{} labels { key: "bad" value: "" }`})
+		},
+		wantErr:   errRejected.Error(),
+		wantState: stateBefore,
+		wantBatch: []string{},
+	},
+	{
+		desc: "rejected relationship",
+		change: func(t *testing.T, g *Graph) {
+			mustUpsertEntities(t, g, []string{`id: "agent" ek_sdn_agent{}`})
+			mustAddRelationships(t, g, []string{`a: "agent" kind: RK_CONTROLS z: "node"`})
+		},
+		wantErr:   errRejected.Error(),
+		wantState: stateBefore,
+		wantBatch: []string{},
+	},
+	{
+		desc: "rejected relationship removed within batch",
+		change: func(t *testing.T, g *Graph) {
+			mustAddRelationships(t, g, []string{`a: "platform" kind: RK_CONTROLS z: "node"`})
+			mustRemoveRelationships(t, g, []string{`a: "platform" kind: RK_CONTROLS z: "node"`})
+		},
+		wantState: stateAfterRewiring,
+		wantBatch: []string{
+			"RelationshipRemoved node->RK_CONTAINS->port; RelationshipRemoved node->RK_ORIGINATES->port; " +
+				"RelationshipRemoved port->RK_TRAVERSES->port; EntityRemoved port; EntityUpserted iface; " +
+				"EntityUpserted platform (replaced); RelationshipAdded node->RK_CONTAINS->iface; " +
+				"RelationshipAdded platform->RK_CONTROLS->node; RelationshipRemoved platform->RK_CONTROLS->node",
+		},
+	},
+	{
+		desc: "update within rolled back batch",
+		change: func(t *testing.T, g *Graph) {
+			if err := g.Update(func(g *Graph) error { return g.RemoveEntity("node") }); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+		},
+		rollback:  true,
+		wantState: stateBefore,
+		wantBatch: []string{},
+	},
+}
+
+func TestTransaction(t *testing.T) {
+	for _, tc := range transactionTestCases {
+		t.Run(tc.desc, tc.Run)
+	}
+}
+
+func TestTransactionMisuse(t *testing.T) {
+	g := New()
+	if err := g.Commit(nil); err == nil {
+		t.Errorf("Commit without Begin succeeded")
+	}
+	if err := g.Rollback(); err == nil {
+		t.Errorf("Rollback without Begin succeeded")
+	}
+	if err := g.Begin(); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if err := g.Begin(); err == nil {
+		t.Errorf("nested Begin succeeded")
+	}
+	if err := g.Commit(nil); err != nil {
+		t.Errorf("Commit: %v", err)
+	}
+	if err := g.Commit(nil); err == nil {
+		t.Errorf("second Commit succeeded")
+	}
+}
+
+func TestRollbackRestoresIntegrity(t *testing.T) {
+	g := New()
+	if err := g.Begin(); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	g.SetIntegrity(IntegrityCascade)
+	if err := g.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if got := g.Integrity(); got != IntegrityNone {
+		t.Errorf("got integrity %v after Rollback; want %v", got, IntegrityNone)
+	}
+}
+
+func TestTransactionWithinSharedUpdate(t *testing.T) {
+	s := mustNewShared(t)
+	before := s.Snapshot()
+	want := stateOf(before)
+
+	err := s.Update(func(g *Graph) error {
+		if err := g.Begin(); err != nil {
+			return err
+		}
+		rewireModem(t, g)
+		if err := g.Rollback(); err != nil {
+			return err
+		}
+		if diff := gcmp.Diff(want, stateOf(g), protocmp.Transform()); diff != "" {
+			t.Errorf("unexpected graph after Rollback (-want +got): %s", diff)
+		}
+
+		if err := g.Begin(); err != nil {
+			return err
+		}
+		rewireModem(t, g)
+		return g.Commit(nil)
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if diff := gcmp.Diff(want, stateOf(before), protocmp.Transform()); diff != "" {
+		t.Errorf("earlier snapshot changed (-want +got): %s", diff)
+	}
+	if diff := gcmp.Diff(stateAfterRewiring(t), stateOf(s.Snapshot()), protocmp.Transform()); diff != "" {
+		t.Errorf("unexpected snapshot after Update (-want +got): %s", diff)
+	}
+}
+
+func TestCommitReturnsEveryError(t *testing.T) {
+	g := New()
+	if err := g.Begin(); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	for i := range 3 {
+		mustUpsertEntities(t, g, []string{fmt.Sprintf(`id: "bad%d" ek_port{} labels { key: "bad" value: "" }`, i)})
+	}
+	err := g.Commit(rejectingValidator{})
+	if got := len(err.(interface{ Unwrap() []error }).Unwrap()); got != 3 || !errors.Is(err, errRejected) {
+		t.Errorf("Commit returned %v; want 3 rejections", err)
+	}
+	if got := len(g.NodesOfKind("EK_PORT")); got != 0 {
+		t.Errorf("wanted no ports after failed Commit; got %d", got)
+	}
+}
@@ -15,8 +15,13 @@
 package graph
 
 import (
+	"context"
+	"iter"
+	"slices"
+
 	set "github.com/deckarep/golang-set/v2"
 
+	"outernetcouncil.org/nmts/v1/lib/labels"
 	npb "outernetcouncil.org/nmts/v1/proto"
 )
 
@@ -32,11 +37,27 @@ func (s *stack[T]) Pop() T {
 
 type TraverseFunc func(g *Graph, fromNodeID string, candidateEdge *Edge) bool
 
+// Step describes how a walk reached a node.
+type Step struct {
+	// ID is the ID of the node reached.
+	ID string
+
+	// Depth is the number of edges the walk traversed to reach the node; 0 for the node it started
+	// from.
+	Depth int
+
+	// From is the ID of the node the walk reached this one from, and Via the edge it traversed to do
+	// so. Both are zero for the node the walk started from.
+	From string
+	Via  *Edge
+}
+
 type DepthFirst struct {
 	// Visit is called once for each Node that is visited.
 	Visit func(*Graph, string)
 
 	// Traverse is called to determine whether the given Edge should be traversed from the given Node.
+	// If nil, every edge is traversed, as with BreadthFirst.
 	Traverse TraverseFunc
 
 	visited set.Set[string]
@@ -46,39 +67,151 @@ func (df *DepthFirst) reset() {
 	df.visited = set.NewSet[string]()
 }
 
-// Walk walks the entire graph g starting from the from Node, respecting the Traverse function if
-// provided. until is called after each visit, and if it returns true, the walk is terminated
-// early.
+// Walk walks the graph g starting from the from Node, respecting the Traverse function. until is
+// called after each visit, and if it returns true, the walk is terminated early.
 func (df *DepthFirst) Walk(g *Graph, from string, until func(string) bool) {
-	df.reset()
-
-	s := &stack[string]{}
-	s.Push(from)
-	for s.Len() != 0 {
-		visiting := s.Pop()
-		if df.visited.Contains(visiting) {
-			continue
-		}
-		df.visited.Add(visiting)
+	for step := range df.All(context.Background(), g, from) {
 		if df.Visit != nil {
-			df.Visit(g, visiting)
+			df.Visit(g, step.ID)
 		}
-		if until != nil && until(visiting) {
+		if until != nil && until(step.ID) {
 			return
 		}
-		toVisits := g.Neighbors(visiting)
-		for _, toVisit := range toVisits {
-			edges := g.Edges(visiting, toVisit)
-			for _, edge := range edges {
-				if df.Traverse != nil && df.Traverse(g, visiting, edge) {
-					s.Push(toVisit)
-					break
+	}
+}
+
+// All returns an iterator over the steps of a walk like Walk's, except that Visit isn't called. The
+// walk ends early if ctx is cancelled; check ctx.Err() to tell whether it was. Depths and
+// predecessors are those of the depth-first tree, so they don't describe shortest paths; see
+// BreadthFirst for those.
+func (df *DepthFirst) All(ctx context.Context, g *Graph, from string) iter.Seq[Step] {
+	return func(yield func(Step) bool) {
+		df.reset()
+
+		s := &stack[Step]{}
+		s.Push(Step{ID: from})
+		for s.Len() != 0 {
+			if ctx.Err() != nil {
+				return
+			}
+			visiting := s.Pop()
+			if df.visited.Contains(visiting.ID) {
+				continue
+			}
+			df.visited.Add(visiting.ID)
+			if !yield(visiting) {
+				return
+			}
+			toVisits := g.Neighbors(visiting.ID)
+			for _, toVisit := range toVisits {
+				edges := g.Edges(visiting.ID, toVisit)
+				for _, edge := range edges {
+					if df.Traverse == nil || df.Traverse(g, visiting.ID, edge) {
+						s.Push(Step{ID: toVisit, Depth: visiting.Depth + 1, From: visiting.ID, Via: edge})
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// BreadthFirst walks a graph in order of distance from the node it starts from, recording how it
+// reached each node so that the path to it can be reconstructed. Nodes at the same depth are visited
+// in the order they were reached, and the neighbors of each node in order of ID, so walks are
+// reproducible.
+type BreadthFirst struct {
+	// Visit is called once for each Node that is visited.
+	Visit func(*Graph, string)
+
+	// Traverse is called to determine whether the given Edge should be traversed from the given Node.
+	// If nil, every edge is traversed.
+	Traverse TraverseFunc
+
+	// MaxDepth, if positive, limits the walk to nodes at most that many edges from the node it starts
+	// from.
+	MaxDepth int
+
+	// Maps the ID of each node visited by the last walk to the step that reached it
+	steps map[string]Step
+}
+
+// Walk walks the graph g starting from the from Node, respecting the Traverse function and
+// MaxDepth. until is called after each visit, and if it returns true, the walk is terminated early.
+func (bf *BreadthFirst) Walk(g *Graph, from string, until func(string) bool) {
+	for step := range bf.All(context.Background(), g, from) {
+		if bf.Visit != nil {
+			bf.Visit(g, step.ID)
+		}
+		if until != nil && until(step.ID) {
+			return
+		}
+	}
+}
+
+// All returns an iterator over the steps of a walk like Walk's, except that Visit isn't called. The
+// walk ends early if ctx is cancelled; check ctx.Err() to tell whether it was.
+func (bf *BreadthFirst) All(ctx context.Context, g *Graph, from string) iter.Seq[Step] {
+	return func(yield func(Step) bool) {
+		bf.steps = map[string]Step{}
+
+		reached := set.NewSet(from)
+		queue := []Step{{ID: from}}
+		for len(queue) != 0 {
+			if ctx.Err() != nil {
+				return
+			}
+			visiting := queue[0]
+			queue = queue[1:]
+			bf.steps[visiting.ID] = visiting
+			if !yield(visiting) {
+				return
+			}
+			if bf.MaxDepth > 0 && visiting.Depth >= bf.MaxDepth {
+				continue
+			}
+			toVisits := g.Neighbors(visiting.ID)
+			slices.Sort(toVisits)
+			for _, toVisit := range toVisits {
+				if reached.Contains(toVisit) {
+					continue
+				}
+				for _, edge := range g.Edges(visiting.ID, toVisit) {
+					if bf.Traverse == nil || bf.Traverse(g, visiting.ID, edge) {
+						reached.Add(toVisit)
+						queue = append(queue, Step{ID: toVisit, Depth: visiting.Depth + 1, From: visiting.ID, Via: edge})
+						break
+					}
 				}
 			}
 		}
 	}
 }
 
+// Reached returns the step by which the last walk reached the node with the given ID, and whether
+// it visited that node at all.
+func (bf *BreadthFirst) Reached(id string) (Step, bool) {
+	step, visited := bf.steps[id]
+	return step, visited
+}
+
+// PathTo returns the steps of a shortest path, among the edges the last walk could traverse, from
+// the node it started from to the node with the given ID, both included. It returns nil if the
+// last walk didn't visit that node.
+func (bf *BreadthFirst) PathTo(id string) []Step {
+	step, visited := bf.steps[id]
+	if !visited {
+		return nil
+	}
+	path := []Step{step}
+	for step.Depth > 0 {
+		step = bf.steps[step.From]
+		path = append(path, step)
+	}
+	slices.Reverse(path)
+	return path
+}
+
 // TraverseAll returns true if any of the given TraverseFuncs return true.
 func TraverseAll(tfs ...TraverseFunc) TraverseFunc {
 	return func(g *Graph, from string, edge *Edge) bool {
@@ -123,3 +256,41 @@ func EdgesFrom(fromKind, aKind string, relationshipKind npb.RK, zKind string) Tr
 		return from.GetKind() == fromKind && a.GetKind() == aKind && edge.GetKind() == relationshipKind && z.GetKind() == zKind
 	}
 }
+
+// LabeledEdges will include traversal of all edges that have the given relationship kind and whose
+// a and z nodes match the given label selectors, regardless of whether the direction of traversal
+// matches the direction of the relationship.
+//
+// NOTE: If an edge corresponds to a node that isn't loaded into the graph, it will not be
+// traversed.
+func LabeledEdges(aSelector labels.Selector, relationshipKind npb.RK, zSelector labels.Selector) TraverseFunc {
+	return func(g *Graph, _ string, edge *Edge) bool {
+		a, z := g.Node(edge.GetA()), g.Node(edge.GetZ())
+		if a == nil || z == nil {
+			return false
+		}
+
+		return edge.GetKind() == relationshipKind &&
+			aSelector.Matches(a.GetEntity().GetLabels()) &&
+			zSelector.Matches(z.GetEntity().GetLabels())
+	}
+}
+
+// ToMatching restricts the given TraverseFunc to edges that lead to a node matching the given
+// label selector.
+//
+// NOTE: If an edge leads to a node that isn't loaded into the graph, it will not be traversed.
+func ToMatching(selector labels.Selector, tf TraverseFunc) TraverseFunc {
+	return func(g *Graph, fromID string, edge *Edge) bool {
+		toID := edge.GetZ()
+		if toID == fromID {
+			toID = edge.GetA()
+		}
+		to := g.Node(toID)
+		if to == nil {
+			return false
+		}
+
+		return selector.Matches(to.GetEntity().GetLabels()) && tf(g, fromID, edge)
+	}
+}
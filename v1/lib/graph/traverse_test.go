@@ -15,10 +15,16 @@
 package graph
 
 import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
 	"testing"
 
 	set "github.com/deckarep/golang-set/v2"
+	gcmp "github.com/google/go-cmp/cmp"
 
+	"outernetcouncil.org/nmts/v1/lib/labels"
 	npb "outernetcouncil.org/nmts/v1/proto"
 )
 
@@ -46,6 +52,18 @@ func (tc *walkTestCase) Run(t *testing.T) {
 		if !wantVisits.Equal(gotVisits) {
 			t.Errorf("unexpected visits from %s; want: %v; got: %v", from, wantVisits, gotVisits)
 		}
+
+		// Both walkers reach the same nodes, if in a different order.
+		gotVisits = set.NewSet[string]()
+		bfs := BreadthFirst{
+			Visit:    func(_ *Graph, n string) { gotVisits.Add(n) },
+			Traverse: TraverseAll(tc.traverseFuncs...),
+		}
+		bfs.Walk(g, from, nil)
+
+		if !wantVisits.Equal(gotVisits) {
+			t.Errorf("unexpected breadth-first visits from %s; want: %v; got: %v", from, wantVisits, gotVisits)
+		}
 	}
 }
 
@@ -103,6 +121,28 @@ var walkTestCases = []walkTestCase{
 			"demodulator": set.NewSet("node", "interface", "port", "modulator", "demodulator"),
 		},
 	},
+	{
+		desc:          "traverse edges between labeled nodes",
+		graphEntities: labeledGraph,
+		traverseFuncs: []TraverseFunc{
+			LabeledEdges(labels.Selector{{Key: "env", Operator: labels.Equals, Values: []string{"prod"}}}, npb.RK_RK_CONTAINS, labels.Selector{{Key: "region", Operator: labels.Exists}}),
+		},
+		wantVisits: map[string]set.Set[string]{
+			"east": set.NewSet("east", "west", "eu", "old"),
+			"dev":  set.NewSet("dev"),
+		},
+	},
+	{
+		desc:          "traverse only to matching nodes",
+		graphEntities: labeledGraph,
+		traverseFuncs: []TraverseFunc{
+			ToMatching(labels.Selector{{Key: "deprecated", Operator: labels.DoesNotExist}}, Edges("EK_NETWORK_NODE", npb.RK_RK_CONTAINS, "EK_NETWORK_NODE")),
+		},
+		wantVisits: map[string]set.Set[string]{
+			"east": set.NewSet("east", "west", "eu"),
+			"old":  set.NewSet("old", "east", "west", "eu", "dev"),
+		},
+	},
 }
 
 func TestDepthFirstWalk(t *testing.T) {
@@ -110,3 +150,219 @@ func TestDepthFirstWalk(t *testing.T) {
 		t.Run(tc.desc, tc.Run)
 	}
 }
+
+// ladderGraph has two routes from "a" to "d": a short one through "b", and a long one through "x"
+// and "y". "e" hangs off "d".
+var ladderGraph = graphEntities{
+	entities: []string{
+		`id: "a" ek_network_node{}`,
+		`id: "b" ek_network_node{}`,
+		`id: "d" ek_network_node{}`,
+		`id: "e" ek_network_node{}`,
+		`id: "x" ek_network_node{}`,
+		`id: "y" ek_network_node{}`,
+	},
+	relationships: []string{
+		`a: "a" kind: RK_CONTAINS z: "x"`,
+		`a: "x" kind: RK_CONTAINS z: "y"`,
+		`a: "y" kind: RK_CONTAINS z: "d"`,
+		`a: "a" kind: RK_CONTAINS z: "b"`,
+		`a: "d" kind: RK_CONTAINS z: "b"`,
+		`a: "d" kind: RK_CONTAINS z: "e"`,
+	},
+}
+
+// describeSteps summarizes steps as, e.g., "b@1 from a via a->RK_CONTAINS->b".
+func describeSteps(steps []Step) []string {
+	descs := []string{}
+	for _, step := range steps {
+		desc := fmt.Sprintf("%s@%d", step.ID, step.Depth)
+		if step.Via != nil {
+			desc += fmt.Sprintf(" from %s via %s", step.From, edgeKey(step.Via))
+		}
+		descs = append(descs, desc)
+	}
+	return descs
+}
+
+type breadthFirstTestCase struct {
+	desc     string
+	traverse TraverseFunc
+	maxDepth int
+	from     string
+	// The steps in order of visit.
+	want []string
+	// Maps node ID to the expected path to it.
+	wantPaths map[string][]string
+}
+
+func (tc *breadthFirstTestCase) Run(t *testing.T) {
+	g := New()
+	mustUpsertEntities(t, g, ladderGraph.entities)
+	mustAddRelationships(t, g, ladderGraph.relationships)
+
+	bfs := BreadthFirst{Traverse: tc.traverse, MaxDepth: tc.maxDepth}
+	got := slices.Collect(bfs.All(context.Background(), g, tc.from))
+	if diff := gcmp.Diff(tc.want, describeSteps(got)); diff != "" {
+		t.Errorf("unexpected steps (-want +got): %s", diff)
+	}
+	for _, step := range got {
+		if reached, ok := bfs.Reached(step.ID); !ok || reached != step {
+			t.Errorf("Reached(%s) = %v, %t; want %v, true", step.ID, reached, ok, step)
+		}
+	}
+	for id, want := range tc.wantPaths {
+		var gotPath []string
+		if path := bfs.PathTo(id); path != nil {
+			gotPath = describeSteps(path)
+		}
+		if diff := gcmp.Diff(want, gotPath); diff != "" {
+			t.Errorf("unexpected path to %s (-want +got): %s", id, diff)
+		}
+	}
+}
+
+var breadthFirstTestCases = []breadthFirstTestCase{
+	{
+		desc: "every edge",
+		from: "a",
+		want: []string{
+			"a@0",
+			"b@1 from a via a|RK_CONTAINS|b",
+			"x@1 from a via a|RK_CONTAINS|x",
+			"d@2 from b via d|RK_CONTAINS|b",
+			"y@2 from x via x|RK_CONTAINS|y",
+			"e@3 from d via d|RK_CONTAINS|e",
+		},
+		wantPaths: map[string][]string{
+			"a": {"a@0"},
+			"e": {"a@0", "b@1 from a via a|RK_CONTAINS|b", "d@2 from b via d|RK_CONTAINS|b", "e@3 from d via d|RK_CONTAINS|e"},
+		},
+	},
+	{
+		desc:     "max depth",
+		from:     "a",
+		maxDepth: 1,
+		want: []string{
+			"a@0",
+			"b@1 from a via a|RK_CONTAINS|b",
+			"x@1 from a via a|RK_CONTAINS|x",
+		},
+		wantPaths: map[string][]string{
+			"d": nil,
+		},
+	},
+	{
+		desc: "only in direction of relationships",
+		from: "a",
+		traverse: func(_ *Graph, from string, edge *Edge) bool {
+			return edge.GetA() == from
+		},
+		want: []string{
+			"a@0",
+			"b@1 from a via a|RK_CONTAINS|b",
+			"x@1 from a via a|RK_CONTAINS|x",
+			"y@2 from x via x|RK_CONTAINS|y",
+			"d@3 from y via y|RK_CONTAINS|d",
+			"e@4 from d via d|RK_CONTAINS|e",
+		},
+		wantPaths: map[string][]string{
+			"d": {"a@0", "x@1 from a via a|RK_CONTAINS|x", "y@2 from x via x|RK_CONTAINS|y", "d@3 from y via y|RK_CONTAINS|d"},
+		},
+	},
+	{
+		desc: "absent node",
+		from: "missing",
+		want: []string{"missing@0"},
+		wantPaths: map[string][]string{
+			"a": nil,
+		},
+	},
+}
+
+func TestBreadthFirst(t *testing.T) {
+	for _, tc := range breadthFirstTestCases {
+		t.Run(tc.desc, tc.Run)
+	}
+}
+
+func TestBreadthFirstWalkStopsEarly(t *testing.T) {
+	g := New()
+	mustUpsertEntities(t, g, ladderGraph.entities)
+	mustAddRelationships(t, g, ladderGraph.relationships)
+
+	visits := []string{}
+	bfs := BreadthFirst{Visit: func(_ *Graph, id string) { visits = append(visits, id) }}
+	bfs.Walk(g, "a", func(id string) bool { return id == "x" })
+
+	if diff := gcmp.Diff([]string{"a", "b", "x"}, visits); diff != "" {
+		t.Errorf("unexpected visits (-want +got): %s", diff)
+	}
+	if _, visited := bfs.Reached("d"); visited {
+		t.Errorf("Reached(d) reports a node the walk stopped before visiting")
+	}
+}
+
+func TestWalkersHonourCancellation(t *testing.T) {
+	g := New()
+	mustUpsertEntities(t, g, ladderGraph.entities)
+	mustAddRelationships(t, g, ladderGraph.relationships)
+
+	walkers := map[string]func(context.Context) iter.Seq[Step]{
+		"DepthFirst": func(ctx context.Context) iter.Seq[Step] {
+			return (&DepthFirst{}).All(ctx, g, "a")
+		},
+		"BreadthFirst": func(ctx context.Context) iter.Seq[Step] {
+			return (&BreadthFirst{}).All(ctx, g, "a")
+		},
+	}
+	for name, all := range walkers {
+		t.Run(name, func(t *testing.T) {
+			if got := len(slices.Collect(all(context.Background()))); got != len(ladderGraph.entities) {
+				t.Errorf("uncancelled walk visited %d nodes; want %d", got, len(ladderGraph.entities))
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			visits := 0
+			for range all(ctx) {
+				visits++
+				if visits == 2 {
+					cancel()
+				}
+			}
+			if visits != 2 {
+				t.Errorf("walk cancelled after 2 visits made %d", visits)
+			}
+
+			ctx, cancel = context.WithCancel(context.Background())
+			cancel()
+			for step := range all(ctx) {
+				t.Errorf("walk with cancelled context visited %s", step.ID)
+			}
+		})
+	}
+}
+
+func TestDepthFirstAllDepths(t *testing.T) {
+	g := New()
+	mustUpsertEntities(t, g, ladderGraph.entities)
+	mustAddRelationships(t, g, ladderGraph.relationships)
+
+	dfs := DepthFirst{}
+	depths := map[string]int{}
+	for step := range dfs.All(context.Background(), g, "a") {
+		depths[step.ID] = step.Depth
+		if step.Depth == 0 {
+			if step.ID != "a" || step.Via != nil {
+				t.Errorf("unexpected starting step %v", step)
+			}
+			continue
+		}
+		if parent, visited := depths[step.From]; !visited || parent != step.Depth-1 {
+			t.Errorf("step %v does not follow its predecessor", step)
+		}
+		if other := step.Via.GetA(); other != step.From && step.Via.GetZ() != step.From {
+			t.Errorf("step %v is not via an edge from its predecessor", step)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"slices"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// EventType identifies the kind of change an Event describes.
+type EventType int
+
+const (
+	EntityUpserted EventType = iota
+	EntityRemoved
+	RelationshipAdded
+	RelationshipRemoved
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EntityUpserted:
+		return "EntityUpserted"
+	case EntityRemoved:
+		return "EntityRemoved"
+	case RelationshipAdded:
+		return "RelationshipAdded"
+	case RelationshipRemoved:
+		return "RelationshipRemoved"
+	}
+	return "EventType(unknown)"
+}
+
+// Event describes a single change to a graph.
+type Event struct {
+	Type EventType
+
+	// Entity is the upserted or removed entity. It's nil for relationship events.
+	Entity *npb.Entity
+
+	// Previous is the entity replaced by an upsert, or nil if the upsert added a new entity.
+	Previous *npb.Entity
+
+	// Relationship is the added or removed relationship. It's zero for entity events.
+	Relationship er.Relationship
+}
+
+// Filter selects the events delivered to a watcher. The zero Filter selects every event; each
+// non-empty field further restricts the events selected.
+type Filter struct {
+	// Types restricts events to those of the given types.
+	Types []EventType
+
+	// EntityKinds restricts entity events to those for entities of the given EKs, e.g. "EK_PORT".
+	EntityKinds []string
+
+	// RelationshipKinds restricts relationship events to those for relationships of the given
+	// kinds.
+	RelationshipKinds []npb.RK
+}
+
+// Matches returns true if the filter selects the event.
+func (f Filter) Matches(e Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, e.Type) {
+		return false
+	}
+	switch e.Type {
+	case EntityUpserted, EntityRemoved:
+		return len(f.EntityKinds) == 0 || slices.Contains(f.EntityKinds, er.EntityKindStringFromProto(e.Entity))
+	default:
+		return len(f.RelationshipKinds) == 0 || slices.Contains(f.RelationshipKinds, e.Relationship.Kind)
+	}
+}
+
+// Watch arranges for fn to be called with the events selected by filter whenever the graph
+// changes, until the returned function is called. Changes made by an Update (or Apply) or in a
+// batch (see Begin) are delivered in a single call once it succeeds, in the order they were made;
+// every other change is delivered by itself as it's made. fn is not called for changes none of
+// whose events are selected.
+//
+// fn is called synchronously by the goroutine modifying the graph, and must not modify it.
+func (g *Graph) Watch(filter Filter, fn func([]Event)) (cancel func()) {
+	if g.watchers == nil {
+		g.watchers = &watchers{}
+	}
+	return g.watchers.add(filter, fn)
+}
+
+// Update calls fn with a copy of the graph. If fn returns nil, the graph is replaced by the copy,
+// and watchers are notified of the changes fn made as a single batch; otherwise the graph is left
+// unchanged, and fn's error is returned. fn must not retain the graph it's given.
+func (g *Graph) Update(fn func(*Graph) error) error {
+	next := Clone(g)
+	if g.pending != nil || g.watchers.any() {
+		next.pending = &[]Event{}
+	}
+	if err := fn(next); err != nil {
+		return err
+	}
+
+	events := next.pending
+	watchers, pending, tx := g.watchers, g.pending, g.tx
+	*g = *next
+	g.watchers, g.pending, g.tx = watchers, pending, tx
+	if events != nil {
+		g.publish(*events...)
+	}
+	return nil
+}
+
+// publish records the events if an Update or batch is in progress, and otherwise notifies watchers
+// of them.
+func (g *Graph) publish(events ...Event) {
+	if g.pending != nil {
+		*g.pending = append(*g.pending, events...)
+		return
+	}
+	g.watchers.notify(events)
+}
+
+type subscription struct {
+	filter Filter
+	fn     func([]Event)
+}
+
+type watchers struct {
+	// Replaced rather than modified, so that notify may iterate over it while a watcher cancels.
+	subscriptions []*subscription
+}
+
+func (w *watchers) add(filter Filter, fn func([]Event)) (cancel func()) {
+	sub := &subscription{filter: filter, fn: fn}
+	w.subscriptions = append(slices.Clip(w.subscriptions), sub)
+	return func() {
+		w.subscriptions = slices.DeleteFunc(slices.Clone(w.subscriptions), func(s *subscription) bool {
+			return s == sub
+		})
+	}
+}
+
+func (w *watchers) any() bool {
+	return w != nil && len(w.subscriptions) > 0
+}
+
+func (w *watchers) notify(events []Event) {
+	if !w.any() || len(events) == 0 {
+		return
+	}
+	for _, sub := range w.subscriptions {
+		selected := []Event{}
+		for _, e := range events {
+			if sub.filter.Matches(e) {
+				selected = append(selected, e)
+			}
+		}
+		if len(selected) > 0 {
+			sub.fn(selected)
+		}
+	}
+}
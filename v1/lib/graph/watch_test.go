@@ -0,0 +1,290 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"fmt"
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// describeBatches summarizes batches of events as, e.g., "EntityUpserted port; RelationshipAdded
+// node->RK_CONTAINS->port", one string per batch.
+func describeBatches(batches [][]Event) []string {
+	descs := []string{}
+	for _, batch := range batches {
+		desc := ""
+		for i, e := range batch {
+			if i > 0 {
+				desc += "; "
+			}
+			switch e.Type {
+			case EntityUpserted, EntityRemoved:
+				desc += fmt.Sprintf("%v %s", e.Type, e.Entity.GetId())
+				if e.Previous != nil {
+					desc += " (replaced)"
+				}
+			default:
+				desc += fmt.Sprintf("%v %s", e.Type, e.Relationship.String())
+			}
+		}
+		descs = append(descs, desc)
+	}
+	return descs
+}
+
+type watchTestCase struct {
+	desc   string
+	filter Filter
+	change func(*Graph) error
+	want   []string
+}
+
+func (tc *watchTestCase) Run(t *testing.T) {
+	g, err := FromCollection(mustBuildCollection(t, conversionFragment))
+	if err != nil {
+		t.Fatalf("FromCollection: %v", err)
+	}
+	batches := [][]Event{}
+	g.Watch(tc.filter, func(events []Event) {
+		batches = append(batches, events)
+	})
+
+	tc.change(g)
+
+	if diff := gcmp.Diff(tc.want, describeBatches(batches)); diff != "" {
+		t.Errorf("unexpected events (-want +got): %s", diff)
+	}
+}
+
+func changeOneByOne(g *Graph) error {
+	g.UpsertEntity(&npb.Entity{Id: "port", Kind: &npb.Entity_EkPort{}})
+	g.UpsertEntity(&npb.Entity{Id: "iface", Kind: &npb.Entity_EkInterface{}})
+	g.AddRelationship(&npb.Relationship{A: "node", Kind: npb.RK_RK_CONTAINS, Z: "iface"})
+	g.AddRelationship(&npb.Relationship{A: "iface", Kind: npb.RK_RK_TRAVERSES, Z: "port"})
+	g.RemoveRelationship(&npb.Relationship{A: "port", Kind: npb.RK_RK_TRAVERSES, Z: "port"})
+	return g.RemoveEntity("platform")
+}
+
+var watchTestCases = []watchTestCase{
+	{
+		desc:   "unbatched changes",
+		change: changeOneByOne,
+		want: []string{
+			"EntityUpserted port (replaced)",
+			"EntityUpserted iface",
+			"RelationshipAdded node->RK_CONTAINS->iface",
+			"RelationshipAdded iface->RK_TRAVERSES->port",
+			"RelationshipRemoved port->RK_TRAVERSES->port",
+			"EntityRemoved platform",
+		},
+	},
+	{
+		desc: "failed changes",
+		change: func(g *Graph) error {
+			g.UpsertEntity(&npb.Entity{Id: "port", Kind: &npb.Entity_EkInterface{}})
+			g.AddRelationship(&npb.Relationship{A: "node", Kind: npb.RK_RK_CONTAINS, Z: "port"})
+			g.RemoveRelationship(&npb.Relationship{A: "node", Kind: npb.RK_RK_CONTROLS, Z: "port"})
+			return g.RemoveEntity("missing")
+		},
+		want: []string{},
+	},
+	{
+		desc: "update",
+		change: func(g *Graph) error {
+			return g.Update(changeOneByOne)
+		},
+		want: []string{
+			"EntityUpserted port (replaced); EntityUpserted iface; " +
+				"RelationshipAdded node->RK_CONTAINS->iface; RelationshipAdded iface->RK_TRAVERSES->port; " +
+				"RelationshipRemoved port->RK_TRAVERSES->port; EntityRemoved platform",
+		},
+	},
+	{
+		desc: "failed update",
+		change: func(g *Graph) error {
+			return g.Update(func(g *Graph) error {
+				changeOneByOne(g)
+				return g.RemoveEntity("missing")
+			})
+		},
+		want: []string{},
+	},
+	{
+		desc: "nested updates",
+		change: func(g *Graph) error {
+			return g.Update(func(g *Graph) error {
+				g.RemoveEntity("platform")
+				g.Update(func(g *Graph) error {
+					g.RemoveEntity("node")
+					return fmt.Errorf("discarded")
+				})
+				return g.Update(func(g *Graph) error {
+					return g.RemoveEntity("port")
+				})
+			})
+		},
+		want: []string{"EntityRemoved platform; EntityRemoved port"},
+	},
+	{
+		desc:   "filtered by entity kind",
+		filter: Filter{EntityKinds: []string{"EK_PORT", "EK_PLATFORM"}},
+		change: changeOneByOne,
+		want: []string{
+			"EntityUpserted port (replaced)",
+			"RelationshipAdded node->RK_CONTAINS->iface",
+			"RelationshipAdded iface->RK_TRAVERSES->port",
+			"RelationshipRemoved port->RK_TRAVERSES->port",
+			"EntityRemoved platform",
+		},
+	},
+	{
+		desc: "filtered by relationship kind and type",
+		filter: Filter{
+			Types:             []EventType{RelationshipAdded, RelationshipRemoved},
+			RelationshipKinds: []npb.RK{npb.RK_RK_TRAVERSES},
+		},
+		change: func(g *Graph) error {
+			return g.Update(changeOneByOne)
+		},
+		want: []string{
+			"RelationshipAdded iface->RK_TRAVERSES->port; RelationshipRemoved port->RK_TRAVERSES->port",
+		},
+	},
+	{
+		desc:   "update with no selected events",
+		filter: Filter{EntityKinds: []string{"EK_SDN_AGENT"}, Types: []EventType{EntityUpserted}},
+		change: func(g *Graph) error {
+			return g.Update(changeOneByOne)
+		},
+		want: []string{},
+	},
+}
+
+func TestWatch(t *testing.T) {
+	for _, tc := range watchTestCases {
+		t.Run(tc.desc, tc.Run)
+	}
+}
+
+func TestWatchApply(t *testing.T) {
+	g, err := FromCollection(mustBuildCollection(t, conversionFragment))
+	if err != nil {
+		t.Fatalf("FromCollection: %v", err)
+	}
+	batches := [][]Event{}
+	g.Watch(Filter{}, func(events []Event) {
+		batches = append(batches, events)
+	})
+
+	patch := &npb.Patch{}
+	mustUnmarshal(t, `
+remove_relationship { a: "port" kind: RK_TRAVERSES z: "port" }
+add_entity { id: "iface" ek_interface{} }
+add_relationship { a: "iface" kind: RK_TRAVERSES z: "port" }
+`, patch)
+	if err := g.Apply(patch, nil); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	mustUnmarshal(t, `remove_entity: "missing"`, patch)
+	if err := g.Apply(patch, nil); err == nil {
+		t.Fatalf("Apply succeeded")
+	}
+	// Watchers survive Apply, which replaces the graph's contents.
+	mustRemoveEntities(t, g, []string{"iface"})
+
+	want := []string{
+		"RelationshipRemoved port->RK_TRAVERSES->port; EntityUpserted iface; RelationshipAdded iface->RK_TRAVERSES->port",
+		"EntityRemoved iface",
+	}
+	if diff := gcmp.Diff(want, describeBatches(batches)); diff != "" {
+		t.Errorf("unexpected events (-want +got): %s", diff)
+	}
+	if diff := gcmp.Diff(
+		&npb.Entity{Id: "iface", Kind: &npb.Entity_EkInterface{}},
+		batches[1][0].Entity,
+		protocmp.Transform(),
+	); diff != "" {
+		t.Errorf("unexpected removed entity (-want +got): %s", diff)
+	}
+}
+
+func TestWatchCancel(t *testing.T) {
+	g := New()
+	var first, second []string
+	var cancelFirst func()
+	cancelFirst = g.Watch(Filter{}, func(events []Event) {
+		first = append(first, events[0].Entity.GetId())
+		cancelFirst()
+	})
+	cancelSecond := g.Watch(Filter{}, func(events []Event) {
+		second = append(second, events[0].Entity.GetId())
+	})
+
+	mustUpsertEntities(t, g, []string{`id: "a" ek_port{}`})
+	cancelSecond()
+	mustUpsertEntities(t, g, []string{`id: "b" ek_port{}`})
+
+	if diff := gcmp.Diff([]string{"a"}, first); diff != "" {
+		t.Errorf("unexpected events for watcher cancelled while notified (-want +got): %s", diff)
+	}
+	if diff := gcmp.Diff([]string{"a"}, second); diff != "" {
+		t.Errorf("unexpected events for cancelled watcher (-want +got): %s", diff)
+	}
+}
+
+func TestSharedWatch(t *testing.T) {
+	s := mustNewShared(t)
+	batches := [][]Event{}
+	cancel := s.Watch(Filter{Types: []EventType{EntityRemoved, RelationshipRemoved}}, func(events []Event) {
+		if s.Snapshot().Node("platform") != nil {
+			t.Errorf("watcher notified before snapshot published")
+		}
+		batches = append(batches, events)
+	})
+
+	if err := s.Update(updateConversionGraph); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	s.Update(func(g *Graph) error {
+		g.RemoveEntity("node")
+		return fmt.Errorf("discarded")
+	})
+	cancel()
+	if err := s.Update(func(g *Graph) error { return g.RemoveEntity("node") }); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	want := []string{"RelationshipRemoved port->RK_TRAVERSES->port; EntityRemoved platform"}
+	if diff := gcmp.Diff(want, describeBatches(batches)); diff != "" {
+		t.Errorf("unexpected events (-want +got): %s", diff)
+	}
+}
+
+func TestFilterMatchesUsesEntityKind(t *testing.T) {
+	f := Filter{EntityKinds: []string{"EK_PORT"}}
+	port := Event{Type: EntityRemoved, Entity: &npb.Entity{Id: "p", Kind: &npb.Entity_EkPort{}}}
+	iface := Event{Type: EntityRemoved, Entity: &npb.Entity{Id: "i", Kind: &npb.Entity_EkInterface{}}}
+	rel := Event{Type: RelationshipAdded, Relationship: er.Relationship{A: "i", Kind: npb.RK_RK_CONTAINS, Z: "p"}}
+	if !f.Matches(port) || f.Matches(iface) || !f.Matches(rel) {
+		t.Errorf("Filter %+v: got matches %v, %v, %v for port, interface and relationship; want true, false, true",
+			f, f.Matches(port), f.Matches(iface), f.Matches(rel))
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package impact computes the entities affected by faults, and explains why each is affected.
+package impact
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"slices"
+
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	"outernetcouncil.org/nmts/v1/lib/utilities"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// Rule is a reason a fault propagates to an entity; its value describes the entity it applies to.
+type Rule string
+
+const (
+	// Failed applies to the entities given as failed.
+	Failed Rule = "failed"
+
+	// The rules of utilities.ComputeTransitivelyAffectedIDsForFault.
+	TraversesFailedInterface  Rule = "traverses a failed interface"
+	BeneathFailedLink         Rule = "is traversed by a failed logical packet link"
+	TraversesFailedMediumLink Rule = "traverses a failed physical medium link"
+	InFailedPlatform          Rule = "is contained by a failed platform"
+	UnderFailedNetworkNode    Rule = "is under a failed network node"
+
+	// The rules for ports.
+	TraversesFailedPort Rule = "traverses a failed port"
+	OnFailedPort        Rule = "is originated or terminated by a failed port"
+
+	// The rules for the radio and modem entities.
+	OnFailedAntenna       Rule = "is originated or terminated by a failed antenna"
+	OnFailedModemPort     Rule = "is originated or terminated by the port of a failed modem"
+	FromFailedTransmitter Rule = "is originated by an antenna fed by a failed transmitter"
+	ToFailedReceiver      Rule = "is terminated by an antenna feeding a failed receiver"
+
+	// The rules for the control plane, which leave entities unmanaged rather than failed.
+	ControlledByFailedAgent   Rule = "is controlled by a failed SDN agent"
+	ControlledByFailedRouteFn Rule = "is controlled by a failed route function"
+)
+
+// Step is a step in the explanation of an effect: an entity and the rule by which the fault reached
+// it.
+type Step struct {
+	ID   string
+	Rule Rule
+}
+
+// Effect is an entity affected by a fault.
+type Effect struct {
+	// ID is the ID of the affected entity, and Kind its kind, e.g. "EK_INTERFACE".
+	ID, Kind string
+
+	// Unmanaged is true if the entity has only lost its management, because the SDN agent or route
+	// function controlling it failed. Unmanaged entities don't propagate the fault any further.
+	Unmanaged bool `json:",omitempty"`
+
+	// Chain explains the effect: it starts with the failed entity the fault originates at, and
+	// continues with each entity the fault propagated to, ending with this one. Where a fault could
+	// have reached the entity in several ways, the chain is one of the shortest.
+	Chain []Step
+}
+
+// Impact holds the effects of a set of failures.
+type Impact struct {
+	// Failed holds the IDs of the failed entities, sorted.
+	Failed []string
+
+	// Effects holds the other entities affected by the failures, sorted by ID.
+	Effects []Effect
+}
+
+// Down returns the IDs of the failed entities and of those the failures took down, but not those
+// left unmanaged, sorted.
+func (i *Impact) Down() []string {
+	ids := slices.Clone(i.Failed)
+	for _, e := range i.Effects {
+		if !e.Unmanaged {
+			ids = append(ids, e.ID)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// propagation is a rule, and the function that returns the IDs of the entities it applies to given
+// the ID of a failed entity.
+type propagation struct {
+	rule Rule
+	next func(g *graph.Graph, id string) []string
+}
+
+// propagations maps the kind of a failed entity to how its failure takes down other entities. It
+// extends the rules of utilities.ComputeTransitivelyAffectedIDsForFault to every kind of entity
+// that can take down another; a platform's failure now only takes down the entities it contains,
+// whose own failure takes down the rest.
+var propagations = map[string][]propagation{
+	"EK_INTERFACE": {
+		{TraversesFailedInterface, in(npb.RK_RK_TRAVERSES, "EK_INTERFACE")},
+		{TraversesFailedInterface, in(npb.RK_RK_TRAVERSES, "EK_UNI")},
+	},
+	"EK_LOGICAL_PACKET_LINK": {
+		{BeneathFailedLink, func(g *graph.Graph, id string) []string {
+			return utilities.GetAllTraversedEntitiesBeneath(g, func(e *npb.Entity) bool {
+				return e.GetEkLogicalPacketLink() != nil || e.GetEkPhysicalMediumLink() != nil
+			}, id)
+		}},
+	},
+	"EK_PHYSICAL_MEDIUM_LINK": {
+		{TraversesFailedMediumLink, in(npb.RK_RK_TRAVERSES, "EK_LOGICAL_PACKET_LINK")},
+	},
+	"EK_PLATFORM": {
+		{InFailedPlatform, out(npb.RK_RK_CONTAINS, "")},
+	},
+	"EK_NETWORK_NODE": {
+		{UnderFailedNetworkNode, utilities.GetAllEntityIDsUnderNetworkNode},
+	},
+	"EK_PORT": {
+		{TraversesFailedPort, in(npb.RK_RK_TRAVERSES, "EK_INTERFACE")},
+		{OnFailedPort, mediumLinksOf},
+	},
+	"EK_ANTENNA": {
+		{OnFailedAntenna, mediumLinksOf},
+	},
+	"EK_MODULATOR": {
+		{OnFailedModemPort, modemLinks},
+	},
+	"EK_DEMODULATOR": {
+		{OnFailedModemPort, modemLinks},
+	},
+	"EK_TRANSMITTER": {
+		{FromFailedTransmitter, then(out(npb.RK_RK_SIGNAL_TRANSITS, "EK_ANTENNA"), out(npb.RK_RK_ORIGINATES, "EK_PHYSICAL_MEDIUM_LINK"))},
+	},
+	"EK_RECEIVER": {
+		{ToFailedReceiver, then(in(npb.RK_RK_SIGNAL_TRANSITS, "EK_ANTENNA"), out(npb.RK_RK_TERMINATES, "EK_PHYSICAL_MEDIUM_LINK"))},
+	},
+}
+
+// controls maps the kind of a failed entity to how its failure leaves other entities unmanaged.
+var controls = map[string]propagation{
+	"EK_SDN_AGENT": {ControlledByFailedAgent, out(npb.RK_RK_CONTROLS, "")},
+	"EK_ROUTE_FN":  {ControlledByFailedRouteFn, out(npb.RK_RK_CONTROLS, "")},
+}
+
+// in returns a function returning the IDs of the entities of the given kind, or of any kind if
+// it's empty, with relationships of the given kind to an entity.
+func in(rk npb.RK, kind string) func(*graph.Graph, string) []string {
+	return func(g *graph.Graph, id string) []string {
+		return ofKind(g, g.InNeighbors(id, rk), kind)
+	}
+}
+
+// out returns a function returning the IDs of the entities of the given kind, or of any kind if
+// it's empty, that an entity has relationships of the given kind with.
+func out(rk npb.RK, kind string) func(*graph.Graph, string) []string {
+	return func(g *graph.Graph, id string) []string {
+		return ofKind(g, g.OutNeighbors(id, rk), kind)
+	}
+}
+
+func ofKind(g *graph.Graph, ids iter.Seq2[string, *graph.Edge], kind string) []string {
+	matching := []string{}
+	for id := range ids {
+		if kind == "" || g.Node(id).GetKind() == kind {
+			matching = append(matching, id)
+		}
+	}
+	return matching
+}
+
+// then returns a function applying second to each of the IDs first returns.
+func then(first, second func(*graph.Graph, string) []string) func(*graph.Graph, string) []string {
+	return func(g *graph.Graph, id string) []string {
+		ids := []string{}
+		for _, next := range first(g, id) {
+			ids = append(ids, second(g, next)...)
+		}
+		return ids
+	}
+}
+
+// mediumLinksOf returns the IDs of the physical medium links an antenna or port originates or
+// terminates.
+func mediumLinksOf(g *graph.Graph, id string) []string {
+	return append(out(npb.RK_RK_ORIGINATES, "EK_PHYSICAL_MEDIUM_LINK")(g, id),
+		out(npb.RK_RK_TERMINATES, "EK_PHYSICAL_MEDIUM_LINK")(g, id)...)
+}
+
+// modemLinks returns the IDs of the physical medium links of the ports that originate or
+// terminate a modulator or demodulator.
+func modemLinks(g *graph.Graph, id string) []string {
+	ports := append(in(npb.RK_RK_ORIGINATES, "EK_PORT")(g, id), in(npb.RK_RK_TERMINATES, "EK_PORT")(g, id)...)
+	ids := []string{}
+	for _, port := range ports {
+		ids = append(ids, mediumLinksOf(g, port)...)
+	}
+	return ids
+}
+
+// Propagate returns the impact of the failure of the entities with the given IDs. Their failure
+// takes down other entities, whose failure may take down still more, by the rules described by
+// the Rule constants: for example, an interface's failure takes down the interfaces that traverse
+// it, and an antenna's the physical medium links it originates or terminates. Finally, the entities
+// controlled by a failed SDN agent or route function that are still up are left unmanaged.
+func Propagate(g *graph.Graph, failed []string) (*Impact, error) {
+	impact := &Impact{Failed: []string{}, Effects: []Effect{}}
+	chains := map[string][]Step{}
+	for _, id := range failed {
+		if g.Node(id) == nil {
+			return nil, fmt.Errorf("unknown entity '%v'", id)
+		}
+		if _, ok := chains[id]; !ok {
+			chains[id] = []Step{{ID: id, Rule: Failed}}
+			impact.Failed = append(impact.Failed, id)
+		}
+	}
+	slices.Sort(impact.Failed)
+
+	// Propagate breadth first, so that each chain is as short as it can be.
+	down := slices.Clone(impact.Failed)
+	reach := func(id string, p propagation, unmanaged bool) {
+		next := p.next(g, id)
+		slices.Sort(next)
+		for _, nextID := range slices.Compact(next) {
+			if _, ok := chains[nextID]; ok || g.Node(nextID) == nil {
+				continue
+			}
+			chains[nextID] = append(slices.Clip(chains[id]), Step{ID: nextID, Rule: p.rule})
+			impact.Effects = append(impact.Effects, Effect{ID: nextID, Kind: g.Node(nextID).GetKind(), Unmanaged: unmanaged, Chain: chains[nextID]})
+			if !unmanaged {
+				down = append(down, nextID)
+			}
+		}
+	}
+	for i := 0; i < len(down); i++ {
+		for _, p := range propagations[g.Node(down[i]).GetKind()] {
+			reach(down[i], p, false)
+		}
+	}
+	for _, id := range down {
+		if p, ok := controls[g.Node(id).GetKind()]; ok {
+			reach(id, p, true)
+		}
+	}
+
+	slices.SortFunc(impact.Effects, func(l, r Effect) int {
+		return cmp.Compare(l.ID, r.ID)
+	})
+	return impact, nil
+}
@@ -0,0 +1,220 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impact_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
+
+	"outernetcouncil.org/nmts/v1/lib/impact"
+	"outernetcouncil.org/nmts/v1/lib/utilities"
+	testutil "outernetcouncil.org/nmts/v1/lib/utilities/testing"
+)
+
+// A satellite platform sat holds network node n, whose interface traverses a port feeding a
+// modulator and is traversed by UNI uni, and whose agent controls the modulator and a second
+// platform's antenna. The transmitter feeds the antenna, which originates the downlink carrying
+// link l, and the uplink terminates at the antenna, which feeds the receiver.
+const impactFragment = `
+entity { id: "sat" ek_platform{} }
+entity { id: "ground" ek_platform{} }
+entity { id: "n" ek_network_node{} }
+entity { id: "n/if" ek_interface{ eth{} } }
+entity { id: "n/ip" ek_interface{ ip{} } }
+entity { id: "n/agent" ek_sdn_agent{} }
+entity { id: "uni" ek_uni{} }
+entity { id: "port" ek_port{} }
+entity { id: "mod" ek_modulator{} }
+entity { id: "tx" ek_transmitter{} }
+entity { id: "rx" ek_receiver{} }
+entity { id: "ant" ek_antenna{} }
+entity { id: "ground/ant" ek_antenna{} }
+entity { id: "feeder" ek_physical_medium_link{} }
+entity { id: "downlink" ek_physical_medium_link{} }
+entity { id: "uplink" ek_physical_medium_link{} }
+entity { id: "l" ek_logical_packet_link{} }
+relationship { a: "sat" kind: RK_CONTAINS z: "n" }
+relationship { a: "sat" kind: RK_CONTAINS z: "port" }
+relationship { a: "sat" kind: RK_CONTAINS z: "mod" }
+relationship { a: "sat" kind: RK_CONTAINS z: "tx" }
+relationship { a: "sat" kind: RK_CONTAINS z: "rx" }
+relationship { a: "sat" kind: RK_CONTAINS z: "ant" }
+relationship { a: "ground" kind: RK_CONTAINS z: "ground/ant" }
+relationship { a: "n" kind: RK_CONTAINS z: "n/if" }
+relationship { a: "n" kind: RK_CONTAINS z: "n/ip" }
+relationship { a: "n" kind: RK_CONTAINS z: "n/agent" }
+relationship { a: "n/ip" kind: RK_TRAVERSES z: "n/if" }
+relationship { a: "n/if" kind: RK_TRAVERSES z: "port" }
+relationship { a: "uni" kind: RK_TRAVERSES z: "n/if" }
+relationship { a: "port" kind: RK_ORIGINATES z: "mod" }
+relationship { a: "port" kind: RK_ORIGINATES z: "feeder" }
+relationship { a: "tx" kind: RK_SIGNAL_TRANSITS z: "ant" }
+relationship { a: "ant" kind: RK_SIGNAL_TRANSITS z: "rx" }
+relationship { a: "ant" kind: RK_ORIGINATES z: "downlink" }
+relationship { a: "ground/ant" kind: RK_TERMINATES z: "downlink" }
+relationship { a: "ground/ant" kind: RK_ORIGINATES z: "uplink" }
+relationship { a: "ant" kind: RK_TERMINATES z: "uplink" }
+relationship { a: "l" kind: RK_TRAVERSES z: "downlink" }
+relationship { a: "n/agent" kind: RK_CONTROLS z: "mod" }
+relationship { a: "n/agent" kind: RK_CONTROLS z: "ground/ant" }
+`
+
+// describeEffects returns an effect per line, as its ID, "(unmanaged)" if it is, and the IDs and
+// rules of its chain.
+func describeEffects(effects []impact.Effect) []string {
+	lines := []string{}
+	for _, e := range effects {
+		line := e.ID
+		if e.Unmanaged {
+			line += " (unmanaged)"
+		}
+		line += ":"
+		for _, step := range e.Chain {
+			line += fmt.Sprintf(" %v %v;", step.ID, step.Rule)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestPropagate(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(impactFragment))))
+	for _, tc := range []struct {
+		failed []string
+		want   []string
+	}{
+		{
+			failed: []string{"tx"},
+			want: []string{
+				"downlink: tx failed; downlink is originated by an antenna fed by a failed transmitter;",
+				"l: tx failed; downlink is originated by an antenna fed by a failed transmitter; l traverses a failed physical medium link;",
+			},
+		},
+		{
+			failed: []string{"rx"},
+			want: []string{
+				"uplink: rx failed; uplink is terminated by an antenna feeding a failed receiver;",
+			},
+		},
+		{
+			failed: []string{"ant"},
+			want: []string{
+				"downlink: ant failed; downlink is originated or terminated by a failed antenna;",
+				"l: ant failed; downlink is originated or terminated by a failed antenna; l traverses a failed physical medium link;",
+				"uplink: ant failed; uplink is originated or terminated by a failed antenna;",
+			},
+		},
+		{
+			failed: []string{"mod"},
+			want: []string{
+				"feeder: mod failed; feeder is originated or terminated by the port of a failed modem;",
+			},
+		},
+		{
+			failed: []string{"port"},
+			want: []string{
+				"feeder: port failed; feeder is originated or terminated by a failed port;",
+				"n/if: port failed; n/if traverses a failed port;",
+				"n/ip: port failed; n/if traverses a failed port; n/ip traverses a failed interface;",
+				"uni: port failed; n/if traverses a failed port; uni traverses a failed interface;",
+			},
+		},
+		{
+			failed: []string{"n/if"},
+			want: []string{
+				"n/ip: n/if failed; n/ip traverses a failed interface;",
+				"uni: n/if failed; uni traverses a failed interface;",
+			},
+		},
+		{
+			failed: []string{"n/agent", "n/agent"},
+			want: []string{
+				"ground/ant (unmanaged): n/agent failed; ground/ant is controlled by a failed SDN agent;",
+				"mod (unmanaged): n/agent failed; mod is controlled by a failed SDN agent;",
+			},
+		},
+		{
+			failed: []string{"n", "mod"},
+			want: []string{
+				"feeder: mod failed; feeder is originated or terminated by the port of a failed modem;",
+				"ground/ant (unmanaged): n failed; n/agent is under a failed network node; ground/ant is controlled by a failed SDN agent;",
+				"n/agent: n failed; n/agent is under a failed network node;",
+				"n/if: n failed; n/if is under a failed network node;",
+				"n/ip: n failed; n/ip is under a failed network node;",
+				"port: n failed; port is under a failed network node;",
+				"uni: n failed; uni is under a failed network node;",
+			},
+		},
+		{
+			failed: []string{"ground"},
+			want: []string{
+				"downlink: ground failed; ground/ant is contained by a failed platform; downlink is originated or terminated by a failed antenna;",
+				"ground/ant: ground failed; ground/ant is contained by a failed platform;",
+				"l: ground failed; ground/ant is contained by a failed platform; downlink is originated or terminated by a failed antenna; l traverses a failed physical medium link;",
+				"uplink: ground failed; ground/ant is contained by a failed platform; uplink is originated or terminated by a failed antenna;",
+			},
+		},
+	} {
+		t.Run(fmt.Sprint(tc.failed), func(t *testing.T) {
+			got, err := impact.Propagate(g, tc.failed)
+			if err != nil {
+				t.Fatalf("Propagate: %v", err)
+			}
+			if diff := gcmp.Diff(tc.want, describeEffects(got.Effects)); diff != "" {
+				t.Errorf("unexpected effects (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestPropagateFailed(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(impactFragment))))
+	got, err := impact.Propagate(g, []string{"n/agent", "ant", "n/agent"})
+	if err != nil {
+		t.Fatalf("Propagate: %v", err)
+	}
+	if diff := gcmp.Diff([]string{"ant", "n/agent"}, got.Failed); diff != "" {
+		t.Errorf("unexpected failed entities (-want +got): %s", diff)
+	}
+	if diff := gcmp.Diff([]string{"ant", "downlink", "l", "n/agent", "uplink"}, got.Down()); diff != "" {
+		t.Errorf("unexpected entities down (-want +got): %s", diff)
+	}
+
+	if _, err := impact.Propagate(g, []string{"ant", "missing"}); err == nil || err.Error() != "unknown entity 'missing'" {
+		t.Errorf("Propagate returned error %v; want unknown entity 'missing'", err)
+	}
+}
+
+// Every entity that utilities.ComputeTransitivelyAffectedIDsForFault finds is found by Propagate
+// too.
+func TestPropagateExtendsUtilities(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(impactFragment))))
+	for node := range g.AllNodesMatching(nil) {
+		got, err := impact.Propagate(g, []string{node.GetID()})
+		if err != nil {
+			t.Fatalf("Propagate: %v", err)
+		}
+		down := got.Down()
+		for _, id := range utilities.ComputeTransitivelyAffectedIDsForFault(g, []string{node.GetID()}) {
+			if !slices.Contains(down, id) {
+				t.Errorf("failure of %v: %v is affected but not down", node.GetID(), id)
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impact
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	"outernetcouncil.org/nmts/v1/lib/utilities"
+	npb "outernetcouncil.org/nmts/v1/proto"
+	mefpb "outernetcouncil.org/nmts/v1/proto/types/mef"
+)
+
+// Attachment is an EVC endpoint, the EK_UNI it's at, and the entities supporting the UNI.
+type Attachment struct {
+	// Endpoint is the ID of the EVC endpoint, and Role its role.
+	Endpoint string
+	Role     mefpb.EVC_EndPoint_Role
+
+	// UNI is the ID of the EK_UNI entity.
+	UNI string
+
+	// Interfaces holds the IDs of the interfaces the UNI RK_TRAVERSES, directly or through other
+	// interfaces, sorted.
+	Interfaces []string
+
+	// Platforms holds the IDs of the platforms encompassing the interfaces, sorted.
+	Platforms []string
+}
+
+// Service is an EVC and the attachments of its endpoints.
+type Service struct {
+	// EVC is the ID of the EVC, and Type its type.
+	EVC  string
+	Type mefpb.EVC_Type
+
+	// Attachments holds an attachment for each of the EVC's endpoints, in order.
+	Attachments []Attachment
+}
+
+// MapServices maps each endpoint of each EVC to its EK_UNI, and the interfaces and platforms
+// supporting it. EVC endpoints don't yet refer to their UNIs, so an endpoint is taken to be at the
+// EK_UNI whose entity ID, or failing that whose MEF UNI ID, is the same as the endpoint's ID.
+func MapServices(g *graph.Graph, evcs []*mefpb.EVC) ([]Service, error) {
+	unisByMefID := map[string]string{}
+	for node := range g.AllNodesOfKind("EK_UNI") {
+		if id := node.GetEntity().GetEkUni().GetMefUni().GetId().GetValue(); id != "" {
+			unisByMefID[id] = node.GetID()
+		}
+	}
+
+	services := []Service{}
+	for _, evc := range evcs {
+		service := Service{EVC: evc.GetId().GetValue(), Type: evc.GetType(), Attachments: []Attachment{}}
+		for _, ep := range evc.GetEndpoints() {
+			epID := ep.GetId().GetValue()
+			uni := epID
+			if g.Node(uni).GetKind() != "EK_UNI" {
+				uni = unisByMefID[epID]
+			}
+			if uni == "" {
+				return nil, fmt.Errorf("EVC '%v': no EK_UNI for endpoint '%v'", service.EVC, epID)
+			}
+			service.Attachments = append(service.Attachments, attach(g, epID, ep.GetRole(), uni))
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// attach returns the attachment of an endpoint to the EK_UNI with the given ID.
+func attach(g *graph.Graph, endpoint string, role mefpb.EVC_EndPoint_Role, uni string) Attachment {
+	a := Attachment{Endpoint: endpoint, Role: role, UNI: uni, Interfaces: []string{}, Platforms: []string{}}
+	seen := map[string]bool{uni: true}
+	queue := []string{uni}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for next := range g.OutNeighbors(id, npb.RK_RK_TRAVERSES) {
+			if !seen[next] && g.Node(next).GetKind() == "EK_INTERFACE" {
+				seen[next] = true
+				queue = append(queue, next)
+				a.Interfaces = append(a.Interfaces, next)
+				if platform := utilities.FindEncompassingPlatform(g, next); platform != "" {
+					a.Platforms = append(a.Platforms, platform)
+				}
+			}
+		}
+	}
+	slices.Sort(a.Interfaces)
+	slices.Sort(a.Platforms)
+	a.Platforms = slices.Compact(a.Platforms)
+	return a
+}
+
+// ServiceState is the state of an EVC affected by faults.
+type ServiceState string
+
+const (
+	// Degraded EVCs have lost some endpoints, but can still carry traffic between the rest.
+	Degraded ServiceState = "degraded"
+
+	// Down EVCs can no longer carry traffic between any of their endpoints.
+	Down ServiceState = "down"
+)
+
+// ServiceEffect is an EVC affected by faults.
+type ServiceEffect struct {
+	// EVC is the ID of the EVC, and State its state.
+	EVC   string
+	State ServiceState
+
+	// Endpoints holds the IDs of the EVC's endpoints whose UNIs lost connectivity, sorted.
+	Endpoints []string
+}
+
+// ServiceImpact holds the effects of faults on services.
+type ServiceImpact struct {
+	// UNIs holds the IDs of the services' UNIs that lost connectivity, sorted.
+	UNIs []string
+
+	// EVCs holds the EVCs that are degraded or down, sorted by ID.
+	EVCs []ServiceEffect
+}
+
+// Services returns the effects of the impact on the given services. A UNI loses connectivity if it's
+// down or any of the interfaces supporting it is; link aggregation isn't modelled, so a UNI relies
+// on each of them. An EVC is down if fewer than two of its endpoints remain, or, for a
+// rooted-multipoint EVC, if none of its roots or none of its leaves remain; it's degraded if it has
+// lost any other endpoints.
+func (i *Impact) Services(services []Service) *ServiceImpact {
+	down := map[string]bool{}
+	for _, id := range i.Down() {
+		down[id] = true
+	}
+
+	si := &ServiceImpact{UNIs: []string{}, EVCs: []ServiceEffect{}}
+	lost := map[string]bool{}
+	for _, service := range services {
+		effect := ServiceEffect{EVC: service.EVC, Endpoints: []string{}}
+		remaining := map[mefpb.EVC_EndPoint_Role]int{}
+		for _, a := range service.Attachments {
+			if down[a.UNI] || slices.ContainsFunc(a.Interfaces, func(id string) bool { return down[id] }) {
+				effect.Endpoints = append(effect.Endpoints, a.Endpoint)
+				lost[a.UNI] = true
+			} else {
+				remaining[a.Role]++
+			}
+		}
+		if len(effect.Endpoints) == 0 {
+			continue
+		}
+
+		effect.State = Degraded
+		switch service.Type {
+		case mefpb.EVC_TYPE_EPTREE, mefpb.EVC_TYPE_EVPTREE:
+			if remaining[mefpb.EVC_EndPoint_ROLE_ROOT] == 0 || remaining[mefpb.EVC_EndPoint_ROLE_LEAF] == 0 {
+				effect.State = Down
+			}
+		default:
+			if len(service.Attachments)-len(effect.Endpoints) < 2 {
+				effect.State = Down
+			}
+		}
+		slices.Sort(effect.Endpoints)
+		si.EVCs = append(si.EVCs, effect)
+	}
+
+	for uni := range lost {
+		si.UNIs = append(si.UNIs, uni)
+	}
+	slices.Sort(si.UNIs)
+	slices.SortStableFunc(si.EVCs, func(l, r ServiceEffect) int {
+		return cmp.Compare(l.EVC, r.EVC)
+	})
+	return si
+}
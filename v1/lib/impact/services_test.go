@@ -0,0 +1,160 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impact_test
+
+import (
+	"fmt"
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
+
+	"outernetcouncil.org/nmts/v1/lib/impact"
+	testutil "outernetcouncil.org/nmts/v1/lib/utilities/testing"
+	mefpb "outernetcouncil.org/nmts/v1/proto/types/mef"
+)
+
+// Sites a, b and c each have a platform holding a network node with a UNI over an interface; a's
+// UNI is over a VLAN interface over an Ethernet one, and b's UNI is known by its MEF ID.
+const servicesFragment = `
+entity { id: "a" ek_platform{} }
+entity { id: "a/node" ek_network_node{} }
+entity { id: "a/eth" ek_interface{ eth{} } }
+entity { id: "a/vlan" ek_interface{ cvlan{} } }
+entity { id: "a/uni" ek_uni{ mef_uni{} } }
+relationship { a: "a" kind: RK_CONTAINS z: "a/node" }
+relationship { a: "a/node" kind: RK_CONTAINS z: "a/eth" }
+relationship { a: "a/node" kind: RK_CONTAINS z: "a/vlan" }
+relationship { a: "a/vlan" kind: RK_TRAVERSES z: "a/eth" }
+relationship { a: "a/uni" kind: RK_TRAVERSES z: "a/vlan" }
+
+entity { id: "b" ek_platform{} }
+entity { id: "b/node" ek_network_node{} }
+entity { id: "b/eth" ek_interface{ eth{} } }
+entity { id: "b/uni" ek_uni{ mef_uni{ id{ value: "UNI-B" } } } }
+relationship { a: "b" kind: RK_CONTAINS z: "b/node" }
+relationship { a: "b/node" kind: RK_CONTAINS z: "b/eth" }
+relationship { a: "b/uni" kind: RK_TRAVERSES z: "b/eth" }
+
+entity { id: "c" ek_platform{} }
+entity { id: "c/node" ek_network_node{} }
+entity { id: "c/eth" ek_interface{ eth{} } }
+entity { id: "c/uni" ek_uni{ mef_uni{} } }
+relationship { a: "c" kind: RK_CONTAINS z: "c/node" }
+relationship { a: "c/node" kind: RK_CONTAINS z: "c/eth" }
+relationship { a: "c/uni" kind: RK_TRAVERSES z: "c/eth" }
+`
+
+func evc(id string, evcType mefpb.EVC_Type, endpoints ...string) *mefpb.EVC {
+	e := &mefpb.EVC{Id: &mefpb.Identifier45{Value: id}, Type: evcType}
+	for i, ep := range endpoints {
+		role := mefpb.EVC_EndPoint_ROLE_LEAF
+		if i == 0 {
+			role = mefpb.EVC_EndPoint_ROLE_ROOT
+		}
+		e.Endpoints = append(e.Endpoints, &mefpb.EVC_EndPoint{Id: &mefpb.Identifier45{Value: ep}, Role: role})
+	}
+	return e
+}
+
+var evcs = []*mefpb.EVC{
+	evc("line", mefpb.EVC_TYPE_EPL, "a/uni", "UNI-B"),
+	evc("lan", mefpb.EVC_TYPE_EVPLAN, "a/uni", "UNI-B", "c/uni"),
+	evc("tree", mefpb.EVC_TYPE_EPTREE, "a/uni", "UNI-B", "c/uni"),
+}
+
+func TestMapServices(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(servicesFragment))))
+	services, err := impact.MapServices(g, evcs[:1])
+	if err != nil {
+		t.Fatalf("MapServices: %v", err)
+	}
+	want := []impact.Service{{
+		EVC:  "line",
+		Type: mefpb.EVC_TYPE_EPL,
+		Attachments: []impact.Attachment{
+			{Endpoint: "a/uni", Role: mefpb.EVC_EndPoint_ROLE_ROOT, UNI: "a/uni", Interfaces: []string{"a/eth", "a/vlan"}, Platforms: []string{"a"}},
+			{Endpoint: "UNI-B", Role: mefpb.EVC_EndPoint_ROLE_LEAF, UNI: "b/uni", Interfaces: []string{"b/eth"}, Platforms: []string{"b"}},
+		},
+	}}
+	if diff := gcmp.Diff(want, services); diff != "" {
+		t.Errorf("unexpected services (-want +got): %s", diff)
+	}
+
+	_, err = impact.MapServices(g, []*mefpb.EVC{evc("broken", mefpb.EVC_TYPE_EPL, "a/uni", "a/node")})
+	if want := "EVC 'broken': no EK_UNI for endpoint 'a/node'"; err == nil || err.Error() != want {
+		t.Errorf("MapServices returned error %v; want %q", err, want)
+	}
+}
+
+func TestServices(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(servicesFragment))))
+	services, err := impact.MapServices(g, evcs)
+	if err != nil {
+		t.Fatalf("MapServices: %v", err)
+	}
+	for _, tc := range []struct {
+		failed []string
+		want   *impact.ServiceImpact
+	}{
+		{
+			failed: []string{"b"},
+			want: &impact.ServiceImpact{
+				UNIs: []string{"b/uni"},
+				EVCs: []impact.ServiceEffect{
+					{EVC: "lan", State: impact.Degraded, Endpoints: []string{"UNI-B"}},
+					{EVC: "line", State: impact.Down, Endpoints: []string{"UNI-B"}},
+					{EVC: "tree", State: impact.Degraded, Endpoints: []string{"UNI-B"}},
+				},
+			},
+		},
+		{
+			failed: []string{"a/eth"},
+			want: &impact.ServiceImpact{
+				UNIs: []string{"a/uni"},
+				EVCs: []impact.ServiceEffect{
+					{EVC: "lan", State: impact.Degraded, Endpoints: []string{"a/uni"}},
+					{EVC: "line", State: impact.Down, Endpoints: []string{"a/uni"}},
+					{EVC: "tree", State: impact.Down, Endpoints: []string{"a/uni"}},
+				},
+			},
+		},
+		{
+			failed: []string{"b/uni", "c/node"},
+			want: &impact.ServiceImpact{
+				UNIs: []string{"b/uni", "c/uni"},
+				EVCs: []impact.ServiceEffect{
+					{EVC: "lan", State: impact.Down, Endpoints: []string{"UNI-B", "c/uni"}},
+					{EVC: "line", State: impact.Down, Endpoints: []string{"UNI-B"}},
+					{EVC: "tree", State: impact.Down, Endpoints: []string{"UNI-B", "c/uni"}},
+				},
+			},
+		},
+		{
+			failed: []string{},
+			want:   &impact.ServiceImpact{UNIs: []string{}, EVCs: []impact.ServiceEffect{}},
+		},
+	} {
+		t.Run(fmt.Sprint(tc.failed), func(t *testing.T) {
+			i, err := impact.Propagate(g, tc.failed)
+			if err != nil {
+				t.Fatalf("Propagate: %v", err)
+			}
+			if diff := gcmp.Diff(tc.want, i.Services(services)); diff != "" {
+				t.Errorf("unexpected service impact (-want +got): %s", diff)
+			}
+		})
+	}
+}
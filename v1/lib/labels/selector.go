@@ -0,0 +1,298 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package labels provides selectors over Entity.labels, using the
+// Kubernetes label selector syntax, e.g.:
+//
+//	env=prod,region in (us-east,us-west),!deprecated
+//
+// A selector is a comma-separated list of requirements, all of which must
+// hold. The supported requirements are:
+//
+//	key=value, key==value   the label is set to value
+//	key!=value              the label is unset, or set to another value
+//	key in (v1,v2)          the label is set to one of the values
+//	key notin (v1,v2)       the label is unset, or set to none of the values
+//	key                     the label is set
+//	!key                    the label is unset
+//
+// Keys and values consist of letters, digits and the characters "-_./:".
+// As an extension, a value may instead be a double-quoted Go string, for
+// values containing other characters, e.g. display_name="GEO 1".
+package labels
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Operator is the kind of condition a Requirement places on a label.
+type Operator int
+
+const (
+	Equals Operator = iota
+	NotEquals
+	In
+	NotIn
+	Exists
+	DoesNotExist
+)
+
+// Requirement is a single condition on one label.
+type Requirement struct {
+	Key      string
+	Operator Operator
+
+	// Values holds the single value for Equals and NotEquals, the set of
+	// values for In and NotIn, and nothing otherwise.
+	Values []string
+}
+
+// Matches returns whether the given labels satisfy the requirement.
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, exists := labels[r.Key]
+	switch r.Operator {
+	case Equals, In:
+		return exists && slices.Contains(r.Values, value)
+	case NotEquals, NotIn:
+		return !exists || !slices.Contains(r.Values, value)
+	case Exists:
+		return exists
+	case DoesNotExist:
+		return !exists
+	default:
+		return false
+	}
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case Equals:
+		return r.Key + "=" + formatValue(r.Values[0])
+	case NotEquals:
+		return r.Key + "!=" + formatValue(r.Values[0])
+	case In, NotIn:
+		op := " in "
+		if r.Operator == NotIn {
+			op = " notin "
+		}
+		values := make([]string, len(r.Values))
+		for i, v := range r.Values {
+			values[i] = formatValue(v)
+		}
+		return r.Key + op + "(" + strings.Join(values, ",") + ")"
+	case DoesNotExist:
+		return "!" + r.Key
+	default:
+		return r.Key
+	}
+}
+
+// Selector is a conjunction of Requirements. The empty Selector matches
+// everything.
+type Selector []Requirement
+
+// Matches returns whether the given labels satisfy every requirement.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Empty returns whether the selector has no requirements, and so matches
+// everything.
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+// String returns the selector in the syntax accepted by Parse.
+func (s Selector) String() string {
+	reqs := make([]string, len(s))
+	for i, r := range s {
+		reqs[i] = r.String()
+	}
+	return strings.Join(reqs, ",")
+}
+
+// Parse parses a selector. The empty string yields the empty Selector.
+func Parse(selector string) (Selector, error) {
+	p := &parser{input: selector}
+	sel, err := p.parseSelector()
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector '%v': %w", selector, err)
+	}
+	return sel, nil
+}
+
+func isLabelRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_./:", r)
+}
+
+func formatValue(v string) string {
+	if v != "" && strings.IndexFunc(v, func(r rune) bool { return !isLabelRune(r) }) < 0 {
+		return v
+	}
+	return strconv.Quote(v)
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) atEnd() bool {
+	p.skipSpace()
+	return p.pos == len(p.input)
+}
+
+// consume skips whitespace and, if the input continues with token,
+// consumes it.
+func (p *parser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// word consumes a run of label characters, which may be empty.
+func (p *parser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) {
+		r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		if !isLabelRune(r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *parser) key() (string, error) {
+	key := p.word()
+	if key == "" {
+		return "", p.errorf("expected a label key")
+	}
+	return key, nil
+}
+
+func (p *parser) value() (string, error) {
+	p.skipSpace()
+	if p.pos < len(p.input) && p.input[p.pos] == '"' {
+		quoted, err := strconv.QuotedPrefix(p.input[p.pos:])
+		if err != nil {
+			return "", p.errorf("malformed quoted value")
+		}
+		p.pos += len(quoted)
+		return strconv.Unquote(quoted)
+	}
+	return p.word(), nil
+}
+
+func (p *parser) parseSelector() (Selector, error) {
+	sel := Selector{}
+	if p.atEnd() {
+		return sel, nil
+	}
+	for {
+		r, err := p.parseRequirement()
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, r)
+
+		if p.atEnd() {
+			return sel, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected ',' or end of selector")
+		}
+	}
+}
+
+func (p *parser) parseRequirement() (Requirement, error) {
+	if p.consume("!") {
+		key, err := p.key()
+		return Requirement{Key: key, Operator: DoesNotExist}, err
+	}
+
+	key, err := p.key()
+	if err != nil {
+		return Requirement{}, err
+	}
+
+	var op Operator
+	switch {
+	case p.consume("=="), p.consume("="):
+		op = Equals
+	case p.consume("!="):
+		op = NotEquals
+	default:
+		p.skipSpace()
+		save := p.pos
+		switch p.word() {
+		case "in":
+			op = In
+		case "notin":
+			op = NotIn
+		default:
+			p.pos = save
+			return Requirement{Key: key, Operator: Exists}, nil
+		}
+		values, err := p.parseValueSet()
+		return Requirement{Key: key, Operator: op, Values: values}, err
+	}
+
+	value, err := p.value()
+	return Requirement{Key: key, Operator: op, Values: []string{value}}, err
+}
+
+func (p *parser) parseValueSet() ([]string, error) {
+	if !p.consume("(") {
+		return nil, p.errorf("expected '('")
+	}
+	values := []string{}
+	for {
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		if p.consume(")") {
+			return values, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected ',' or ')'")
+		}
+	}
+}
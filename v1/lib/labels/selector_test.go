@@ -0,0 +1,129 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labels_test
+
+import (
+	"testing"
+
+	"outernetcouncil.org/nmts/v1/lib/labels"
+)
+
+var prodUSEast = map[string]string{"env": "prod", "region": "us-east"}
+var prodEU = map[string]string{"env": "prod", "region": "eu-west"}
+var devDeprecated = map[string]string{"env": "dev", "deprecated": ""}
+var named = map[string]string{"display_name": "GEO 1"}
+
+var selectorTestCases = []struct {
+	selector  string
+	canonical string
+	matches   []map[string]string
+	rejects   []map[string]string
+}{
+	{
+		selector:  "",
+		canonical: "",
+		matches:   []map[string]string{prodUSEast, devDeprecated, nil},
+	},
+	{
+		selector:  "env=prod",
+		canonical: "env=prod",
+		matches:   []map[string]string{prodUSEast, prodEU},
+		rejects:   []map[string]string{devDeprecated, nil},
+	},
+	{
+		selector:  "env == prod",
+		canonical: "env=prod",
+		matches:   []map[string]string{prodUSEast},
+		rejects:   []map[string]string{devDeprecated},
+	},
+	{
+		selector:  "env!=prod",
+		canonical: "env!=prod",
+		matches:   []map[string]string{devDeprecated, nil},
+		rejects:   []map[string]string{prodUSEast},
+	},
+	{
+		selector:  "env=prod,region in (us-east,us-west),!deprecated",
+		canonical: "env=prod,region in (us-east,us-west),!deprecated",
+		matches:   []map[string]string{prodUSEast},
+		rejects:   []map[string]string{prodEU, devDeprecated},
+	},
+	{
+		selector:  "region notin ( us-east , us-west )",
+		canonical: "region notin (us-east,us-west)",
+		matches:   []map[string]string{prodEU, devDeprecated},
+		rejects:   []map[string]string{prodUSEast},
+	},
+	{
+		selector:  "deprecated",
+		canonical: "deprecated",
+		matches:   []map[string]string{devDeprecated},
+		rejects:   []map[string]string{prodUSEast},
+	},
+	{
+		selector:  "deprecated=",
+		canonical: `deprecated=""`,
+		matches:   []map[string]string{devDeprecated},
+		rejects:   []map[string]string{prodUSEast},
+	},
+	{
+		selector:  `display_name="GEO 1"`,
+		canonical: `display_name="GEO 1"`,
+		matches:   []map[string]string{named},
+		rejects:   []map[string]string{prodUSEast},
+	},
+}
+
+func TestSelectors(t *testing.T) {
+	for _, tc := range selectorTestCases {
+		t.Run(tc.selector, func(t *testing.T) {
+			sel, err := labels.Parse(tc.selector)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := sel.String(); got != tc.canonical {
+				t.Errorf("String: wanted %q, got %q", tc.canonical, got)
+			}
+			for _, l := range tc.matches {
+				if !sel.Matches(l) {
+					t.Errorf("wanted a match for %v", l)
+				}
+			}
+			for _, l := range tc.rejects {
+				if sel.Matches(l) {
+					t.Errorf("unexpected match for %v", l)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, selector := range []string{
+		",",
+		"env=prod,",
+		"!",
+		"env prod",
+		"region in us-east",
+		"region in (us-east",
+		"region in (us-east us-west)",
+		`name="unterminated`,
+		"env=(prod)",
+	} {
+		if sel, err := labels.Parse(selector); err == nil {
+			t.Errorf("Parse(%q) unexpectedly succeeded: %v", selector, sel)
+		}
+	}
+}
@@ -0,0 +1,519 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pattern matches paths through a graph.Graph against patterns of
+// entity and relationship kinds, e.g.:
+//
+//	EK_NETWORK_NODE -[RK_CONTAINS]-> EK_INTERFACE -[RK_TRAVERSES]-> EK_PORT <-[RK_ORIGINATES]- EK_MODULATOR
+//
+// A pattern is a sequence of nodes joined by edges. A node is an entity
+// kind, or "*" for an entity of any kind, optionally preceded by a variable
+// name and a colon, e.g. "port:EK_PORT". A node may only be bound to an
+// entity in the graph, and every node with the same variable name must be
+// bound to the same entity.
+//
+// An edge gives the direction of the relationships it follows:
+//
+//	-[...]->   from the node on the left (A) to the node on the right (Z)
+//	<-[...]-   from the node on the right to the node on the left
+//	-[...]-    in either direction
+//
+// Between the brackets is a list of relationship kinds separated by "|",
+// e.g. "RK_ORIGINATES|RK_TERMINATES", or nothing for relationships of any
+// kind, followed by an optional repetition:
+//
+//	?        zero or one hops, i.e. an optional hop
+//	*        zero or more hops
+//	+        one or more hops
+//	{n}      exactly n hops
+//	{m,n}    between m and n hops
+//	{m,}     m or more hops
+//
+// The entities passed through by a repeated edge may be of any kind, and
+// aren't bound. An edge repeated zero times binds the nodes on either side
+// of it to the same entity.
+package pattern
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// Direction is the direction of the relationships an edge follows.
+type Direction int
+
+const (
+	// Forward edges follow relationships from A to Z.
+	Forward Direction = iota
+	// Backward edges follow relationships from Z to A.
+	Backward
+	// Either edges follow relationships in either direction.
+	Either
+)
+
+// Node is a node of a pattern.
+type Node struct {
+	// Var is the name of the variable bound to the node, or empty.
+	Var string
+
+	// Kind is the entity kind of the node, e.g. "EK_PORT", or empty for any kind.
+	Kind string
+}
+
+func (n Node) String() string {
+	kind := n.Kind
+	if kind == "" {
+		kind = "*"
+	}
+	if n.Var == "" {
+		return kind
+	}
+	return n.Var + ":" + kind
+}
+
+// Edge is an edge of a pattern, joining the node before it to the node after it.
+type Edge struct {
+	Direction Direction
+
+	// Kinds holds the kinds of relationship the edge follows, or nothing for any kind.
+	Kinds []npb.RK
+
+	// Min and Max are the least and greatest number of hops the edge may make. Max is -1 if the
+	// number of hops is unbounded.
+	Min, Max int
+}
+
+func (e Edge) String() string {
+	kinds := make([]string, len(e.Kinds))
+	for i, rk := range e.Kinds {
+		kinds[i] = rk.String()
+	}
+	spec := strings.Join(kinds, "|")
+	switch {
+	case e.Min == 1 && e.Max == 1:
+	case e.Min == 0 && e.Max == 1:
+		spec += "?"
+	case e.Min == 0 && e.Max == -1:
+		spec += "*"
+	case e.Min == 1 && e.Max == -1:
+		spec += "+"
+	case e.Min == e.Max:
+		spec += fmt.Sprintf("{%d}", e.Min)
+	case e.Max == -1:
+		spec += fmt.Sprintf("{%d,}", e.Min)
+	default:
+		spec += fmt.Sprintf("{%d,%d}", e.Min, e.Max)
+	}
+
+	switch e.Direction {
+	case Backward:
+		return "<-[" + spec + "]-"
+	case Either:
+		return "-[" + spec + "]-"
+	default:
+		return "-[" + spec + "]->"
+	}
+}
+
+// Pattern is a compiled pattern.
+type Pattern struct {
+	// Nodes holds the nodes of the pattern, in order.
+	Nodes []Node
+
+	// Edges holds the edges of the pattern, Edges[i] joining Nodes[i] to Nodes[i+1].
+	Edges []Edge
+}
+
+// String returns the pattern in the syntax accepted by Compile.
+func (p *Pattern) String() string {
+	var sb strings.Builder
+	for i, node := range p.Nodes {
+		if i > 0 {
+			sb.WriteString(" " + p.Edges[i-1].String() + " ")
+		}
+		sb.WriteString(node.String())
+	}
+	return sb.String()
+}
+
+// Vars returns the names of the pattern's variables, in the order they first appear.
+func (p *Pattern) Vars() []string {
+	vars := []string{}
+	for _, node := range p.Nodes {
+		if node.Var != "" && !slices.Contains(vars, node.Var) {
+			vars = append(vars, node.Var)
+		}
+	}
+	return vars
+}
+
+// Binding is a match of a pattern in a graph.
+type Binding struct {
+	// IDs holds the ID of the entity bound to each node of the pattern, in order.
+	IDs []string
+
+	// Vars maps the name of each of the pattern's variables to the ID of the entity bound to it.
+	Vars map[string]string `json:",omitempty"`
+}
+
+// Match returns every binding of the pattern's nodes to entities in g, ordered by the IDs bound.
+func (p *Pattern) Match(g *graph.Graph) []Binding {
+	bindings := []Binding{}
+	ids := make([]string, len(p.Nodes))
+
+	var extend func(i int, id string)
+	extend = func(i int, id string) {
+		if !p.Nodes[i].matches(g, id) || !p.consistent(ids[:i], i, id) {
+			return
+		}
+		ids[i] = id
+		if i == len(p.Nodes)-1 {
+			bindings = append(bindings, p.bind(ids))
+			return
+		}
+		for _, next := range p.Edges[i].reach(g, id) {
+			extend(i+1, next)
+		}
+	}
+
+	for _, id := range p.Nodes[0].candidates(g) {
+		extend(0, id)
+	}
+	return bindings
+}
+
+// consistent returns true if binding node i to id agrees with the nodes already bound to the same
+// variable.
+func (p *Pattern) consistent(bound []string, i int, id string) bool {
+	if p.Nodes[i].Var == "" {
+		return true
+	}
+	for j, other := range bound {
+		if p.Nodes[j].Var == p.Nodes[i].Var && other != id {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Pattern) bind(ids []string) Binding {
+	b := Binding{IDs: slices.Clone(ids)}
+	for i, node := range p.Nodes {
+		if node.Var != "" {
+			if b.Vars == nil {
+				b.Vars = map[string]string{}
+			}
+			b.Vars[node.Var] = ids[i]
+		}
+	}
+	return b
+}
+
+func (n Node) matches(g *graph.Graph, id string) bool {
+	node := g.Node(id)
+	return node != nil && (n.Kind == "" || node.GetKind() == n.Kind)
+}
+
+// candidates returns the IDs of the entities the node might be bound to, sorted.
+func (n Node) candidates(g *graph.Graph) []string {
+	ids := []string{}
+	if n.Kind != "" {
+		for node := range g.AllNodesOfKind(n.Kind) {
+			ids = append(ids, node.GetID())
+		}
+	} else {
+		for node := range g.AllNodesMatching(nil) {
+			ids = append(ids, node.GetID())
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// reach returns the IDs of the entities the edge leads to from the entity with ID from, sorted.
+func (e Edge) reach(g *graph.Graph, from string) []string {
+	type state struct {
+		id   string
+		hops int
+	}
+	// Once a walk has made Min hops, how many more it makes only matters if Max is bounded.
+	clamp := func(hops int) int {
+		if e.Max < 0 && hops > e.Min {
+			return e.Min
+		}
+		return hops
+	}
+
+	start := state{id: from}
+	seen := map[state]bool{start: true}
+	queue := []state{start}
+	reached := map[string]bool{}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if s.hops >= e.Min {
+			reached[s.id] = true
+		}
+		if s.hops == e.Max {
+			continue
+		}
+		for _, next := range e.neighbors(g, s.id) {
+			n := state{id: next, hops: clamp(s.hops + 1)}
+			if !seen[n] {
+				seen[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(reached))
+}
+
+// neighbors returns the IDs of the entities one hop along the edge from the entity with ID id.
+func (e Edge) neighbors(g *graph.Graph, id string) []string {
+	kinds := e.Kinds
+	if len(kinds) == 0 {
+		kinds = []npb.RK{npb.RK_RK_UNSPECIFIED}
+	}
+	neighbors := []string{}
+	for _, rk := range kinds {
+		if e.Direction != Backward {
+			for neighbor := range g.OutNeighbors(id, rk) {
+				neighbors = append(neighbors, neighbor)
+			}
+		}
+		if e.Direction != Forward {
+			for neighbor := range g.InNeighbors(id, rk) {
+				neighbors = append(neighbors, neighbor)
+			}
+		}
+	}
+	return slices.DeleteFunc(neighbors, func(neighbor string) bool {
+		return g.Node(neighbor) == nil
+	})
+}
+
+// Compile parses a pattern.
+func Compile(pattern string) (*Pattern, error) {
+	p := &parser{input: pattern}
+	compiled, err := p.parsePattern()
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern '%v': %w", pattern, err)
+	}
+	return compiled, nil
+}
+
+// entityKinds holds the names of the entity kinds, e.g. "EK_PORT".
+var entityKinds = func() map[string]bool {
+	kinds := map[string]bool{}
+	fields := (&npb.Entity{}).ProtoReflect().Descriptor().Oneofs().ByName("kind").Fields()
+	for i := range fields.Len() {
+		kinds[strings.ToUpper(string(fields.Get(i).Name()))] = true
+	}
+	return kinds
+}()
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) atEnd() bool {
+	p.skipSpace()
+	return p.pos == len(p.input)
+}
+
+// consume skips whitespace and, if the input continues with token,
+// consumes it.
+func (p *parser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// word consumes a run of letters, digits and underscores, which may be empty.
+func (p *parser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if !(c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			break
+		}
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *parser) number() (int, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && '0' <= p.input[p.pos] && p.input[p.pos] <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, p.errorf("expected a number")
+	}
+	return strconv.Atoi(p.input[start:p.pos])
+}
+
+func (p *parser) parsePattern() (*Pattern, error) {
+	pattern := &Pattern{}
+	for {
+		node, err := p.parseNode()
+		if err != nil {
+			return nil, err
+		}
+		pattern.Nodes = append(pattern.Nodes, node)
+		if p.atEnd() {
+			return pattern, nil
+		}
+
+		edge, err := p.parseEdge()
+		if err != nil {
+			return nil, err
+		}
+		pattern.Edges = append(pattern.Edges, edge)
+	}
+}
+
+func (p *parser) parseNode() (Node, error) {
+	node := Node{}
+	save := p.pos
+	if name := p.word(); name != "" && p.consume(":") {
+		node.Var = name
+	} else {
+		p.pos = save
+	}
+
+	if p.consume("*") {
+		return node, nil
+	}
+	kind := p.word()
+	if kind == "" {
+		return Node{}, p.errorf("expected an entity kind or '*'")
+	}
+	if !entityKinds[kind] {
+		return Node{}, p.errorf("unknown entity kind '%v'", kind)
+	}
+	node.Kind = kind
+	return node, nil
+}
+
+func (p *parser) parseEdge() (Edge, error) {
+	edge := Edge{Direction: Forward}
+	switch {
+	case p.consume("<-["):
+		edge.Direction = Backward
+	case p.consume("-["):
+	default:
+		return Edge{}, p.errorf("expected '-[', '<-[' or end of pattern")
+	}
+
+	if err := p.parseKinds(&edge); err != nil {
+		return Edge{}, err
+	}
+	if err := p.parseRepetition(&edge); err != nil {
+		return Edge{}, err
+	}
+	if !p.consume("]") {
+		return Edge{}, p.errorf("expected ']'")
+	}
+
+	switch {
+	case edge.Direction == Backward:
+		if !p.consume("-") {
+			return Edge{}, p.errorf("expected '-'")
+		}
+	case p.consume("->"):
+	case p.consume("-"):
+		edge.Direction = Either
+	default:
+		return Edge{}, p.errorf("expected '->' or '-'")
+	}
+	return edge, nil
+}
+
+func (p *parser) parseKinds(edge *Edge) error {
+	kind := p.word()
+	if kind == "" {
+		return nil
+	}
+	for {
+		rk, err := er.ParseRelationshipKind(kind)
+		if err != nil {
+			return p.errorf("%v", err)
+		}
+		edge.Kinds = append(edge.Kinds, rk)
+		if !p.consume("|") {
+			return nil
+		}
+		if kind = p.word(); kind == "" {
+			return p.errorf("expected a relationship kind")
+		}
+	}
+}
+
+func (p *parser) parseRepetition(edge *Edge) error {
+	edge.Min, edge.Max = 1, 1
+	switch {
+	case p.consume("?"):
+		edge.Min = 0
+	case p.consume("*"):
+		edge.Min, edge.Max = 0, -1
+	case p.consume("+"):
+		edge.Max = -1
+	case p.consume("{"):
+		min, err := p.number()
+		if err != nil {
+			return err
+		}
+		max := min
+		if p.consume(",") {
+			max = -1
+			if p.skipSpace(); !strings.HasPrefix(p.input[p.pos:], "}") {
+				if max, err = p.number(); err != nil {
+					return err
+				}
+			}
+		}
+		if !p.consume("}") {
+			return p.errorf("expected '}'")
+		}
+		if max == 0 || max > 0 && max < min {
+			return p.errorf("invalid repetition {%d,%d}", min, max)
+		}
+		edge.Min, edge.Max = min, max
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pattern_test
+
+import (
+	"strings"
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
+
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	"outernetcouncil.org/nmts/v1/lib/pattern"
+	testutil "outernetcouncil.org/nmts/v1/lib/utilities/testing"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// A node with a tunnel interface stacked on a radio interface, which traverses a transmitting port,
+// and a second radio interface traversing a receiving port.
+const testFragment = `
+entity { id: "agent" ek_sdn_agent{} }
+entity { id: "node" ek_network_node{} }
+entity { id: "tunnel" ek_interface{} }
+entity { id: "radio1" ek_interface{} }
+entity { id: "radio2" ek_interface{} }
+entity { id: "tx" ek_port{} }
+entity { id: "rx" ek_port{} }
+entity { id: "mod" ek_modulator{} }
+entity { id: "demod" ek_demodulator{} }
+relationship { a: "agent" kind: RK_CONTROLS z: "node" }
+relationship { a: "node" kind: RK_CONTAINS z: "tunnel" }
+relationship { a: "node" kind: RK_CONTAINS z: "radio1" }
+relationship { a: "node" kind: RK_CONTAINS z: "radio2" }
+relationship { a: "tunnel" kind: RK_TRAVERSES z: "radio1" }
+relationship { a: "radio1" kind: RK_TRAVERSES z: "tx" }
+relationship { a: "radio2" kind: RK_TRAVERSES z: "rx" }
+relationship { a: "tx" kind: RK_ORIGINATES z: "mod" }
+relationship { a: "rx" kind: RK_TERMINATES z: "demod" }
+`
+
+// getTestGraph returns the graph of testFragment, with a relationship to an entity it lacks, since
+// patterns only bind existing entities.
+func getTestGraph() *graph.Graph {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(testFragment))))
+	lo.Must(g.AddRelationship(&npb.Relationship{A: "node", Kind: npb.RK_RK_CONTAINS, Z: "missing"}))
+	return g
+}
+
+var matchTestCases = []struct {
+	pattern   string
+	canonical string
+	// Each binding's IDs, joined by spaces.
+	want []string
+}{
+	{
+		pattern:   "EK_MODULATOR",
+		canonical: "EK_MODULATOR",
+		want:      []string{"mod"},
+	},
+	{
+		pattern:   "EK_NETWORK_NODE -[RK_CONTAINS]-> EK_INTERFACE -[RK_TRAVERSES]-> EK_PORT -[RK_ORIGINATES]-> EK_MODULATOR",
+		canonical: "EK_NETWORK_NODE -[RK_CONTAINS]-> EK_INTERFACE -[RK_TRAVERSES]-> EK_PORT -[RK_ORIGINATES]-> EK_MODULATOR",
+		want:      []string{"node radio1 tx mod"},
+	},
+	{
+		pattern:   "EK_MODULATOR<-[RK_ORIGINATES]-EK_PORT<-[RK_TRAVERSES]-EK_INTERFACE",
+		canonical: "EK_MODULATOR <-[RK_ORIGINATES]- EK_PORT <-[RK_TRAVERSES]- EK_INTERFACE",
+		want:      []string{"mod tx radio1"},
+	},
+	{
+		pattern:   "EK_PORT -[RK_ORIGINATES|RK_TERMINATES]-> *",
+		canonical: "EK_PORT -[RK_ORIGINATES|RK_TERMINATES]-> *",
+		want:      []string{"rx demod", "tx mod"},
+	},
+	{
+		pattern:   "EK_INTERFACE -[RK_TRAVERSES]- EK_INTERFACE",
+		canonical: "EK_INTERFACE -[RK_TRAVERSES]- EK_INTERFACE",
+		want:      []string{"radio1 tunnel", "tunnel radio1"},
+	},
+	{
+		pattern:   "EK_INTERFACE -[RK_TRAVERSES+]-> EK_PORT",
+		canonical: "EK_INTERFACE -[RK_TRAVERSES+]-> EK_PORT",
+		want:      []string{"radio1 tx", "radio2 rx", "tunnel tx"},
+	},
+	{
+		pattern:   "EK_INTERFACE -[RK_TRAVERSES{2}]-> EK_PORT",
+		canonical: "EK_INTERFACE -[RK_TRAVERSES{2}]-> EK_PORT",
+		want:      []string{"tunnel tx"},
+	},
+	{
+		pattern:   "EK_INTERFACE -[RK_TRAVERSES{ 0 , }]-> *",
+		canonical: "EK_INTERFACE -[RK_TRAVERSES*]-> *",
+		want: []string{
+			"radio1 radio1", "radio1 tx", "radio2 radio2", "radio2 rx", "tunnel radio1", "tunnel tunnel", "tunnel tx",
+		},
+	},
+	{
+		pattern:   "EK_NETWORK_NODE -[RK_CONTAINS]-> EK_INTERFACE -[RK_TRAVERSES?]-> EK_INTERFACE",
+		canonical: "EK_NETWORK_NODE -[RK_CONTAINS]-> EK_INTERFACE -[RK_TRAVERSES?]-> EK_INTERFACE",
+		want:      []string{"node radio1 radio1", "node radio2 radio2", "node tunnel radio1", "node tunnel tunnel"},
+	},
+	{
+		pattern:   "EK_SDN_AGENT -[*]-> EK_MODULATOR",
+		canonical: "EK_SDN_AGENT -[*]-> EK_MODULATOR",
+		want:      []string{"agent mod"},
+	},
+	{
+		pattern:   "EK_SDN_AGENT -[{1,2}]-> *",
+		canonical: "EK_SDN_AGENT -[{1,2}]-> *",
+		want:      []string{"agent node", "agent radio1", "agent radio2", "agent tunnel"},
+	},
+	{
+		pattern:   "x:EK_INTERFACE <-[RK_CONTAINS]- EK_NETWORK_NODE -[RK_CONTAINS]-> x:*",
+		canonical: "x:EK_INTERFACE <-[RK_CONTAINS]- EK_NETWORK_NODE -[RK_CONTAINS]-> x:*",
+		want:      []string{"radio1 node radio1", "radio2 node radio2", "tunnel node tunnel"},
+	},
+	{
+		pattern:   "EK_NETWORK_NODE -[RK_CONTAINS]-> *",
+		canonical: "EK_NETWORK_NODE -[RK_CONTAINS]-> *",
+		want:      []string{"node radio1", "node radio2", "node tunnel"},
+	},
+}
+
+func TestMatch(t *testing.T) {
+	g := getTestGraph()
+	for _, tc := range matchTestCases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			p, err := pattern.Compile(tc.pattern)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			if got := p.String(); got != tc.canonical {
+				t.Errorf("String() = %q; want %q", got, tc.canonical)
+			}
+
+			got := []string{}
+			for _, b := range p.Match(g) {
+				got = append(got, strings.Join(b.IDs, " "))
+			}
+			if diff := gcmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected bindings (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestMatchBindsVars(t *testing.T) {
+	p, err := pattern.Compile("port:EK_PORT -[RK_ORIGINATES]-> mod:* <-[]- port:*")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if diff := gcmp.Diff([]string{"port", "mod"}, p.Vars()); diff != "" {
+		t.Errorf("unexpected vars (-want +got): %s", diff)
+	}
+	want := []pattern.Binding{{
+		IDs:  []string{"tx", "mod", "tx"},
+		Vars: map[string]string{"port": "tx", "mod": "mod"},
+	}}
+	if diff := gcmp.Diff(want, p.Match(getTestGraph())); diff != "" {
+		t.Errorf("unexpected bindings (-want +got): %s", diff)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for input, wantErr := range map[string]string{
+		"":                              "at offset 0: expected an entity kind or '*'",
+		"EK_BOGUS":                      "at offset 8: unknown entity kind 'EK_BOGUS'",
+		"EK_PORT -[RK_BOGUS]-> *":       "at offset 18: unknown relationship kind 'RK_BOGUS'",
+		"EK_PORT -[RK_UNSPECIFIED]-> *": "at offset 24: unknown relationship kind 'RK_UNSPECIFIED'",
+		"EK_PORT -[RK_CONTAINS|]-> *":   "at offset 22: expected a relationship kind",
+		"EK_PORT -[RK_CONTAINS]->":      "at offset 24: expected an entity kind or '*'",
+		"EK_PORT <-[RK_CONTAINS]-> *":   "at offset 24: expected an entity kind or '*'",
+		"EK_PORT -[RK_CONTAINS *":       "at offset 23: expected ']'",
+		"EK_PORT -[{2,1}]-> *":          "at offset 15: invalid repetition {2,1}",
+		"EK_PORT -[{0}]-> *":            "at offset 13: invalid repetition {0,0}",
+		"EK_PORT -[{x}]-> *":            "at offset 11: expected a number",
+		"EK_PORT EK_PORT":               "at offset 8: expected '-[', '<-[' or end of pattern",
+	} {
+		_, err := pattern.Compile(input)
+		want := "invalid pattern '" + input + "': " + wantErr
+		if err == nil || err.Error() != want {
+			t.Errorf("Compile(%q) returned error %v; want %q", input, err, want)
+		}
+	}
+}
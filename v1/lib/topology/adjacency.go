@@ -0,0 +1,217 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package topology derives network-level views from an NMTS graph, such as which network nodes
+// are neighbours at a given layer.
+package topology
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	"outernetcouncil.org/nmts/v1/lib/utilities"
+	npb "outernetcouncil.org/nmts/v1/proto"
+	logicalpb "outernetcouncil.org/nmts/v1/proto/ek/logical"
+)
+
+// Layer selects interfaces by the field set in their Interface.layer oneof, e.g. "eth" or "ip".
+type Layer []string
+
+var (
+	// L2 selects Ethernet, GSE and C-VLAN interfaces.
+	L2 = Layer{"eth", "gse", "cvlan"}
+	// L3 selects IP interfaces.
+	L3 = Layer{"ip"}
+)
+
+// interfaceLayers holds the names of the fields of the Interface.layer oneof.
+var interfaceLayers = func() []string {
+	names := []string{}
+	fields := (&logicalpb.Interface{}).ProtoReflect().Descriptor().Oneofs().ByName("layer").Fields()
+	for i := range fields.Len() {
+		names = append(names, string(fields.Get(i).Name()))
+	}
+	return names
+}()
+
+// ParseLayer parses "l2", "l3", or a comma-separated list of the fields of the Interface.layer
+// oneof, e.g. "mpls" or "eth,cvlan".
+func ParseLayer(s string) (Layer, error) {
+	switch strings.ToLower(s) {
+	case "l2":
+		return L2, nil
+	case "l3":
+		return L3, nil
+	}
+	layer := Layer{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(interfaceLayers, name) {
+			return nil, fmt.Errorf("unknown layer '%v'; want l2, l3 or some of %v", name, strings.Join(interfaceLayers, ", "))
+		}
+		layer = append(layer, name)
+	}
+	return layer, nil
+}
+
+func (l Layer) String() string {
+	return strings.Join(l, ",")
+}
+
+// Matches returns true if the interface's layer is one of those selected.
+func (l Layer) Matches(iface *logicalpb.Interface) bool {
+	which := iface.ProtoReflect().WhichOneof(iface.ProtoReflect().Descriptor().Oneofs().ByName("layer"))
+	return which != nil && slices.Contains(l, string(which.Name()))
+}
+
+// Link is an EK_LOGICAL_PACKET_LINK underlying an adjacency.
+type Link struct {
+	// ID is the ID of the link.
+	ID string
+
+	// AInterface is the ID of the EK_INTERFACE that RK_ORIGINATES the link, and ZInterface the ID
+	// of the one that RK_TERMINATES it.
+	AInterface, ZInterface string
+
+	// Latency is the link's latency, or 0 if it has none.
+	Latency time.Duration
+}
+
+// Adjacency records that traffic can pass directly from one network node to another, over one or
+// more links.
+type Adjacency struct {
+	// A and Z are the IDs of the EK_NETWORK_NODEs containing the interfaces that originate and
+	// terminate the links.
+	A, Z string
+
+	// Links holds the links from A to Z, sorted by ID.
+	Links []Link
+}
+
+// Latency returns the least latency of those of the adjacency's links that have one, or 0 if none
+// do.
+func (a Adjacency) Latency() time.Duration {
+	var least time.Duration
+	for _, link := range a.Links {
+		if link.Latency > 0 && (least == 0 || link.Latency < least) {
+			least = link.Latency
+		}
+	}
+	return least
+}
+
+// AdjacencyGraph is a graph of network nodes joined by their adjacencies at a layer.
+type AdjacencyGraph struct {
+	Layer Layer
+
+	// Nodes holds the IDs of every EK_NETWORK_NODE, sorted, whether or not it has any adjacencies.
+	Nodes []string
+
+	// Adjacencies holds the adjacencies between the nodes, sorted by A and then Z.
+	Adjacencies []Adjacency
+}
+
+// Neighbors returns the IDs of the nodes adjacent to the node with the given ID, in either
+// direction, sorted.
+func (ag *AdjacencyGraph) Neighbors(id string) []string {
+	neighbors := []string{}
+	for _, adj := range ag.Adjacencies {
+		switch id {
+		case adj.A:
+			neighbors = append(neighbors, adj.Z)
+		case adj.Z:
+			neighbors = append(neighbors, adj.A)
+		}
+	}
+	slices.Sort(neighbors)
+	return slices.Compact(neighbors)
+}
+
+// DeriveAdjacencyGraph returns the network nodes of g and their adjacencies at the given layer. Two
+// nodes are adjacent if an interface of the layer contained by one RK_ORIGINATES an
+// EK_LOGICAL_PACKET_LINK that an interface of the layer contained by the other RK_TERMINATES.
+// Adjacencies are directed like the links they're derived from; links between interfaces of the
+// same node are ignored.
+func DeriveAdjacencyGraph(g *graph.Graph, layer Layer) *AdjacencyGraph {
+	ag := &AdjacencyGraph{Layer: layer, Nodes: []string{}, Adjacencies: []Adjacency{}}
+	for node := range g.AllNodesOfKind("EK_NETWORK_NODE") {
+		ag.Nodes = append(ag.Nodes, node.GetID())
+	}
+	slices.Sort(ag.Nodes)
+
+	networkNodes := map[string]string{}
+	networkNodeOf := func(ifaceID string) string {
+		if id, ok := networkNodes[ifaceID]; ok {
+			return id
+		}
+		id := utilities.FindEncompassingNetworkNode(g, ifaceID)
+		networkNodes[ifaceID] = id
+		return id
+	}
+
+	type pair struct{ a, z string }
+	adjacencies := map[pair]*Adjacency{}
+	for linkNode := range g.AllNodesOfKind("EK_LOGICAL_PACKET_LINK") {
+		linkID := linkNode.GetID()
+		origins := interfacesOf(g, linkID, npb.RK_RK_ORIGINATES, layer)
+		terminations := interfacesOf(g, linkID, npb.RK_RK_TERMINATES, layer)
+		for _, aIface := range origins {
+			for _, zIface := range terminations {
+				a, z := networkNodeOf(aIface), networkNodeOf(zIface)
+				if a == "" || z == "" || a == z {
+					continue
+				}
+				adj, ok := adjacencies[pair{a, z}]
+				if !ok {
+					adj = &Adjacency{A: a, Z: z}
+					adjacencies[pair{a, z}] = adj
+				}
+				adj.Links = append(adj.Links, Link{
+					ID:         linkID,
+					AInterface: aIface,
+					ZInterface: zIface,
+					Latency:    linkNode.GetEntity().GetEkLogicalPacketLink().GetLatency().AsDuration(),
+				})
+			}
+		}
+	}
+
+	for _, adj := range adjacencies {
+		slices.SortFunc(adj.Links, func(l, r Link) int {
+			return cmp.Or(cmp.Compare(l.ID, r.ID), cmp.Compare(l.AInterface, r.AInterface), cmp.Compare(l.ZInterface, r.ZInterface))
+		})
+		ag.Adjacencies = append(ag.Adjacencies, *adj)
+	}
+	slices.SortFunc(ag.Adjacencies, func(l, r Adjacency) int {
+		return cmp.Or(cmp.Compare(l.A, r.A), cmp.Compare(l.Z, r.Z))
+	})
+	return ag
+}
+
+// interfacesOf returns the IDs of the interfaces of the layer that have relationships of the given
+// kind with the link, sorted.
+func interfacesOf(g *graph.Graph, linkID string, rk npb.RK, layer Layer) []string {
+	ids := []string{}
+	for id := range g.InNeighbors(linkID, rk) {
+		if iface := g.Node(id).GetEntity().GetEkInterface(); iface != nil && layer.Matches(iface) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,176 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology_test
+
+import (
+	"testing"
+	"time"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
+
+	"outernetcouncil.org/nmts/v1/lib/topology"
+	testutil "outernetcouncil.org/nmts/v1/lib/utilities/testing"
+)
+
+// Routers r1 and r2 have IP interfaces over Ethernet interfaces, joined by a pair of IP links over
+// an Ethernet link, and a second, slower IP link. Switch sw is joined to r2 by an Ethernet link
+// alone, and r1's loopback link goes nowhere else.
+const adjacencyFragment = `
+entity { id: "r1" ek_network_node{} }
+entity { id: "r2" ek_network_node{} }
+entity { id: "sw" ek_network_node{} }
+entity { id: "r1/eth" ek_interface{ eth{} } }
+entity { id: "r1/ip" ek_interface{ ip{} } }
+entity { id: "r1/lo" ek_interface{ ip{} } }
+entity { id: "r2/eth0" ek_interface{ eth{} } }
+entity { id: "r2/eth1" ek_interface{ eth{} } }
+entity { id: "r2/ip" ek_interface{ ip{} } }
+entity { id: "sw/eth" ek_interface{ eth{} } }
+entity { id: "sw/mgmt" ek_interface{} }
+relationship { a: "r1" kind: RK_CONTAINS z: "r1/eth" }
+relationship { a: "r1" kind: RK_CONTAINS z: "r1/ip" }
+relationship { a: "r1" kind: RK_CONTAINS z: "r1/lo" }
+relationship { a: "r2" kind: RK_CONTAINS z: "r2/eth0" }
+relationship { a: "r2" kind: RK_CONTAINS z: "r2/eth1" }
+relationship { a: "r2" kind: RK_CONTAINS z: "r2/ip" }
+relationship { a: "sw" kind: RK_CONTAINS z: "sw/eth" }
+relationship { a: "sw" kind: RK_CONTAINS z: "sw/mgmt" }
+relationship { a: "r1/ip" kind: RK_TRAVERSES z: "r1/eth" }
+relationship { a: "r2/ip" kind: RK_TRAVERSES z: "r2/eth0" }
+
+entity { id: "r1->r2/eth" ek_logical_packet_link{ latency { nanos: 1000000 } } }
+relationship { a: "r1/eth" kind: RK_ORIGINATES z: "r1->r2/eth" }
+relationship { a: "r2/eth0" kind: RK_TERMINATES z: "r1->r2/eth" }
+entity { id: "r1->r2/ip" ek_logical_packet_link{ latency { nanos: 2000000 } } }
+relationship { a: "r1/ip" kind: RK_ORIGINATES z: "r1->r2/ip" }
+relationship { a: "r2/ip" kind: RK_TERMINATES z: "r1->r2/ip" }
+relationship { a: "r1->r2/ip" kind: RK_TRAVERSES z: "r1->r2/eth" }
+entity { id: "r1->r2/ip-slow" ek_logical_packet_link{ latency { seconds: 1 } } }
+relationship { a: "r1/ip" kind: RK_ORIGINATES z: "r1->r2/ip-slow" }
+relationship { a: "r2/ip" kind: RK_TERMINATES z: "r1->r2/ip-slow" }
+entity { id: "r2->r1/ip" ek_logical_packet_link{} }
+relationship { a: "r2/ip" kind: RK_ORIGINATES z: "r2->r1/ip" }
+relationship { a: "r1/ip" kind: RK_TERMINATES z: "r2->r1/ip" }
+entity { id: "r2->sw" ek_logical_packet_link{} }
+relationship { a: "r2/eth1" kind: RK_ORIGINATES z: "r2->sw" }
+relationship { a: "sw/eth" kind: RK_TERMINATES z: "r2->sw" }
+relationship { a: "sw/mgmt" kind: RK_TERMINATES z: "r2->sw" }
+entity { id: "r1/lo-link" ek_logical_packet_link{} }
+relationship { a: "r1/lo" kind: RK_ORIGINATES z: "r1/lo-link" }
+relationship { a: "r1/ip" kind: RK_TERMINATES z: "r1/lo-link" }
+`
+
+func TestDeriveAdjacencyGraph(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(adjacencyFragment))))
+	for _, tc := range []struct {
+		layer string
+		want  *topology.AdjacencyGraph
+	}{
+		{
+			layer: "l3",
+			want: &topology.AdjacencyGraph{
+				Layer: topology.L3,
+				Nodes: []string{"r1", "r2", "sw"},
+				Adjacencies: []topology.Adjacency{
+					{
+						A: "r1",
+						Z: "r2",
+						Links: []topology.Link{
+							{ID: "r1->r2/ip", AInterface: "r1/ip", ZInterface: "r2/ip", Latency: 2 * time.Millisecond},
+							{ID: "r1->r2/ip-slow", AInterface: "r1/ip", ZInterface: "r2/ip", Latency: time.Second},
+						},
+					},
+					{
+						A:     "r2",
+						Z:     "r1",
+						Links: []topology.Link{{ID: "r2->r1/ip", AInterface: "r2/ip", ZInterface: "r1/ip"}},
+					},
+				},
+			},
+		},
+		{
+			layer: "L2",
+			want: &topology.AdjacencyGraph{
+				Layer: topology.L2,
+				Nodes: []string{"r1", "r2", "sw"},
+				Adjacencies: []topology.Adjacency{
+					{
+						A:     "r1",
+						Z:     "r2",
+						Links: []topology.Link{{ID: "r1->r2/eth", AInterface: "r1/eth", ZInterface: "r2/eth0", Latency: time.Millisecond}},
+					},
+					{
+						A:     "r2",
+						Z:     "sw",
+						Links: []topology.Link{{ID: "r2->sw", AInterface: "r2/eth1", ZInterface: "sw/eth"}},
+					},
+				},
+			},
+		},
+		{
+			layer: "mpls",
+			want: &topology.AdjacencyGraph{
+				Layer:       topology.Layer{"mpls"},
+				Nodes:       []string{"r1", "r2", "sw"},
+				Adjacencies: []topology.Adjacency{},
+			},
+		},
+	} {
+		t.Run(tc.layer, func(t *testing.T) {
+			layer, err := topology.ParseLayer(tc.layer)
+			if err != nil {
+				t.Fatalf("ParseLayer: %v", err)
+			}
+			if diff := gcmp.Diff(tc.want, topology.DeriveAdjacencyGraph(g, layer)); diff != "" {
+				t.Errorf("unexpected adjacency graph (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestAdjacencyGraphNeighbors(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(adjacencyFragment))))
+	ag := topology.DeriveAdjacencyGraph(g, topology.L2)
+	for id, want := range map[string][]string{
+		"r1":      {"r2"},
+		"r2":      {"r1", "sw"},
+		"sw":      {"r2"},
+		"missing": {},
+	} {
+		if diff := gcmp.Diff(want, ag.Neighbors(id)); diff != "" {
+			t.Errorf("unexpected neighbors of %s (-want +got): %s", id, diff)
+		}
+	}
+}
+
+func TestAdjacencyLatency(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(adjacencyFragment))))
+	ag := topology.DeriveAdjacencyGraph(g, topology.L3)
+	if got := ag.Adjacencies[0].Latency(); got != 2*time.Millisecond {
+		t.Errorf("r1->r2 latency = %v; want 2ms", got)
+	}
+	if got := ag.Adjacencies[1].Latency(); got != 0 {
+		t.Errorf("r2->r1 latency = %v; want 0", got)
+	}
+}
+
+func TestParseLayerErrors(t *testing.T) {
+	_, err := topology.ParseLayer("eth,l4")
+	want := "unknown layer 'l4'; want l2, l3 or some of eth, gse, cvlan, mpls, ip, loopback"
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseLayer returned error %v; want %q", err, want)
+	}
+}
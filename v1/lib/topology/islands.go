@@ -0,0 +1,73 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology
+
+import (
+	"slices"
+
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	"outernetcouncil.org/nmts/v1/lib/utilities"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// Island is a connected component of a graph; see graph.Graph.Components.
+type Island struct {
+	// IDs holds the IDs of the island's entities, sorted.
+	IDs []string
+
+	// Platforms holds the IDs of the EK_PLATFORMs encompassing the island's entities, as found by
+	// utilities.FindEncompassingPlatform in the whole graph, sorted. They needn't be in the island,
+	// as when it's joined only by data-plane relationships.
+	Platforms []string
+
+	// Representative is the ID of an entity that identifies the island to a reader: its first
+	// platform, which needn't be in the island, or failing that its first network node, or failing
+	// that the entity with the most neighbours.
+	Representative string
+}
+
+// Islands returns the connected components of g, joined by relationships of the given kinds or of
+// any kind if none are given, largest first.
+func Islands(g *graph.Graph, kinds ...npb.RK) []Island {
+	islands := []Island{}
+	for _, ids := range g.Components(kinds...) {
+		island := Island{IDs: ids, Platforms: []string{}}
+		networkNode, mostNeighbors, busiest := "", -1, ""
+		for _, id := range ids {
+			if platform := utilities.FindEncompassingPlatform(g, id); platform != "" {
+				island.Platforms = append(island.Platforms, platform)
+			}
+			if networkNode == "" && g.Node(id).GetKind() == "EK_NETWORK_NODE" {
+				networkNode = id
+			}
+			if n := len(g.Neighbors(id)); n > mostNeighbors {
+				mostNeighbors, busiest = n, id
+			}
+		}
+		slices.Sort(island.Platforms)
+		island.Platforms = slices.Compact(island.Platforms)
+
+		switch {
+		case len(island.Platforms) > 0:
+			island.Representative = island.Platforms[0]
+		case networkNode != "":
+			island.Representative = networkNode
+		default:
+			island.Representative = busiest
+		}
+		islands = append(islands, island)
+	}
+	return islands
+}
@@ -0,0 +1,129 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology_test
+
+import (
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
+
+	"outernetcouncil.org/nmts/v1/lib/topology"
+	testutil "outernetcouncil.org/nmts/v1/lib/utilities/testing"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// Ground station gs1 is linked to satellite sat1, but gs2's link fragment is missing, leaving it an
+// island. An agent controls gs2's node, and a pair of interfaces traverse a port and nothing else.
+const islandsFragment = `
+entity { id: "gs1" ek_platform{} }
+entity { id: "gs1/node" ek_network_node{} }
+entity { id: "gs1/ant" ek_interface{} }
+entity { id: "sat1" ek_platform{} }
+entity { id: "sat1/node" ek_network_node{} }
+entity { id: "sat1/ant" ek_interface{} }
+entity { id: "gs1->sat1" ek_logical_packet_link{} }
+entity { id: "gs2" ek_platform{} }
+entity { id: "gs2/node" ek_network_node{} }
+entity { id: "agent" ek_sdn_agent{} }
+entity { id: "if1" ek_interface{} }
+entity { id: "if2" ek_interface{} }
+entity { id: "port" ek_port{} }
+relationship { a: "gs1" kind: RK_CONTAINS z: "gs1/node" }
+relationship { a: "gs1/node" kind: RK_CONTAINS z: "gs1/ant" }
+relationship { a: "sat1" kind: RK_CONTAINS z: "sat1/node" }
+relationship { a: "sat1/node" kind: RK_CONTAINS z: "sat1/ant" }
+relationship { a: "gs1/ant" kind: RK_ORIGINATES z: "gs1->sat1" }
+relationship { a: "sat1/ant" kind: RK_TERMINATES z: "gs1->sat1" }
+relationship { a: "gs2" kind: RK_CONTAINS z: "gs2/node" }
+relationship { a: "agent" kind: RK_CONTROLS z: "gs2/node" }
+relationship { a: "if1" kind: RK_TRAVERSES z: "port" }
+relationship { a: "if2" kind: RK_TRAVERSES z: "port" }
+`
+
+func TestIslands(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(islandsFragment))))
+	for _, tc := range []struct {
+		desc  string
+		kinds []npb.RK
+		want  []topology.Island
+	}{
+		{
+			desc: "any kind",
+			want: []topology.Island{
+				{
+					IDs:            []string{"gs1", "gs1->sat1", "gs1/ant", "gs1/node", "sat1", "sat1/ant", "sat1/node"},
+					Platforms:      []string{"gs1", "sat1"},
+					Representative: "gs1",
+				},
+				{
+					IDs:            []string{"agent", "gs2", "gs2/node"},
+					Platforms:      []string{"gs2"},
+					Representative: "gs2",
+				},
+				{
+					IDs:            []string{"if1", "if2", "port"},
+					Platforms:      []string{},
+					Representative: "port",
+				},
+			},
+		},
+		{
+			desc:  "data plane",
+			kinds: []npb.RK{npb.RK_RK_CONTAINS, npb.RK_RK_ORIGINATES, npb.RK_RK_TERMINATES},
+			want: []topology.Island{
+				{
+					IDs:            []string{"gs1", "gs1->sat1", "gs1/ant", "gs1/node", "sat1", "sat1/ant", "sat1/node"},
+					Platforms:      []string{"gs1", "sat1"},
+					Representative: "gs1",
+				},
+				{IDs: []string{"gs2", "gs2/node"}, Platforms: []string{"gs2"}, Representative: "gs2"},
+				{IDs: []string{"agent"}, Platforms: []string{}, Representative: "agent"},
+				{IDs: []string{"if1"}, Platforms: []string{}, Representative: "if1"},
+				{IDs: []string{"if2"}, Platforms: []string{}, Representative: "if2"},
+				{IDs: []string{"port"}, Platforms: []string{}, Representative: "port"},
+			},
+		},
+		{
+			desc:  "links only",
+			kinds: []npb.RK{npb.RK_RK_ORIGINATES, npb.RK_RK_TERMINATES, npb.RK_RK_TRAVERSES},
+			want: []topology.Island{
+				{
+					IDs:            []string{"gs1->sat1", "gs1/ant", "sat1/ant"},
+					Platforms:      []string{"gs1", "sat1"},
+					Representative: "gs1",
+				},
+				{
+					IDs:            []string{"if1", "if2", "port"},
+					Platforms:      []string{},
+					Representative: "port",
+				},
+				{IDs: []string{"agent"}, Platforms: []string{}, Representative: "agent"},
+				{IDs: []string{"gs1"}, Platforms: []string{"gs1"}, Representative: "gs1"},
+				{IDs: []string{"gs1/node"}, Platforms: []string{"gs1"}, Representative: "gs1"},
+				{IDs: []string{"gs2"}, Platforms: []string{"gs2"}, Representative: "gs2"},
+				{IDs: []string{"gs2/node"}, Platforms: []string{"gs2"}, Representative: "gs2"},
+				{IDs: []string{"sat1"}, Platforms: []string{"sat1"}, Representative: "sat1"},
+				{IDs: []string{"sat1/node"}, Platforms: []string{"sat1"}, Representative: "sat1"},
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if diff := gcmp.Diff(tc.want, topology.Islands(g, tc.kinds...)); diff != "" {
+				t.Errorf("unexpected islands (-want +got): %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"iter"
+	"runtime"
+	"slices"
+	"sync"
+
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	"outernetcouncil.org/nmts/v1/lib/impact"
+)
+
+// Sweep evaluates how reachability between network nodes degrades as entities fail, k at a time.
+type Sweep struct {
+	// Layer is the layer at which reachability is computed; see DeriveAdjacencyGraph.
+	Layer Layer
+
+	// Endpoints holds the IDs of the network nodes between which reachability is computed. If it's
+	// empty, every network node is an endpoint.
+	Endpoints []string
+
+	// Candidates holds the IDs of the entities to fail. If it's empty, every EK_PLATFORM,
+	// EK_PHYSICAL_MEDIUM_LINK and EK_NETWORK_NODE is a candidate.
+	Candidates []string
+
+	// K is the number of candidates that fail together in each scenario; every combination of K
+	// candidates is evaluated. If it's 0, candidates fail one at a time.
+	K int
+
+	// Parallelism is the number of scenarios evaluated at once. If it's 0, it's
+	// runtime.GOMAXPROCS(0).
+	Parallelism int
+
+	// Top, if positive, limits the outcomes to that many of the worst. The others are discarded as
+	// the sweep goes, so that its memory doesn't grow with the number of scenarios.
+	Top int
+}
+
+// Outcome is the result of a scenario of a sweep.
+type Outcome struct {
+	// Failed holds the IDs of the candidates that failed, sorted.
+	Failed []string
+
+	// Down is the number of entities down as a result, as computed by impact.Propagate, including
+	// those that failed.
+	Down int
+
+	// EndpointsDown holds the IDs of the endpoints that are down, sorted.
+	EndpointsDown []string
+
+	// Unreachable holds the ordered pairs of endpoints, from and to, such that to was reachable from
+	// from before the failures but isn't after them, whether because either is down or because no
+	// path remains; sorted.
+	Unreachable [][2]string
+}
+
+// sweepKinds are the kinds of entity that fail in a sweep with no candidates.
+var sweepKinds = []string{"EK_PLATFORM", "EK_PHYSICAL_MEDIUM_LINK", "EK_NETWORK_NODE"}
+
+// Run evaluates every scenario of the sweep against g, which must not be modified until Run
+// returns. Each scenario is evaluated on its own clone of g, from which the entities the failures
+// take down are removed before the adjacency graph is derived again. The outcomes are ranked by the
+// number of pairs of endpoints made unreachable, most first, then by the number of entities down,
+// most first, and then by the IDs of the failed candidates.
+//
+// Run returns the context's error if it's cancelled before every scenario has been evaluated.
+func (s *Sweep) Run(ctx context.Context, g *graph.Graph) ([]Outcome, error) {
+	candidates := slices.Clone(s.Candidates)
+	if len(candidates) == 0 {
+		for _, kind := range sweepKinds {
+			for node := range g.AllNodesOfKind(kind) {
+				candidates = append(candidates, node.GetID())
+			}
+		}
+	}
+	slices.Sort(candidates)
+	candidates = slices.Compact(candidates)
+	for _, id := range candidates {
+		if g.Node(id) == nil {
+			return nil, fmt.Errorf("unknown entity '%v'", id)
+		}
+	}
+	k := max(s.K, 1)
+	if k > len(candidates) {
+		return nil, fmt.Errorf("cannot fail %d of %d candidates", k, len(candidates))
+	}
+
+	baseline := DeriveAdjacencyGraph(g, s.Layer)
+	endpoints := slices.Clone(s.Endpoints)
+	if len(endpoints) == 0 {
+		endpoints = baseline.Nodes
+	}
+	slices.Sort(endpoints)
+	endpoints = slices.Compact(endpoints)
+	reachable := reachablePairs(baseline, endpoints)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	scenarios := make(chan []string)
+	var mu sync.Mutex
+	outcomes := []Outcome{}
+	var wg sync.WaitGroup
+	for range cmp.Or(s.Parallelism, runtime.GOMAXPROCS(0)) {
+		wg.Go(func() {
+			for failed := range scenarios {
+				outcome, err := s.evaluate(g, failed, endpoints, reachable)
+				if err != nil {
+					cancel(err)
+					continue
+				}
+				mu.Lock()
+				outcomes = append(outcomes, outcome)
+				if s.Top > 0 && len(outcomes) >= 2*s.Top {
+					slices.SortFunc(outcomes, compareOutcomes)
+					outcomes = outcomes[:s.Top]
+				}
+				mu.Unlock()
+			}
+		})
+	}
+	for combination := range combinations(len(candidates), k) {
+		if ctx.Err() != nil {
+			break
+		}
+		failed := make([]string, k)
+		for i, c := range combination {
+			failed[i] = candidates[c]
+		}
+		select {
+		case scenarios <- failed:
+		case <-ctx.Done():
+		}
+	}
+	close(scenarios)
+	wg.Wait()
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(outcomes, compareOutcomes)
+	if s.Top > 0 && len(outcomes) > s.Top {
+		outcomes = outcomes[:s.Top]
+	}
+	return outcomes, nil
+}
+
+// compareOutcomes orders outcomes as Run ranks them.
+func compareOutcomes(l, r Outcome) int {
+	return cmp.Or(
+		cmp.Compare(len(r.Unreachable), len(l.Unreachable)),
+		cmp.Compare(r.Down, l.Down),
+		slices.Compare(l.Failed, r.Failed),
+	)
+}
+
+// evaluate returns the outcome of the failure of the given entities.
+func (s *Sweep) evaluate(g *graph.Graph, failed, endpoints []string, reachable map[[2]string]bool) (Outcome, error) {
+	i, err := impact.Propagate(g, failed)
+	if err != nil {
+		return Outcome{}, err
+	}
+	down := i.Down()
+
+	snapshot := graph.Clone(g)
+	snapshot.SetIntegrity(graph.IntegrityCascade)
+	for _, id := range down {
+		if err := snapshot.RemoveEntity(id); err != nil {
+			return Outcome{}, fmt.Errorf("unable to remove '%v': %w", id, err)
+		}
+	}
+	after := reachablePairs(DeriveAdjacencyGraph(snapshot, s.Layer), endpoints)
+
+	outcome := Outcome{Failed: i.Failed, Down: len(down), EndpointsDown: []string{}, Unreachable: [][2]string{}}
+	for _, id := range endpoints {
+		if _, isDown := slices.BinarySearch(down, id); isDown {
+			outcome.EndpointsDown = append(outcome.EndpointsDown, id)
+		}
+	}
+	for pair := range reachable {
+		if !after[pair] {
+			outcome.Unreachable = append(outcome.Unreachable, pair)
+		}
+	}
+	slices.SortFunc(outcome.Unreachable, comparePairs)
+	return outcome, nil
+}
+
+// reachablePairs returns the ordered pairs of distinct endpoints such that the second is reachable
+// from the first, following the direction of the adjacencies.
+func reachablePairs(ag *AdjacencyGraph, endpoints []string) map[[2]string]bool {
+	next := map[string][]string{}
+	for _, adj := range ag.Adjacencies {
+		next[adj.A] = append(next[adj.A], adj.Z)
+	}
+	isEndpoint := map[string]bool{}
+	for _, id := range endpoints {
+		isEndpoint[id] = true
+	}
+
+	pairs := map[[2]string]bool{}
+	for _, from := range endpoints {
+		if !slices.Contains(ag.Nodes, from) {
+			continue
+		}
+		seen := map[string]bool{from: true}
+		queue := []string{from}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			if isEndpoint[id] && id != from {
+				pairs[[2]string{from, id}] = true
+			}
+			for _, z := range next[id] {
+				if !seen[z] {
+					seen[z] = true
+					queue = append(queue, z)
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+// combinations returns an iterator over every combination of k of the integers [0, n), where
+// 0 < k <= n, in lexicographic order. The slice it yields is reused, so it must be copied to be kept.
+func combinations(n, k int) iter.Seq[[]int] {
+	return func(yield func([]int) bool) {
+		combination := make([]int, k)
+		for i := range combination {
+			combination[i] = i
+		}
+		for {
+			if !yield(combination) {
+				return
+			}
+			i := k - 1
+			for i >= 0 && combination[i] == n-k+i {
+				i--
+			}
+			if i < 0 {
+				return
+			}
+			combination[i]++
+			for j := i + 1; j < k; j++ {
+				combination[j] = combination[j-1] + 1
+			}
+		}
+	}
+}
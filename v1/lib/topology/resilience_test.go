@@ -0,0 +1,152 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
+
+	"outernetcouncil.org/nmts/v1/lib/topology"
+	testutil "outernetcouncil.org/nmts/v1/lib/utilities/testing"
+)
+
+// describeOutcomes returns an outcome per line, as its failed candidates, the number of entities
+// down and the number of pairs made unreachable.
+func describeOutcomes(outcomes []topology.Outcome) []string {
+	lines := []string{}
+	for _, o := range outcomes {
+		lines = append(lines, fmt.Sprintf("%v: %d down, %d unreachable", o.Failed, o.Down, len(o.Unreachable)))
+	}
+	return lines
+}
+
+func TestSweep(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(spofFragment))))
+	for _, tc := range []struct {
+		name  string
+		sweep topology.Sweep
+		want  []string
+	}{
+		{
+			name:  "N-1",
+			sweep: topology.Sweep{Layer: topology.L3},
+			want: []string{
+				"[r3]: 6 down, 13 unreachable",
+				"[p2]: 6 down, 12 unreachable",
+				"[r2]: 5 down, 12 unreachable",
+				"[r4]: 4 down, 10 unreachable",
+				"[r1]: 3 down, 7 unreachable",
+				"[r5]: 2 down, 4 unreachable",
+			},
+		},
+		{
+			name: "N-2",
+			sweep: topology.Sweep{
+				Layer:       topology.L3,
+				Endpoints:   []string{"r1", "r3", "r5"},
+				Candidates:  []string{"r5", "p2", "r1"},
+				K:           2,
+				Parallelism: 2,
+			},
+			want: []string{
+				"[p2 r5]: 8 down, 4 unreachable",
+				"[r1 r5]: 5 down, 4 unreachable",
+				"[p2 r1]: 9 down, 3 unreachable",
+			},
+		},
+		{
+			name:  "top",
+			sweep: topology.Sweep{Layer: topology.L3, Parallelism: 1, Top: 2},
+			want: []string{
+				"[r3]: 6 down, 13 unreachable",
+				"[p2]: 6 down, 12 unreachable",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			outcomes, err := tc.sweep.Run(context.Background(), g)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if diff := gcmp.Diff(tc.want, describeOutcomes(outcomes)); diff != "" {
+				t.Errorf("unexpected outcomes (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestSweepOutcome(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(spofFragment))))
+	sweep := topology.Sweep{Layer: topology.L3, Endpoints: []string{"r1", "r3", "r5"}, Candidates: []string{"r2"}}
+	outcomes, err := sweep.Run(context.Background(), g)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := []topology.Outcome{{
+		Failed:        []string{"r2"},
+		Down:          5,
+		EndpointsDown: []string{},
+		Unreachable:   [][2]string{{"r1", "r3"}, {"r1", "r5"}, {"r3", "r1"}},
+	}}
+	if diff := gcmp.Diff(want, outcomes); diff != "" {
+		t.Errorf("unexpected outcomes (-want +got): %s", diff)
+	}
+
+	// The graph is left as it was.
+	if g.Node("r2") == nil || len(g.Neighbors("r2")) == 0 {
+		t.Errorf("Run modified the graph")
+	}
+}
+
+func TestSweepErrors(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(spofFragment))))
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, tc := range []struct {
+		name  string
+		ctx   context.Context
+		sweep topology.Sweep
+		want  string
+	}{
+		{
+			name:  "unknown candidate",
+			ctx:   context.Background(),
+			sweep: topology.Sweep{Layer: topology.L3, Candidates: []string{"r1", "missing"}},
+			want:  "unknown entity 'missing'",
+		},
+		{
+			name:  "too many failures",
+			ctx:   context.Background(),
+			sweep: topology.Sweep{Layer: topology.L3, Candidates: []string{"r1", "r2"}, K: 3},
+			want:  "cannot fail 3 of 2 candidates",
+		},
+		{
+			name:  "cancelled",
+			ctx:   cancelled,
+			sweep: topology.Sweep{Layer: topology.L3},
+			want:  context.Canceled.Error(),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.sweep.Run(tc.ctx, g); err == nil || err.Error() != tc.want {
+				t.Errorf("Run returned error %v; want %q", err, tc.want)
+			}
+		})
+	}
+}
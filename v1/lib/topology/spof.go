@@ -0,0 +1,406 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	"outernetcouncil.org/nmts/v1/lib/graph"
+	"outernetcouncil.org/nmts/v1/lib/impact"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// ArticulationPoints returns the IDs of the nodes whose removal would disconnect others that are
+// connected, regardless of the direction of their adjacencies, sorted.
+func (ag *AdjacencyGraph) ArticulationPoints() []string {
+	points, _ := ag.cuts()
+	return points
+}
+
+// Bridges returns the pairs of nodes, each sorted, whose adjacencies are the only connection between
+// the parts of the graph on either side of them, regardless of direction; removing every link
+// between either pair would disconnect those parts. The pairs are sorted.
+func (ag *AdjacencyGraph) Bridges() [][2]string {
+	_, bridges := ag.cuts()
+	return bridges
+}
+
+// undirected returns the sorted neighbours of each node, regardless of the direction and number of
+// the adjacencies joining them.
+func (ag *AdjacencyGraph) undirected() map[string][]string {
+	neighbors := map[string][]string{}
+	for _, adj := range ag.Adjacencies {
+		neighbors[adj.A] = append(neighbors[adj.A], adj.Z)
+		neighbors[adj.Z] = append(neighbors[adj.Z], adj.A)
+	}
+	for id, ids := range neighbors {
+		slices.Sort(ids)
+		neighbors[id] = slices.Compact(ids)
+	}
+	return neighbors
+}
+
+// cuts finds the articulation points and bridges using Tarjan's algorithm.
+func (ag *AdjacencyGraph) cuts() ([]string, [][2]string) {
+	neighbors := ag.undirected()
+	discovered, low := map[string]int{}, map[string]int{}
+	points := map[string]bool{}
+	bridges := [][2]string{}
+
+	var visit func(id, parent string, root bool)
+	visit = func(id, parent string, root bool) {
+		discovered[id] = len(discovered) + 1
+		low[id] = discovered[id]
+		children := 0
+		for _, neighbor := range neighbors[id] {
+			if _, ok := discovered[neighbor]; !ok {
+				children++
+				visit(neighbor, id, false)
+				low[id] = min(low[id], low[neighbor])
+				if !root && low[neighbor] >= discovered[id] {
+					points[id] = true
+				}
+				if low[neighbor] > discovered[id] {
+					bridges = append(bridges, pairOf(id, neighbor))
+				}
+			} else if root || neighbor != parent {
+				low[id] = min(low[id], discovered[neighbor])
+			}
+		}
+		if root && children > 1 {
+			points[id] = true
+		}
+	}
+	for _, id := range ag.Nodes {
+		if _, ok := discovered[id]; !ok {
+			visit(id, "", true)
+		}
+	}
+
+	slices.SortFunc(bridges, comparePairs)
+	return slices.Sorted(maps.Keys(points)), bridges
+}
+
+func pairOf(x, y string) [2]string {
+	if y < x {
+		x, y = y, x
+	}
+	return [2]string{x, y}
+}
+
+func comparePairs(l, r [2]string) int {
+	return cmp.Or(cmp.Compare(l[0], r[0]), cmp.Compare(l[1], r[1]))
+}
+
+// Failure is an entity or relationship whose failure disconnects network nodes.
+type Failure struct {
+	// Entity is the ID of the failed entity, or empty if a relationship failed.
+	Entity string `json:",omitempty"`
+
+	// Relationship is the failed relationship, or nil if an entity failed. Only the RK_ORIGINATES
+	// and RK_TERMINATES relationships between interfaces and links are considered, since their
+	// failure takes down the links derived from them.
+	Relationship *er.Relationship `json:",omitempty"`
+
+	// Affected holds the IDs of the entities the failed entity takes down with it, as computed by
+	// impact.Propagate, sorted. Entities it only leaves unmanaged aren't included.
+	Affected []string
+
+	// DisconnectedPairs is the number of pairs of chosen nodes that are connected before the failure
+	// but not after it, neither of which failed.
+	DisconnectedPairs int
+
+	// Partitions holds, for each set of connected chosen nodes that the failure splits, the sets of
+	// them that remain connected, each sorted, largest first.
+	Partitions [][][]string
+}
+
+// SinglePointsOfFailure returns every entity and relationship of g whose failure disconnects a
+// network node of from from one of to at the given layer, i.e. in the graph DeriveAdjacencyGraph
+// returns, regardless of the direction of the adjacencies. If from or to is empty, every network
+// node is chosen. An entity's failure takes down the entities affected by it too, as computed by
+// impact.Propagate; a link is down if it, either of its interfaces, or either of their
+// relationships with it failed.
+//
+// The failures are ranked by the number of pairs of nodes they disconnect, most first, then by
+// entity ID and then by relationship.
+func SinglePointsOfFailure(g *graph.Graph, layer Layer, from, to []string) []Failure {
+	ag := DeriveAdjacencyGraph(g, layer)
+	a := newAnalysis(ag, from, to)
+	points, bridges := ag.cuts()
+	a.articulationPoints = map[string]bool{}
+	for _, id := range points {
+		a.articulationPoints[id] = true
+	}
+	a.bridges = map[[2]string]bool{}
+	for _, pair := range bridges {
+		a.bridges[pair] = true
+	}
+
+	ids := []string{}
+	for node := range g.AllNodesMatching(nil) {
+		ids = append(ids, node.GetID())
+	}
+	slices.Sort(ids)
+
+	failures := []Failure{}
+	for _, id := range ids {
+		// The IDs are g's own, so Propagate can't fail.
+		i, _ := impact.Propagate(g, []string{id})
+		affected := []string{}
+		failed := map[string]bool{id: true}
+		for _, e := range i.Effects {
+			if !e.Unmanaged {
+				affected = append(affected, e.ID)
+				failed[e.ID] = true
+			}
+		}
+		if !a.elements[id] && !slices.ContainsFunc(affected, func(id string) bool { return a.elements[id] }) {
+			continue
+		}
+		if f, ok := a.evaluate(failed, nil); ok {
+			f.Entity, f.Affected = id, affected
+			failures = append(failures, f)
+		}
+	}
+	for _, r := range slices.SortedFunc(maps.Keys(a.relationships), er.CompareRelationships) {
+		if f, ok := a.evaluate(nil, map[er.Relationship]bool{r: true}); ok {
+			f.Relationship, f.Affected = &r, []string{}
+			failures = append(failures, f)
+		}
+	}
+
+	slices.SortStableFunc(failures, func(l, r Failure) int {
+		return cmp.Compare(r.DisconnectedPairs, l.DisconnectedPairs)
+	})
+	return failures
+}
+
+// analysis holds what's needed to evaluate failures against an adjacency graph.
+type analysis struct {
+	ag                 *AdjacencyGraph
+	from, to           map[string]bool
+	articulationPoints map[string]bool
+	bridges            map[[2]string]bool
+
+	// groups holds the chosen nodes grouped by their connectivity before any failure, each sorted.
+	groups [][]string
+
+	// elements holds the IDs of every entity whose failure takes part of the adjacency graph down
+	// directly: nodes, links and interfaces; failures that include none of them are skipped.
+	elements map[string]bool
+
+	// relationships holds the relationships links are derived from.
+	relationships map[er.Relationship]bool
+}
+
+func newAnalysis(ag *AdjacencyGraph, from, to []string) *analysis {
+	a := &analysis{ag: ag, from: chosen(ag, from), to: chosen(ag, to), elements: map[string]bool{}, relationships: map[er.Relationship]bool{}}
+	for _, id := range ag.Nodes {
+		a.elements[id] = true
+	}
+	for _, adj := range ag.Adjacencies {
+		for _, link := range adj.Links {
+			a.elements[link.ID] = true
+			a.elements[link.AInterface] = true
+			a.elements[link.ZInterface] = true
+			a.relationships[originates(link)] = true
+			a.relationships[terminates(link)] = true
+		}
+	}
+	before := a.partition(func(Adjacency, Link) bool { return true }, nil)
+	index := map[int]int{}
+	for _, id := range ag.Nodes {
+		if !a.from[id] && !a.to[id] {
+			continue
+		}
+		root := before.find(id)
+		if _, ok := index[root]; !ok {
+			index[root] = len(a.groups)
+			a.groups = append(a.groups, []string{})
+		}
+		a.groups[index[root]] = append(a.groups[index[root]], id)
+	}
+	return a
+}
+
+// chosen returns the set of the given nodes in the adjacency graph, or all of them if none are
+// given.
+func chosen(ag *AdjacencyGraph, ids []string) map[string]bool {
+	if len(ids) == 0 {
+		ids = ag.Nodes
+	}
+	set := map[string]bool{}
+	for _, id := range ids {
+		if slices.Contains(ag.Nodes, id) {
+			set[id] = true
+		}
+	}
+	return set
+}
+
+func originates(link Link) er.Relationship {
+	return er.Relationship{A: link.AInterface, Kind: npb.RK_RK_ORIGINATES, Z: link.ID}
+}
+
+func terminates(link Link) er.Relationship {
+	return er.Relationship{A: link.ZInterface, Kind: npb.RK_RK_TERMINATES, Z: link.ID}
+}
+
+// evaluate returns how the given failures disconnect the chosen nodes, and false if they don't.
+func (a *analysis) evaluate(failed map[string]bool, failedRelationships map[er.Relationship]bool) (Failure, bool) {
+	linkUp := func(adj Adjacency, link Link) bool {
+		return !failed[adj.A] && !failed[adj.Z] && !failed[link.ID] && !failed[link.AInterface] && !failed[link.ZInterface] &&
+			!failedRelationships[originates(link)] && !failedRelationships[terminates(link)]
+	}
+
+	// Skip the full evaluation of failures known not to disconnect anything: those of a single node
+	// that isn't an articulation point, and of links between a single pair of nodes that isn't a
+	// bridge or isn't left without links.
+	failedNodes := []string{}
+	for _, id := range a.ag.Nodes {
+		if failed[id] {
+			failedNodes = append(failedNodes, id)
+		}
+	}
+	downPairs := map[[2]string]bool{}
+	upPairs := map[[2]string]bool{}
+	for _, adj := range a.ag.Adjacencies {
+		pair := pairOf(adj.A, adj.Z)
+		for _, link := range adj.Links {
+			if linkUp(adj, link) {
+				upPairs[pair] = true
+			} else if !failed[adj.A] && !failed[adj.Z] {
+				downPairs[pair] = true
+			}
+		}
+	}
+	switch {
+	case len(failedNodes) == 0 && len(downPairs) == 0:
+		return Failure{}, false
+	case len(failedNodes) == 1 && len(downPairs) == 0 && !a.articulationPoints[failedNodes[0]]:
+		return Failure{}, false
+	case len(failedNodes) == 0 && len(downPairs) == 1:
+		for pair := range downPairs {
+			if upPairs[pair] || !a.bridges[pair] {
+				return Failure{}, false
+			}
+		}
+	}
+
+	after := a.partition(linkUp, failed)
+	f := Failure{Partitions: [][][]string{}}
+	for _, group := range a.groups {
+		survivors := []string{}
+		parts := [][]string{}
+		part := map[int]int{}
+		for _, id := range group {
+			if failed[id] {
+				continue
+			}
+			survivors = append(survivors, id)
+			root := after.find(id)
+			if _, ok := part[root]; !ok {
+				part[root] = len(parts)
+				parts = append(parts, []string{})
+			}
+			parts[part[root]] = append(parts[part[root]], id)
+		}
+		if len(parts) < 2 {
+			continue
+		}
+		lost := a.pairs(survivors)
+		for _, part := range parts {
+			lost -= a.pairs(part)
+		}
+		if lost == 0 {
+			continue
+		}
+		f.DisconnectedPairs += lost
+		slices.SortStableFunc(parts, func(l, r []string) int { return cmp.Compare(len(r), len(l)) })
+		f.Partitions = append(f.Partitions, parts)
+	}
+	return f, f.DisconnectedPairs > 0
+}
+
+// pairs returns the number of unordered pairs of distinct nodes of ids, one from and one to.
+func (a *analysis) pairs(ids []string) int {
+	from, to, both := 0, 0, 0
+	for _, id := range ids {
+		if a.from[id] {
+			from++
+		}
+		if a.to[id] {
+			to++
+		}
+		if a.from[id] && a.to[id] {
+			both++
+		}
+	}
+	// Ordered pairs, less those of a node with itself, less the pairs of nodes in both sets that
+	// were counted in both orders.
+	return from*to - both - both*(both-1)/2
+}
+
+// partition returns a disjoint-set forest of the nodes that haven't failed, joined by the links
+// that are up.
+func (a *analysis) partition(up func(Adjacency, Link) bool, failed map[string]bool) partitioning {
+	p := partitioning{index: map[string]int{}, ids: []string{}}
+	for _, id := range a.ag.Nodes {
+		if !failed[id] {
+			p.index[id] = len(p.ids)
+			p.ids = append(p.ids, id)
+			p.parent = append(p.parent, len(p.parent))
+		}
+	}
+	for _, adj := range a.ag.Adjacencies {
+		if slices.ContainsFunc(adj.Links, func(link Link) bool { return up(adj, link) }) {
+			p.union(adj.A, adj.Z)
+		}
+	}
+	return p
+}
+
+// partitioning is a disjoint-set forest of the nodes of an adjacency graph.
+type partitioning struct {
+	ids    []string
+	index  map[string]int
+	parent []int
+}
+
+func (p partitioning) find(id string) int {
+	i := p.index[id]
+	for p.parent[i] != i {
+		p.parent[i] = p.parent[p.parent[i]]
+		i = p.parent[i]
+	}
+	return i
+}
+
+func (p partitioning) union(x, y string) {
+	if _, ok := p.index[x]; !ok {
+		return
+	}
+	if _, ok := p.index[y]; !ok {
+		return
+	}
+	rx, ry := p.find(x), p.find(y)
+	if rx != ry {
+		p.parent[max(rx, ry)] = min(rx, ry)
+	}
+}
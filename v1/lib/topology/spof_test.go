@@ -0,0 +1,144 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology_test
+
+import (
+	"fmt"
+	"testing"
+
+	gcmp "github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+	"outernetcouncil.org/nmts/v1/lib/topology"
+	testutil "outernetcouncil.org/nmts/v1/lib/utilities/testing"
+	npb "outernetcouncil.org/nmts/v1/proto"
+)
+
+// Routers r1 to r4 form a chain of IP links in both directions, r3 and r4 joined by a redundant
+// pair from r3. Router r2 is on platform p2, and r5 is only reachable by a one-way link from r4.
+const spofFragment = `
+entity { id: "p2" ek_platform{} }
+entity { id: "r1" ek_network_node{} }
+entity { id: "r2" ek_network_node{} }
+entity { id: "r3" ek_network_node{} }
+entity { id: "r4" ek_network_node{} }
+entity { id: "r5" ek_network_node{} }
+relationship { a: "p2" kind: RK_CONTAINS z: "r2" }
+entity { id: "r1/ip" ek_interface{ ip{} } }
+entity { id: "r2/ip-a" ek_interface{ ip{} } }
+entity { id: "r2/ip-b" ek_interface{ ip{} } }
+entity { id: "r3/ip-a" ek_interface{ ip{} } }
+entity { id: "r3/ip-b" ek_interface{ ip{} } }
+entity { id: "r4/ip" ek_interface{ ip{} } }
+entity { id: "r5/ip" ek_interface{ ip{} } }
+relationship { a: "r1" kind: RK_CONTAINS z: "r1/ip" }
+relationship { a: "r2" kind: RK_CONTAINS z: "r2/ip-a" }
+relationship { a: "r2" kind: RK_CONTAINS z: "r2/ip-b" }
+relationship { a: "r3" kind: RK_CONTAINS z: "r3/ip-a" }
+relationship { a: "r3" kind: RK_CONTAINS z: "r3/ip-b" }
+relationship { a: "r4" kind: RK_CONTAINS z: "r4/ip" }
+relationship { a: "r5" kind: RK_CONTAINS z: "r5/ip" }
+
+entity { id: "r1->r2" ek_logical_packet_link{} }
+relationship { a: "r1/ip" kind: RK_ORIGINATES z: "r1->r2" }
+relationship { a: "r2/ip-a" kind: RK_TERMINATES z: "r1->r2" }
+entity { id: "r2->r1" ek_logical_packet_link{} }
+relationship { a: "r2/ip-a" kind: RK_ORIGINATES z: "r2->r1" }
+relationship { a: "r1/ip" kind: RK_TERMINATES z: "r2->r1" }
+entity { id: "r2->r3" ek_logical_packet_link{} }
+relationship { a: "r2/ip-b" kind: RK_ORIGINATES z: "r2->r3" }
+relationship { a: "r3/ip-a" kind: RK_TERMINATES z: "r2->r3" }
+entity { id: "r3->r2" ek_logical_packet_link{} }
+relationship { a: "r3/ip-a" kind: RK_ORIGINATES z: "r3->r2" }
+relationship { a: "r2/ip-b" kind: RK_TERMINATES z: "r3->r2" }
+entity { id: "r3->r4/a" ek_logical_packet_link{} }
+relationship { a: "r3/ip-b" kind: RK_ORIGINATES z: "r3->r4/a" }
+relationship { a: "r4/ip" kind: RK_TERMINATES z: "r3->r4/a" }
+entity { id: "r3->r4/b" ek_logical_packet_link{} }
+relationship { a: "r3/ip-b" kind: RK_ORIGINATES z: "r3->r4/b" }
+relationship { a: "r4/ip" kind: RK_TERMINATES z: "r3->r4/b" }
+entity { id: "r4->r3" ek_logical_packet_link{} }
+relationship { a: "r4/ip" kind: RK_ORIGINATES z: "r4->r3" }
+relationship { a: "r3/ip-b" kind: RK_TERMINATES z: "r4->r3" }
+entity { id: "r4->r5" ek_logical_packet_link{} }
+relationship { a: "r4/ip" kind: RK_ORIGINATES z: "r4->r5" }
+relationship { a: "r5/ip" kind: RK_TERMINATES z: "r4->r5" }
+`
+
+func TestArticulationPointsAndBridges(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(spofFragment))))
+	ag := topology.DeriveAdjacencyGraph(g, topology.L3)
+	if diff := gcmp.Diff([]string{"r2", "r3", "r4"}, ag.ArticulationPoints()); diff != "" {
+		t.Errorf("unexpected articulation points (-want +got): %s", diff)
+	}
+	want := [][2]string{{"r1", "r2"}, {"r2", "r3"}, {"r3", "r4"}, {"r4", "r5"}}
+	if diff := gcmp.Diff(want, ag.Bridges()); diff != "" {
+		t.Errorf("unexpected bridges (-want +got): %s", diff)
+	}
+}
+
+func TestSinglePointsOfFailure(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(spofFragment))))
+	failures := topology.SinglePointsOfFailure(g, topology.L3, []string{"r1"}, []string{"r4", "r5"})
+
+	got := []string{}
+	for _, f := range failures {
+		name := f.Entity
+		if name == "" {
+			name = f.Relationship.String()
+		}
+		got = append(got, fmt.Sprintf("%v: %d", name, f.DisconnectedPairs))
+	}
+	want := []string{
+		"p2: 2",
+		"r1/ip: 2",
+		"r2: 2",
+		"r2/ip-a: 2",
+		"r2/ip-b: 2",
+		"r3: 2",
+		"r3/ip-a: 2",
+		"r3/ip-b: 2",
+		"r4/ip: 2",
+		"r4: 1",
+		"r4->r5: 1",
+		"r5/ip: 1",
+		(&er.Relationship{A: "r4/ip", Kind: npb.RK_RK_ORIGINATES, Z: "r4->r5"}).String() + ": 1",
+		(&er.Relationship{A: "r5/ip", Kind: npb.RK_RK_TERMINATES, Z: "r4->r5"}).String() + ": 1",
+	}
+	if diff := gcmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected failures (-want +got): %s", diff)
+	}
+
+	wantPlatform := topology.Failure{
+		Entity:            "p2",
+		Affected:          []string{"r2", "r2->r1", "r2->r3", "r2/ip-a", "r2/ip-b"},
+		DisconnectedPairs: 2,
+		Partitions:        [][][]string{{{"r4", "r5"}, {"r1"}}},
+	}
+	if diff := gcmp.Diff(wantPlatform, failures[0]); diff != "" {
+		t.Errorf("unexpected failure of p2 (-want +got): %s", diff)
+	}
+}
+
+func TestSinglePointsOfFailureRedundant(t *testing.T) {
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(spofFragment))))
+	if failures := topology.SinglePointsOfFailure(g, topology.L3, []string{"r3"}, []string{"r4"}); len(failures) != 2 {
+		t.Errorf("got %d failures between r3 and r4; want 2 (r3/ip-b and r4/ip): %v", len(failures), failures)
+	}
+	if failures := topology.SinglePointsOfFailure(g, topology.L2, nil, nil); len(failures) != 0 {
+		t.Errorf("got %d failures with no L2 adjacencies; want none: %v", len(failures), failures)
+	}
+}
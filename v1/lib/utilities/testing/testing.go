@@ -42,6 +42,18 @@ func FragmentFrom(txtpb string) (*nmtspb.Fragment, error) {
 	return e, nil
 }
 
+// Unlike GraphFromFragments, doesn't validate, so fragments may hold what
+// the model doesn't permit.
+//
+// Consider wrapping in https://github.com/samber/lo lo.Must().
+func CollectionFromFragments(fragments ...*nmtspb.Fragment) (*er.Collection, error) {
+	builder := er.NewNonValidatingCollectionBuilder()
+	if err := builder.InsertFragments(fragments...); err != nil {
+		return nil, err
+	}
+	return builder.Build()
+}
+
 // Consider wrapping in https://github.com/samber/lo lo.Must().
 func GraphFromFragments(fragments ...*nmtspb.Fragment) (*graph.Graph, error) {
 	return UpdateGraphWithFragments(graph.New(), fragments...)
@@ -39,15 +39,19 @@ func EdgesIncidentTo(g *graph.Graph, nodeID string) EdgeSet {
 }
 
 func OutEdgesFrom(g *graph.Graph, nodeID string) EdgeSet {
-	return NewEdgeSetOf(lo.Filter(EdgesIncidentTo(g, nodeID).ToSlice(), func(e *graph.Edge, _ int) bool {
-		return e != nil && e.GetA() == nodeID
-	})...)
+	edges := NewEdgeSet()
+	for _, edge := range g.OutNeighbors(nodeID, nmtspb.RK_RK_UNSPECIFIED) {
+		edges.Add(edge)
+	}
+	return edges
 }
 
 func InEdgesTo(g *graph.Graph, nodeID string) EdgeSet {
-	return NewEdgeSetOf(lo.Filter(EdgesIncidentTo(g, nodeID).ToSlice(), func(e *graph.Edge, _ int) bool {
-		return e != nil && e.GetZ() == nodeID
-	})...)
+	edges := NewEdgeSet()
+	for _, edge := range g.InNeighbors(nodeID, nmtspb.RK_RK_UNSPECIFIED) {
+		edges.Add(edge)
+	}
+	return edges
 }
 
 func NewEdgeSet() EdgeSet {
@@ -66,11 +70,11 @@ func FilterFor(edgeSet EdgeSet, kinds ...nmtspb.RK) EdgeSet {
 
 // Get all IDs of "A" entities in inbound edges of this direct relationship EK_A -- RK --> EK_Z, given "Z" ID.
 func getInIDs(g *graph.Graph, zID string, relationship nmtspb.RK) []string {
-	inIDs := set.NewSet[string]()
-	for edge := range FilterFor(InEdgesTo(g, zID), relationship).Iter() {
-		inIDs.Add(edge.GetA())
+	inIDs := []string{}
+	for aID := range g.InNeighbors(zID, relationship) {
+		inIDs = append(inIDs, aID)
 	}
-	return inIDs.ToSlice()
+	return inIDs
 }
 
 // This is useful if you just need EK_A from a direct relationship EK_A -- RK --> EK_Z, given "Z" ID.
@@ -80,11 +84,11 @@ func GetAFromZ(g *graph.Graph, zID string, relationship nmtspb.RK, isDesiredID f
 
 // Get all IDs of "Z" entities in outbound edges of this direct relationship EK_A -- RK --> EK_Z, given "A" ID.
 func getOutIDs(g *graph.Graph, aID string, relationship nmtspb.RK) []string {
-	outIDs := set.NewSet[string]()
-	for edge := range FilterFor(OutEdgesFrom(g, aID), relationship).Iter() {
-		outIDs.Add(edge.GetZ())
+	outIDs := []string{}
+	for zID := range g.OutNeighbors(aID, relationship) {
+		outIDs = append(outIDs, zID)
 	}
-	return outIDs.ToSlice()
+	return outIDs
 }
 
 // This is useful if you just need EK_Z from a direct relationship EK_A -- RK --> EK_Z, given "A" ID.
@@ -97,20 +101,6 @@ func GetZsFromA(g *graph.Graph, aID string, relationship nmtspb.RK, isDesiredID
 	return lo.Filter(getOutIDs(g, aID, relationship), isDesiredID)
 }
 
-type countdown struct {
-	value int
-}
-
-func (c *countdown) Decrement() {
-	if !c.Stopped() {
-		c.value--
-	}
-}
-
-func (c *countdown) Stopped() bool {
-	return c == nil || c.value <= 0
-}
-
 type GraphTrace struct {
 	messages []string
 }
@@ -194,7 +184,6 @@ func findEncompassingEntity(g *graph.Graph, isDesiredKind func(*nmtspb.Entity) b
 	}
 
 	encompassingEntityID := ""
-	c := countdown{value: 4096}
 
 	onVisitFn := func(g *graph.Graph, entityID string) {
 		if e := g.Node(entityID).GetEntity(); e != nil && isDesiredKind(e) {
@@ -202,22 +191,19 @@ func findEncompassingEntity(g *graph.Graph, isDesiredKind func(*nmtspb.Entity) b
 		}
 	}
 	shouldStopFn := func(entityID string) bool {
-		if encompassingEntityID != "" {
-			return true
-		}
-		c.Decrement()
-		return c.Stopped()
+		return encompassingEntityID != ""
 	}
 
 	var trace *GraphTrace
 	// N.B.: uncomment below to emit graph traces in unit tests.
 	// trace = &GraphTrace{}
 
-	dfs := graph.DepthFirst{
+	// Walking breadth first finds the nearest encompassing entity.
+	bfs := graph.BreadthFirst{
 		Visit:    trace.LoggingVisitFn(onVisitFn),
 		Traverse: trace.LoggingTraverseFn(encompassingEntityInternalTraversal),
 	}
-	dfs.Walk(g, startingID, trace.LoggingUntilFn(shouldStopFn))
+	bfs.Walk(g, startingID, trace.LoggingUntilFn(shouldStopFn))
 	trace.EmitLogMessage(os.Stderr)
 
 	return encompassingEntityID
@@ -249,9 +235,8 @@ func FindAssociatedPort(g *graph.Graph, startingID string) string {
 	}
 
 	portID := ""
-	c := countdown{value: 4096}
 
-	dfs := graph.DepthFirst{
+	bfs := graph.BreadthFirst{
 		Visit: func(g *graph.Graph, entityID string) {
 			if g.Node(entityID).GetEntity().GetEkPort() != nil {
 				portID = entityID
@@ -264,12 +249,8 @@ func FindAssociatedPort(g *graph.Graph, startingID string) string {
 				(rk == nmtspb.RK_RK_TERMINATES && g.Node(candidateEdge.GetZ()).GetEntity().GetEkDemodulator() != nil)
 		},
 	}
-	dfs.Walk(g, startingID, func(entityID string) bool {
-		if portID != "" {
-			return true
-		}
-		c.Decrement()
-		return c.Stopped()
+	bfs.Walk(g, startingID, func(entityID string) bool {
+		return portID != ""
 	})
 
 	return portID
@@ -285,7 +266,6 @@ func FindBentPipeReceiverFromTransmitter(g *graph.Graph, transmitterID string) s
 	}
 
 	receiverID := ""
-	c := countdown{value: 4096}
 
 	onVisitFn := func(g *graph.Graph, entityID string) {
 		if g.Node(entityID).GetEntity().GetEkReceiver() != nil {
@@ -305,18 +285,14 @@ func FindBentPipeReceiverFromTransmitter(g *graph.Graph, transmitterID string) s
 		return rk == nmtspb.RK_RK_SIGNAL_TRANSITS
 	}
 	shouldStopFn := func(entityID string) bool {
-		if receiverID != "" {
-			return true
-		}
-		c.Decrement()
-		return c.Stopped()
+		return receiverID != ""
 	}
 
-	dfs := graph.DepthFirst{
+	bfs := graph.BreadthFirst{
 		Visit:    onVisitFn,
 		Traverse: shouldTraverseFn,
 	}
-	dfs.Walk(g, transmitterID, shouldStopFn)
+	bfs.Walk(g, transmitterID, shouldStopFn)
 
 	return receiverID
 }
@@ -339,8 +315,7 @@ func GetAllTraversedEntitiesBeneath(g *graph.Graph, isDesiredKind func(*nmtspb.E
 
 func getAllTraversedEntitiesBeneathHelper(g *graph.Graph, isDesiredKind func(*nmtspb.Entity) bool, startingID string, visited set.Set[string]) []string {
 	traversedEntities := []string{startingID}
-	for edge := range FilterFor(OutEdgesFrom(g, startingID), nmtspb.RK_RK_TRAVERSES).Iter() {
-		nextID := edge.GetZ()
+	for nextID := range g.OutNeighbors(startingID, nmtspb.RK_RK_TRAVERSES) {
 		// Don't traverse if this entity has already been visited.
 		if e := g.Node(nextID).GetEntity(); e != nil && isDesiredKind(e) && !visited.Contains(nextID) {
 			visited.Add(nextID)
@@ -417,14 +392,8 @@ func GetAgentIDsFromNetworkInterfaceIDViaModems(g *graph.Graph, interfaceID stri
 
 // EK_NETWORK_NODE --RK_CONTAINS--> EK_ROUTE_FN <--RK_CONTROLS-- EK_SDN_AGENT
 func GetAgentIDFromNetworkNodeIDViaRouteFn(g *graph.Graph, networkNodeID string) (agentID string, ok bool) {
-	// First we get the list of potential EK_ROUTE_FNs that are contained by this node.
-	// potentialRouteFns is just the list of entities with the correct relationship but not necessarily the right type.
-	potentialRouteFns := set.NewSet[string]()
-	for edge := range FilterFor(OutEdgesFrom(g, networkNodeID), nmtspb.RK_RK_CONTAINS).Iter() {
-		potentialRouteFns.Add(edge.GetZ())
-	}
-
-	routeFns := lo.Filter(potentialRouteFns.ToSlice(), func(id string, _ int) bool {
+	// First we get the list of EK_ROUTE_FNs that are contained by this node.
+	routeFns := GetZsFromA(g, networkNodeID, nmtspb.RK_RK_CONTAINS, func(id string, _ int) bool {
 		return g.Node(id).GetEntity().GetEkRouteFn() != nil
 	})
 
@@ -555,27 +524,22 @@ func GetAllEntityIDsUnderNetworkNode(g *graph.Graph, startingID string) []string
 	}
 
 	entityIds := set.NewSet[string]()
-	c := countdown{value: 4096}
 
 	onVisitFn := func(g *graph.Graph, entityID string) {
 		if e := g.Node(entityID).GetEntity(); e != nil {
 			entityIds.Add(entityID)
 		}
 	}
-	shouldStopFn := func(entityID string) bool {
-		c.Decrement()
-		return c.Stopped()
-	}
 	traverseFn := func(g *graph.Graph, _ string, candidateEdge *graph.Edge) bool {
 		a := g.Node(candidateEdge.GetA()).GetEntity()
 		return encompassingEntityInternalTraversal(g, "", candidateEdge) && a.GetEkPlatform() == nil
 	}
 
-	dfs := graph.DepthFirst{
+	bfs := graph.BreadthFirst{
 		Visit:    onVisitFn,
 		Traverse: traverseFn,
 	}
-	dfs.Walk(g, startingID, shouldStopFn)
+	bfs.Walk(g, startingID, nil)
 
 	return entityIds.ToSlice()
 }
@@ -584,7 +548,8 @@ func GetAllEntityIDsUnderNetworkNode(g *graph.Graph, startingID string) []string
 // This is still under development and may change at any time.
 //
 // Given a list of entity IDs that are affected by a fault, returns a list of all
-// transitively affected entity IDs.
+// transitively affected entity IDs. See package impact for a model covering
+// every kind of entity, which also explains why each entity is affected.
 func ComputeTransitivelyAffectedIDsForFault(g *graph.Graph, affectedEntityIDs []string) []string {
 	computedEntityIDs := computeTransitivelyAffectedIDsForFaultHelper(g, set.NewSet[string](affectedEntityIDs...), set.NewSet[string]())
 	// Remove any transitively affected entities that are in affected entities.
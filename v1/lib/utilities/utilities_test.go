@@ -15,6 +15,7 @@
 package utilities_test
 
 import (
+	"fmt"
 	"slices"
 	"strings"
 	"testing"
@@ -729,6 +730,27 @@ func TestFindEncompassingPlatformForAntenna(t *testing.T) {
 	}
 }
 
+func TestFindEncompassingPlatformFindsNearest(t *testing.T) {
+	// The demodulator is contained by platform "near", and also terminates a port contained by
+	// platform "a_far", one relationship further away.
+	const txtpb = `
+entity { id: "near" ek_platform{} }
+entity { id: "a_far" ek_platform{} }
+entity { id: "demod" ek_demodulator{} }
+entity { id: "port" ek_port{} }
+relationship { a: "near" kind: RK_CONTAINS z: "demod" }
+relationship { a: "a_far" kind: RK_CONTAINS z: "port" }
+relationship { a: "port" kind: RK_TERMINATES z: "demod" }
+`
+	g := lo.Must(testutil.GraphFromFragments(lo.Must(testutil.FragmentFrom(txtpb))))
+	if p := graphutil.FindEncompassingPlatform(g, "demod"); p != "near" {
+		t.Errorf("want: %q, got: %q", "near", p)
+	}
+	if p := graphutil.FindEncompassingPlatform(g, "port"); p != "a_far" {
+		t.Errorf("want: %q, got: %q", "a_far", p)
+	}
+}
+
 func FindEncompassingNetworkNodeForNetworkNode(t *testing.T) {
 	g := getBasicWorkingGraph()
 	const networkNodeID = "uuid(gs1/network_node)"
@@ -1195,3 +1217,122 @@ func TestComputeTransitivelyAffectedIDsForPlatform(t *testing.T) {
 		t.Errorf("unexpected transitively affected IDs (-want +got): %s", diff)
 	}
 }
+
+const (
+	benchmarkNodes             = 10000
+	benchmarkInterfacesPerNode = 9
+)
+
+// getBenchmarkGraph returns a graph of just over 100k entities: an agent controlling every network
+// node, each of which contains its interfaces.
+func getBenchmarkGraph(b *testing.B) *graph.Graph {
+	b.Helper()
+	g := graph.New()
+	mustUpsert := func(entity *nmtspb.Entity) {
+		if _, err := g.UpsertEntity(entity); err != nil {
+			b.Fatalf("unable to upsert entity: %v", err)
+		}
+	}
+	mustAdd := func(a string, kind nmtspb.RK, z string) {
+		if _, err := g.AddRelationship(&nmtspb.Relationship{A: a, Kind: kind, Z: z}); err != nil {
+			b.Fatalf("unable to add relationship: %v", err)
+		}
+	}
+
+	mustUpsert(&nmtspb.Entity{Id: "agent", Kind: &nmtspb.Entity_EkSdnAgent{EkSdnAgent: &logicalpb.SdnAgent{}}})
+	for n := range benchmarkNodes {
+		node := fmt.Sprintf("node%d", n)
+		mustUpsert(&nmtspb.Entity{Id: node, Kind: &nmtspb.Entity_EkNetworkNode{EkNetworkNode: &logicalpb.NetworkNode{}}})
+		mustAdd("agent", nmtspb.RK_RK_CONTROLS, node)
+		for i := range benchmarkInterfacesPerNode {
+			iface := fmt.Sprintf("%s/interface%d", node, i)
+			mustUpsert(&nmtspb.Entity{Id: iface, Kind: &nmtspb.Entity_EkInterface{EkInterface: &logicalpb.Interface{}}})
+			mustAdd(node, nmtspb.RK_RK_CONTAINS, iface)
+		}
+	}
+	return g
+}
+
+// The scanning implementations are those GetZsFromA and GetAFromZ had before the graph indexed
+// edges by direction and kind, kept to measure the speedup.
+func getZsFromAByScanning(g *graph.Graph, aID string, relationship nmtspb.RK, isDesiredID func(id string, _ int) bool) []string {
+	outEdges := lo.Filter(graphutil.EdgesIncidentTo(g, aID).ToSlice(), func(e *graph.Edge, _ int) bool {
+		return e.GetA() == aID
+	})
+	outIDs := set.NewSet[string]()
+	for edge := range graphutil.FilterFor(graphutil.NewEdgeSetOf(outEdges...), relationship).Iter() {
+		outIDs.Add(edge.GetZ())
+	}
+	return lo.Filter(outIDs.ToSlice(), isDesiredID)
+}
+
+func getAFromZByScanning(g *graph.Graph, zID string, relationship nmtspb.RK, isDesiredID func(string) bool) (string, bool) {
+	inEdges := lo.Filter(graphutil.EdgesIncidentTo(g, zID).ToSlice(), func(e *graph.Edge, _ int) bool {
+		return e.GetZ() == zID
+	})
+	inIDs := set.NewSet[string]()
+	for edge := range graphutil.FilterFor(graphutil.NewEdgeSetOf(inEdges...), relationship).Iter() {
+		inIDs.Add(edge.GetA())
+	}
+	return lo.Find(inIDs.ToSlice(), isDesiredID)
+}
+
+func BenchmarkGetZsFromA(b *testing.B) {
+	g := getBenchmarkGraph(b)
+	isInterface := func(id string, _ int) bool {
+		return g.Node(id).GetEntity().GetEkInterface() != nil
+	}
+	isNode := func(id string, _ int) bool {
+		return g.Node(id).GetEntity().GetEkNetworkNode() != nil
+	}
+	for _, impl := range []struct {
+		name       string
+		getZsFromA func(*graph.Graph, string, nmtspb.RK, func(string, int) bool) []string
+	}{
+		{"indexed", graphutil.GetZsFromA},
+		{"scanning", getZsFromAByScanning},
+	} {
+		b.Run("interfaces/"+impl.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				node := fmt.Sprintf("node%d", i%benchmarkNodes)
+				if got := impl.getZsFromA(g, node, nmtspb.RK_RK_CONTAINS, isInterface); len(got) != benchmarkInterfacesPerNode {
+					b.Fatalf("got %d interfaces of %s; want %d", len(got), node, benchmarkInterfacesPerNode)
+				}
+			}
+		})
+		b.Run("controlled_nodes/"+impl.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if got := impl.getZsFromA(g, "agent", nmtspb.RK_RK_CONTROLS, isNode); len(got) != benchmarkNodes {
+					b.Fatalf("got %d nodes controlled by agent; want %d", len(got), benchmarkNodes)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetAFromZ(b *testing.B) {
+	g := getBenchmarkGraph(b)
+	isNode := func(id string) bool {
+		return g.Node(id).GetEntity().GetEkNetworkNode() != nil
+	}
+	for _, impl := range []struct {
+		name      string
+		getAFromZ func(*graph.Graph, string, nmtspb.RK, func(string) bool) (string, bool)
+	}{
+		{"indexed", graphutil.GetAFromZ},
+		{"scanning", getAFromZByScanning},
+	} {
+		b.Run(impl.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				n := i % benchmarkNodes
+				iface := fmt.Sprintf("node%d/interface%d", n, i%benchmarkInterfacesPerNode)
+				if _, ok := impl.getAFromZ(g, iface, nmtspb.RK_RK_CONTAINS, isNode); !ok {
+					b.Fatalf("no node contains %s", iface)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) Outernet Council and Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+
+	er "outernetcouncil.org/nmts/v1/lib/entityrelationship"
+)
+
+// MalformedIDError reports an entity ID that breaks one of the rules
+// checked by IsEntityMinimallyWellFormed.
+type MalformedIDError struct {
+	ID string
+	// Reason describes the rule that was broken, e.g. "MUST NOT be empty".
+	Reason string
+}
+
+func (e *MalformedIDError) Error() string {
+	return fmt.Sprintf("entity ID '%v' %s", e.ID, e.Reason)
+}
+
+// UnsupportedRelationshipError reports a relationship that is not permitted
+// between entities of its endpoints' kinds.
+type UnsupportedRelationshipError struct {
+	Relationship er.Relationship
+	// Key is the relationship's class, which is absent from the permitted
+	// relationships.
+	Key AllowedRelationship
+}
+
+func (e *UnsupportedRelationshipError) Error() string {
+	return fmt.Sprintf("unsupported relationship between entites: '%v' i.e. '%v'", e.Relationship.String(), e.Key)
+}
+
+// AntennaFieldError reports an invalid field of an EK_ANTENNA entity.
+type AntennaFieldError struct {
+	ID string
+	// Path identifies the field within the Antenna message, e.g.
+	// "eirp_limits.eirpsd_masks[0].power_spectral_density".
+	Path    string
+	Message string
+}
+
+func (e *AntennaFieldError) Error() string {
+	return fmt.Sprintf("antenna '%v': %s: %s", e.ID, e.Path, e.Message)
+}
+
+// fieldError returns an AntennaFieldError for the field at path, relative
+// to whatever message is being validated. Callers validating the enclosing
+// message add their own path with within.
+func fieldError(path, format string, args ...any) *AntennaFieldError {
+	return &AntennaFieldError{Path: path, Message: fmt.Sprintf(format, args...)}
+}
+
+// within returns a copy of e for the antenna with the given ID, whose path
+// is prefixed with that of the enclosing message.
+func (e *AntennaFieldError) within(id, path string) *AntennaFieldError {
+	return &AntennaFieldError{ID: id, Path: path + "." + e.Path, Message: e.Message}
+}
@@ -34,18 +34,18 @@ func IsEntityMinimallyWellFormed(entity *npb.Entity) error {
 	// In keeping with https://google.aip.dev/210#normalization
 	// ensure Entity IDs are in Unicode Normal Form C.
 	if norm.NFC.String(id) != id {
-		return fmt.Errorf("entity ID MUST be in Unicode Normalization Form C")
+		return &MalformedIDError{ID: id, Reason: "MUST be in Unicode Normalization Form C"}
 	}
 
 	// Do not permit extraneous whitespace; this likely indicates
 	// some configuration or tooling error.
 	if id != strings.TrimSpace(id) {
-		return fmt.Errorf("id must not have lead nor trailing whitespace: '%s'", id)
+		return &MalformedIDError{ID: id, Reason: "MUST NOT have leading or trailing whitespace"}
 	}
 
 	// TODO: uuid.Validate(id)
 	if id == "" {
-		return fmt.Errorf("id must not be empty: %q", id)
+		return &MalformedIDError{ID: id, Reason: "MUST NOT be empty"}
 	}
 
 	if er.EntityKindStringFromProto(entity) == "" {
@@ -68,88 +68,85 @@ func ValidateAntenna(entity *npb.Entity) error {
 	hasReceiveAntennaPattern := len(antenna.GetAntennaPattern().GetReceiveFrequencyRangeToGainPatterns()) > 0
 	hasNoiseTemperature := antenna.GetAntennaNoiseTemperatureK() != 0
 	if hasGOverT && (hasReceiveAntennaPattern || hasNoiseTemperature) {
-		return fmt.Errorf(
-			"Antenna %q: configurations that include G/T with either a "+
+		return &AntennaFieldError{
+			ID:   entity.GetId(),
+			Path: "g_over_t_db_per_k",
+			Message: "configurations that include G/T with either a " +
 				"receive antenna pattern or sources of noise are invalid",
-			entity.GetId(),
-		)
+		}
 	}
 
-	if err := validateEirpLimits(antenna.GetEirpLimits(), entity.GetId()); err != nil {
-		return err
+	for i, mask := range antenna.GetEirpLimits().GetEirpsdMasks() {
+		if err := validateEirpsdMask(mask); err != nil {
+			return err.within(entity.GetId(), fmt.Sprintf("eirp_limits.eirpsd_masks[%d]", i))
+		}
 	}
 
 	for i, mask := range antenna.GetEmissionEnvelope().GetEirpsdMasks() {
 		if err := validateEirpsdMask(mask); err != nil {
-			return fmt.Errorf("antenna %q: emission_envelope.eirpsd_masks[%d]: %w", entity.GetId(), i, err)
+			return err.within(entity.GetId(), fmt.Sprintf("emission_envelope.eirpsd_masks[%d]", i))
 		}
 	}
 
 	return nil
 }
 
-// validateEirpLimits checks an antenna's eirp_limits and its nested EIRPSD masks.
-// eirp_limits is optional, so a nil value is valid.
-func validateEirpLimits(limits *physicalpb.EirpLimits, id string) error {
-	if limits == nil {
-		return nil
-	}
-	for i, mask := range limits.GetEirpsdMasks() {
-		if err := validateEirpsdMask(mask); err != nil {
-			return fmt.Errorf("Antenna %q: eirp_limits.eirpsd_masks[%d]: %w", id, i, err)
-		}
-	}
-	return nil
-}
+// The validators of messages nested within an Antenna return an
+// AntennaFieldError with a path relative to the message they validate, or
+// nil, for the caller to place within the antenna.
 
-func validateEirpsdMask(mask *physicalpb.EirpsdMask) error {
+func validateEirpsdMask(mask *physicalpb.EirpsdMask) *AntennaFieldError {
 	psd := mask.GetPowerSpectralDensity()
 	if psd == nil {
-		return fmt.Errorf("power_spectral_density is required")
+		return fieldError("power_spectral_density", "is required")
 	}
 	// frequency_range is optional (an unset range applies to all frequencies), but
 	// when present it must be a non-empty half-open [min, max) interval.
 	if fr := mask.GetFrequencyRange(); fr != nil {
 		if fr.GetMinFrequencyHz() < 0 {
-			return fmt.Errorf("frequency_range.min_frequency_hz must be non-negative: %d", fr.GetMinFrequencyHz())
+			return fieldError("frequency_range.min_frequency_hz", "must be non-negative: %d", fr.GetMinFrequencyHz())
 		}
 		if fr.GetMaxFrequencyHz() <= fr.GetMinFrequencyHz() {
-			return fmt.Errorf(
-				"frequency_range.max_frequency_hz (%d) must be greater than min_frequency_hz (%d)",
-				fr.GetMaxFrequencyHz(), fr.GetMinFrequencyHz())
+			return fieldError("frequency_range.max_frequency_hz",
+				"must be greater than min_frequency_hz (%d), got %d",
+				fr.GetMinFrequencyHz(), fr.GetMaxFrequencyHz())
 		}
 	}
-	return validatePowerSpectralDensity(psd)
+	if err := validatePowerSpectralDensity(psd); err != nil {
+		return err.within("", "power_spectral_density")
+	}
+	return nil
 }
 
-func validatePowerSpectralDensity(psd *physicalpb.PowerSpectralDensity) error {
+func validatePowerSpectralDensity(psd *physicalpb.PowerSpectralDensity) *AntennaFieldError {
 	if psd.GetReferenceBandwidthHz() <= 0.0 {
-		return fmt.Errorf(
-			"power_spectral_density.reference_bandwidth_hz must be positive: %v",
-			psd.GetReferenceBandwidthHz())
+		return fieldError("reference_bandwidth_hz", "must be positive: %v", psd.GetReferenceBandwidthHz())
 	}
 	switch t := psd.GetType().(type) {
 	case *physicalpb.PowerSpectralDensity_Fixed:
 		// Any finite power_dbw is valid; proto3 cannot distinguish an unset scalar from 0.
 		return nil
 	case *physicalpb.PowerSpectralDensity_OffAxis:
-		return validateOffAxisPower(t.OffAxis)
+		if err := validateOffAxisPower(t.OffAxis); err != nil {
+			return err.within("", "off_axis")
+		}
+		return nil
 	case nil:
-		return fmt.Errorf("power_spectral_density.type must be set")
+		return fieldError("type", "must be set")
 	default:
-		return fmt.Errorf("power_spectral_density has unknown type: %T", t)
+		return fieldError("type", "unknown type %T", t)
 	}
 }
 
-func validateOffAxisPower(o *physicalpb.PowerSpectralDensity_OffAxisPower) error {
+func validateOffAxisPower(o *physicalpb.PowerSpectralDensity_OffAxisPower) *AntennaFieldError {
 	points := o.GetControlPoints()
 	if len(points) < 2 {
-		return fmt.Errorf("off_axis.control_points must have at least two entries, got %d", len(points))
+		return fieldError("control_points", "must have at least two entries, got %d", len(points))
 	}
 	for i := 1; i < len(points); i++ {
 		if points[i].GetAngleDeg() < points[i-1].GetAngleDeg() {
-			return fmt.Errorf(
-				"off_axis.control_points must be ordered by non-decreasing angle_deg: %v after %v",
+			return fieldError(fmt.Sprintf("control_points[%d].angle_deg", i),
+				"must not be less than that of the previous control point: %v after %v",
 				points[i].GetAngleDeg(), points[i-1].GetAngleDeg())
 		}
 	}
@@ -167,45 +164,47 @@ func (DefaultValidator) ValidateEntity(coll *er.Collection, entity *npb.Entity)
 	return ValidateAntenna(entity)
 }
 
-type allowedRelationship struct {
-	a, z string
-	rk   npb.RK
+// AllowedRelationship identifies a class of relationship by the kinds of
+// its A and Z entities and its own kind.
+type AllowedRelationship struct {
+	A, Z string
+	RK   npb.RK
 }
 
-func (aRel allowedRelationship) String() string {
-	return fmt.Sprintf("%s->%s->%s", aRel.a, aRel.rk, aRel.z)
+func (aRel AllowedRelationship) String() string {
+	return fmt.Sprintf("%s->%s->%s", aRel.A, aRel.RK, aRel.Z)
 }
 
-var permittedRelationships = map[allowedRelationship]struct{}{
-	{a: "EK_ANTENNA", rk: npb.RK_RK_ORIGINATES, z: "EK_PHYSICAL_MEDIUM_LINK"}: {},
-	{a: "EK_ANTENNA", rk: npb.RK_RK_SIGNAL_TRANSITS, z: "EK_RECEIVER"}:        {},
-	{a: "EK_ANTENNA", rk: npb.RK_RK_TERMINATES, z: "EK_PHYSICAL_MEDIUM_LINK"}: {},
+var permittedRelationships = map[AllowedRelationship]struct{}{
+	{A: "EK_ANTENNA", RK: npb.RK_RK_ORIGINATES, Z: "EK_PHYSICAL_MEDIUM_LINK"}: {},
+	{A: "EK_ANTENNA", RK: npb.RK_RK_SIGNAL_TRANSITS, Z: "EK_RECEIVER"}:        {},
+	{A: "EK_ANTENNA", RK: npb.RK_RK_TERMINATES, Z: "EK_PHYSICAL_MEDIUM_LINK"}: {},
 
-	{a: "EK_INTERFACE", rk: npb.RK_RK_DATA_TRANSITS, z: "EK_INTERNAL_FABRIC"}:  {},
-	{a: "EK_INTERFACE", rk: npb.RK_RK_ORIGINATES, z: "EK_LOGICAL_PACKET_LINK"}: {},
-	{a: "EK_INTERFACE", rk: npb.RK_RK_TERMINATES, z: "EK_LOGICAL_PACKET_LINK"}: {},
-	{a: "EK_INTERFACE", rk: npb.RK_RK_TRAVERSES, z: "EK_PORT"}:                 {},
-	{a: "EK_INTERFACE", rk: npb.RK_RK_TRAVERSES, z: "EK_INTERFACE"}:            {},
+	{A: "EK_INTERFACE", RK: npb.RK_RK_DATA_TRANSITS, Z: "EK_INTERNAL_FABRIC"}:  {},
+	{A: "EK_INTERFACE", RK: npb.RK_RK_ORIGINATES, Z: "EK_LOGICAL_PACKET_LINK"}: {},
+	{A: "EK_INTERFACE", RK: npb.RK_RK_TERMINATES, Z: "EK_LOGICAL_PACKET_LINK"}: {},
+	{A: "EK_INTERFACE", RK: npb.RK_RK_TRAVERSES, Z: "EK_PORT"}:                 {},
+	{A: "EK_INTERFACE", RK: npb.RK_RK_TRAVERSES, Z: "EK_INTERFACE"}:            {},
 
-	{a: "EK_INTERNAL_FABRIC", rk: npb.RK_RK_DATA_TRANSITS, z: "EK_INTERFACE"}:       {},
-	{a: "EK_INTERNAL_FABRIC", rk: npb.RK_RK_DATA_TRANSITS, z: "EK_INTERNAL_FABRIC"}: {},
+	{A: "EK_INTERNAL_FABRIC", RK: npb.RK_RK_DATA_TRANSITS, Z: "EK_INTERFACE"}:       {},
+	{A: "EK_INTERNAL_FABRIC", RK: npb.RK_RK_DATA_TRANSITS, Z: "EK_INTERNAL_FABRIC"}: {},
 
-	{a: "EK_LOGICAL_PACKET_LINK", rk: npb.RK_RK_TRAVERSES, z: "EK_PHYSICAL_MEDIUM_LINK"}: {},
-	{a: "EK_LOGICAL_PACKET_LINK", rk: npb.RK_RK_TRAVERSES, z: "EK_LOGICAL_PACKET_LINK"}:  {},
+	{A: "EK_LOGICAL_PACKET_LINK", RK: npb.RK_RK_TRAVERSES, Z: "EK_PHYSICAL_MEDIUM_LINK"}: {},
+	{A: "EK_LOGICAL_PACKET_LINK", RK: npb.RK_RK_TRAVERSES, Z: "EK_LOGICAL_PACKET_LINK"}:  {},
 
-	{a: "EK_MODULATOR", rk: npb.RK_RK_SIGNAL_TRANSITS, z: "EK_SIGNAL_PROCESSING_CHAIN"}: {},
+	{A: "EK_MODULATOR", RK: npb.RK_RK_SIGNAL_TRANSITS, Z: "EK_SIGNAL_PROCESSING_CHAIN"}: {},
 
-	{a: "EK_NETWORK_NODE", rk: npb.RK_RK_CONTAINS, z: "EK_ACCESS_FN"}:       {},
-	{a: "EK_NETWORK_NODE", rk: npb.RK_RK_CONTAINS, z: "EK_BP_AGENT_FN"}:     {},
-	{a: "EK_NETWORK_NODE", rk: npb.RK_RK_CONTAINS, z: "EK_INTERFACE"}:       {},
-	{a: "EK_NETWORK_NODE", rk: npb.RK_RK_CONTAINS, z: "EK_INTERNAL_FABRIC"}: {},
-	{a: "EK_NETWORK_NODE", rk: npb.RK_RK_CONTAINS, z: "EK_ROUTE_FN"}:        {},
-	{a: "EK_NETWORK_NODE", rk: npb.RK_RK_CONTAINS, z: "EK_SWITCH_FN"}:       {},
-	{a: "EK_NETWORK_NODE", rk: npb.RK_RK_CONTAINS, z: "EK_SDN_AGENT"}:       {},
+	{A: "EK_NETWORK_NODE", RK: npb.RK_RK_CONTAINS, Z: "EK_ACCESS_FN"}:       {},
+	{A: "EK_NETWORK_NODE", RK: npb.RK_RK_CONTAINS, Z: "EK_BP_AGENT_FN"}:     {},
+	{A: "EK_NETWORK_NODE", RK: npb.RK_RK_CONTAINS, Z: "EK_INTERFACE"}:       {},
+	{A: "EK_NETWORK_NODE", RK: npb.RK_RK_CONTAINS, Z: "EK_INTERNAL_FABRIC"}: {},
+	{A: "EK_NETWORK_NODE", RK: npb.RK_RK_CONTAINS, Z: "EK_ROUTE_FN"}:        {},
+	{A: "EK_NETWORK_NODE", RK: npb.RK_RK_CONTAINS, Z: "EK_SWITCH_FN"}:       {},
+	{A: "EK_NETWORK_NODE", RK: npb.RK_RK_CONTAINS, Z: "EK_SDN_AGENT"}:       {},
 
-	{a: "EK_PLATFORM", rk: npb.RK_RK_CONTAINS, z: "EK_ANTENNA"}:     {},
-	{a: "EK_PLATFORM", rk: npb.RK_RK_CONTAINS, z: "EK_DEMODULATOR"}: {},
-	{a: "EK_PLATFORM", rk: npb.RK_RK_CONTAINS, z: "EK_MODULATOR"}:   {},
+	{A: "EK_PLATFORM", RK: npb.RK_RK_CONTAINS, Z: "EK_ANTENNA"}:     {},
+	{A: "EK_PLATFORM", RK: npb.RK_RK_CONTAINS, Z: "EK_DEMODULATOR"}: {},
+	{A: "EK_PLATFORM", RK: npb.RK_RK_CONTAINS, Z: "EK_MODULATOR"}:   {},
 	// It's theoretically possible to model a system as a platform of
 	// platforms. This can, however, have complicating implications
 	// for some code that might try to examine and enforce certain
@@ -213,39 +212,42 @@ var permittedRelationships = map[allowedRelationship]struct{}{
 	//
 	// Leave this commented out, as it will likely be revisited.
 	//
-	// {a: "EK_PLATFORM", rk: npb.RK_RK_CONTAINS, z: "EK_PLATFORM"}:                {},
-	{a: "EK_PLATFORM", rk: npb.RK_RK_CONTAINS, z: "EK_PORT"}:                    {},
-	{a: "EK_PLATFORM", rk: npb.RK_RK_CONTAINS, z: "EK_NETWORK_NODE"}:            {},
-	{a: "EK_PLATFORM", rk: npb.RK_RK_CONTAINS, z: "EK_RECEIVER"}:                {},
-	{a: "EK_PLATFORM", rk: npb.RK_RK_CONTAINS, z: "EK_SIGNAL_PROCESSING_CHAIN"}: {},
-	{a: "EK_PLATFORM", rk: npb.RK_RK_CONTAINS, z: "EK_TRANSMITTER"}:             {},
-
-	{a: "EK_PORT", rk: npb.RK_RK_ORIGINATES, z: "EK_MODULATOR"}:            {},
-	{a: "EK_PORT", rk: npb.RK_RK_ORIGINATES, z: "EK_PHYSICAL_MEDIUM_LINK"}: {},
-	{a: "EK_PORT", rk: npb.RK_RK_TERMINATES, z: "EK_DEMODULATOR"}:          {},
-	{a: "EK_PORT", rk: npb.RK_RK_TERMINATES, z: "EK_PHYSICAL_MEDIUM_LINK"}: {},
-
-	{a: "EK_RECEIVER", rk: npb.RK_RK_SIGNAL_TRANSITS, z: "EK_SIGNAL_PROCESSING_CHAIN"}: {},
-
-	{a: "EK_ROUTE_FN", rk: npb.RK_RK_CONTROLS, z: "EK_NETWORK_NODE"}: {},
-
-	{a: "EK_SDN_AGENT", rk: npb.RK_RK_CONTROLS, z: "EK_ACCESS_FN"}:    {},
-	{a: "EK_SDN_AGENT", rk: npb.RK_RK_CONTROLS, z: "EK_ANTENNA"}:      {},
-	{a: "EK_SDN_AGENT", rk: npb.RK_RK_CONTROLS, z: "EK_BP_AGENT_FN"}:  {},
-	{a: "EK_SDN_AGENT", rk: npb.RK_RK_CONTROLS, z: "EK_DEMODULATOR"}:  {},
-	{a: "EK_SDN_AGENT", rk: npb.RK_RK_CONTROLS, z: "EK_MODULATOR"}:    {},
-	{a: "EK_SDN_AGENT", rk: npb.RK_RK_CONTROLS, z: "EK_NETWORK_NODE"}: {},
-	{a: "EK_SDN_AGENT", rk: npb.RK_RK_CONTROLS, z: "EK_PLATFORM"}:     {},
-	{a: "EK_SDN_AGENT", rk: npb.RK_RK_CONTROLS, z: "EK_ROUTE_FN"}:     {},
-
-	{a: "EK_SIGNAL_PROCESSING_CHAIN", rk: npb.RK_RK_SIGNAL_TRANSITS, z: "EK_DEMODULATOR"}:             {},
-	{a: "EK_SIGNAL_PROCESSING_CHAIN", rk: npb.RK_RK_SIGNAL_TRANSITS, z: "EK_SIGNAL_PROCESSING_CHAIN"}: {},
-	{a: "EK_SIGNAL_PROCESSING_CHAIN", rk: npb.RK_RK_SIGNAL_TRANSITS, z: "EK_TRANSMITTER"}:             {},
-
-	{a: "EK_TRANSMITTER", rk: npb.RK_RK_SIGNAL_TRANSITS, z: "EK_ANTENNA"}: {},
-
-	{a: "EK_TRANSMITTER", rk: npb.RK_RK_SUPPORTS, z: "EK_CARRIER_CONFIGURATION"}: {},
-	{a: "EK_RECEIVER", rk: npb.RK_RK_SUPPORTS, z: "EK_CARRIER_CONFIGURATION"}:    {},
+	// {A: "EK_PLATFORM", RK: npb.RK_RK_CONTAINS, Z: "EK_PLATFORM"}:                {},
+	{A: "EK_PLATFORM", RK: npb.RK_RK_CONTAINS, Z: "EK_PORT"}:                    {},
+	{A: "EK_PLATFORM", RK: npb.RK_RK_CONTAINS, Z: "EK_NETWORK_NODE"}:            {},
+	{A: "EK_PLATFORM", RK: npb.RK_RK_CONTAINS, Z: "EK_RECEIVER"}:                {},
+	{A: "EK_PLATFORM", RK: npb.RK_RK_CONTAINS, Z: "EK_SIGNAL_PROCESSING_CHAIN"}: {},
+	{A: "EK_PLATFORM", RK: npb.RK_RK_CONTAINS, Z: "EK_TRANSMITTER"}:             {},
+
+	{A: "EK_PORT", RK: npb.RK_RK_ORIGINATES, Z: "EK_MODULATOR"}:            {},
+	{A: "EK_PORT", RK: npb.RK_RK_ORIGINATES, Z: "EK_PHYSICAL_MEDIUM_LINK"}: {},
+	{A: "EK_PORT", RK: npb.RK_RK_TERMINATES, Z: "EK_DEMODULATOR"}:          {},
+	{A: "EK_PORT", RK: npb.RK_RK_TERMINATES, Z: "EK_PHYSICAL_MEDIUM_LINK"}: {},
+
+	{A: "EK_RECEIVER", RK: npb.RK_RK_SIGNAL_TRANSITS, Z: "EK_SIGNAL_PROCESSING_CHAIN"}: {},
+
+	{A: "EK_ROUTE_FN", RK: npb.RK_RK_CONTROLS, Z: "EK_NETWORK_NODE"}: {},
+
+	{A: "EK_SDN_AGENT", RK: npb.RK_RK_CONTROLS, Z: "EK_ACCESS_FN"}:    {},
+	{A: "EK_SDN_AGENT", RK: npb.RK_RK_CONTROLS, Z: "EK_ANTENNA"}:      {},
+	{A: "EK_SDN_AGENT", RK: npb.RK_RK_CONTROLS, Z: "EK_BP_AGENT_FN"}:  {},
+	{A: "EK_SDN_AGENT", RK: npb.RK_RK_CONTROLS, Z: "EK_DEMODULATOR"}:  {},
+	{A: "EK_SDN_AGENT", RK: npb.RK_RK_CONTROLS, Z: "EK_MODULATOR"}:    {},
+	{A: "EK_SDN_AGENT", RK: npb.RK_RK_CONTROLS, Z: "EK_NETWORK_NODE"}: {},
+	{A: "EK_SDN_AGENT", RK: npb.RK_RK_CONTROLS, Z: "EK_PLATFORM"}:     {},
+	{A: "EK_SDN_AGENT", RK: npb.RK_RK_CONTROLS, Z: "EK_ROUTE_FN"}:     {},
+
+	{A: "EK_SIGNAL_PROCESSING_CHAIN", RK: npb.RK_RK_SIGNAL_TRANSITS, Z: "EK_DEMODULATOR"}:             {},
+	{A: "EK_SIGNAL_PROCESSING_CHAIN", RK: npb.RK_RK_SIGNAL_TRANSITS, Z: "EK_SIGNAL_PROCESSING_CHAIN"}: {},
+	{A: "EK_SIGNAL_PROCESSING_CHAIN", RK: npb.RK_RK_SIGNAL_TRANSITS, Z: "EK_TRANSMITTER"}:             {},
+
+	{A: "EK_TRANSMITTER", RK: npb.RK_RK_SIGNAL_TRANSITS, Z: "EK_ANTENNA"}: {},
+
+	{A: "EK_TRANSMITTER", RK: npb.RK_RK_SUPPORTS, Z: "EK_CARRIER_CONFIGURATION"}: {},
+	{A: "EK_RECEIVER", RK: npb.RK_RK_SUPPORTS, Z: "EK_CARRIER_CONFIGURATION"}:    {},
+
+	// See the EK_UNI documentation in uni.proto.
+	{A: "EK_UNI", RK: npb.RK_RK_TRAVERSES, Z: "EK_INTERFACE"}: {},
 }
 
 // Validate each relationship as it's loaded within the collection
@@ -254,7 +256,7 @@ func (DefaultValidator) ValidateRelationship(coll *er.Collection, rel er.Relatio
 	kindA := er.EntityKindStringFromProto(coll.Entities[rel.A])
 	kindZ := er.EntityKindStringFromProto(coll.Entities[rel.Z])
 
-	key := allowedRelationship{a: kindA, rk: rel.Kind, z: kindZ}
+	key := AllowedRelationship{A: kindA, RK: rel.Kind, Z: kindZ}
 	if _, ok := permittedRelationships[key]; ok {
 		return nil
 	}
@@ -262,7 +264,7 @@ func (DefaultValidator) ValidateRelationship(coll *er.Collection, rel er.Relatio
 	// More detailed checks can be added here, after basic validity
 	// has been checked and before the "default deny" error.
 
-	return fmt.Errorf("unsupported relationship between entites: '%v' i.e. '%v'", rel.String(), key)
+	return &UnsupportedRelationshipError{Relationship: rel, Key: key}
 }
 
 // Validate the complete collection.
@@ -278,20 +280,25 @@ func (DefaultValidator) ValidateCollection(coll *er.Collection) error {
 
 type DefaultGraphValidator struct{}
 
+// Validate each entity as it's added to the graph.
+func (DefaultGraphValidator) ValidateEntity(g *graph.Graph, entity *npb.Entity) error {
+	return DefaultValidator{}.ValidateEntity(nil, entity)
+}
+
 func (DefaultGraphValidator) ValidateRelationship(g *graph.Graph, rel er.Relationship) error {
 	a := g.Node(rel.A)
 	if a == nil {
-		return fmt.Errorf("A entity %q does not exist", rel.A)
+		return &er.DanglingEndpointError{Relationship: rel, Endpoint: er.EndpointA}
 	}
 	kindA := a.GetKind()
 
 	z := g.Node(rel.Z)
 	if z == nil {
-		return fmt.Errorf("Z entity %q does not exist", rel.Z)
+		return &er.DanglingEndpointError{Relationship: rel, Endpoint: er.EndpointZ}
 	}
 	kindZ := z.GetKind()
 
-	key := allowedRelationship{a: kindA, rk: rel.Kind, z: kindZ}
+	key := AllowedRelationship{A: kindA, RK: rel.Kind, Z: kindZ}
 	if _, ok := permittedRelationships[key]; ok {
 		return nil
 	}
@@ -299,5 +306,5 @@ func (DefaultGraphValidator) ValidateRelationship(g *graph.Graph, rel er.Relatio
 	// More detailed checks can be added here, after basic validity
 	// has been checked and before the "default deny" error.
 
-	return fmt.Errorf("unsupported relationship between entites: '%v' i.e. '%v'", rel.String(), key)
+	return &UnsupportedRelationshipError{Relationship: rel, Key: key}
 }
@@ -15,6 +15,8 @@
 package validation_test
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"google.golang.org/protobuf/encoding/prototext"
@@ -565,3 +567,131 @@ func TestIsEntityMinimallyWellFormedChecksUnicodeNormalizationForm(t *testing.T)
 		t.Fatalf("failed to minimally validate %q: %q", brokenUnicodeCombiningAcute, err)
 	}
 }
+
+func TestGraphApplyWithDefaultGraphValidator(t *testing.T) {
+	g := graph.New()
+	patch := &npb.Patch{}
+	if err := prototext.Unmarshal([]byte(`
+add_entity { id: "platform" ek_platform{} }
+add_entity { id: "node" ek_network_node{} }
+add_entity { id: " padded" ek_port{} }
+add_relationship { a: "node" kind: RK_CONTAINS z: "platform" }
+`), patch); err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	err := g.Apply(patch, validation.DefaultGraphValidator{})
+	if err == nil {
+		t.Fatalf("Apply succeeded with a malformed ID and an unsupported relationship")
+	}
+	for _, want := range []string{"whitespace", "unsupported relationship"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if g.Node("platform") != nil {
+		t.Errorf("graph changed by failed Apply")
+	}
+}
+
+func TestGraphCommitWithDefaultGraphValidator(t *testing.T) {
+	g := graph.New()
+	mustUpsert := func(txtPb string) {
+		entity := new(npb.Entity)
+		if err := prototext.Unmarshal([]byte(txtPb), entity); err != nil {
+			t.Fatalf("failed to parse %q: %v", txtPb, err)
+		}
+		if _, err := g.UpsertEntity(entity); err != nil {
+			t.Fatalf("UpsertEntity: %v", err)
+		}
+	}
+	mustUpsert(`id: "platform" ek_platform{}`)
+	mustUpsert(`id: "node" ek_network_node{}`)
+
+	if err := g.Begin(); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	mustUpsert(`id: "port" ek_port{}`)
+	// Validated at Commit, once the batch is complete, so the order of the changes doesn't matter.
+	if _, err := g.AddRelationship(&npb.Relationship{A: "platform", Kind: npb.RK_RK_CONTAINS, Z: "node"}); err != nil {
+		t.Fatalf("AddRelationship: %v", err)
+	}
+	if _, err := g.AddRelationship(&npb.Relationship{A: "node", Kind: npb.RK_RK_CONTAINS, Z: "platform"}); err != nil {
+		t.Fatalf("AddRelationship: %v", err)
+	}
+	err := g.Commit(validation.DefaultGraphValidator{})
+	var unsupported *validation.UnsupportedRelationshipError
+	if !errors.As(err, &unsupported) || unsupported.Relationship.A != "node" {
+		t.Fatalf("Commit returned %v; wanted an UnsupportedRelationshipError for node->platform", err)
+	}
+	if g.Node("port") != nil || len(g.Neighbors("platform")) != 0 {
+		t.Errorf("graph changed by failed Commit")
+	}
+
+	if err := g.Begin(); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if _, err := g.AddRelationship(&npb.Relationship{A: "platform", Kind: npb.RK_RK_CONTAINS, Z: "node"}); err != nil {
+		t.Fatalf("AddRelationship: %v", err)
+	}
+	if err := g.Commit(validation.DefaultGraphValidator{}); err != nil {
+		t.Errorf("Commit: %v", err)
+	}
+}
+
+func TestValidationErrorsAreTyped(t *testing.T) {
+	mustParse := func(txtPb string) *npb.Entity {
+		entity := new(npb.Entity)
+		if err := prototext.Unmarshal([]byte(txtPb), entity); err != nil {
+			t.Fatalf("failed to parse %q: %v", txtPb, err)
+		}
+		return entity
+	}
+
+	var malformed *validation.MalformedIDError
+	err := validation.IsEntityMinimallyWellFormed(mustParse(`id: "node " ek_network_node{}`))
+	if !errors.As(err, &malformed) || malformed.ID != "node " {
+		t.Errorf("wanted a MalformedIDError for 'node ', got %v", err)
+	}
+
+	var antennaErr *validation.AntennaFieldError
+	err = validation.ValidateAntenna(mustParse(`id: "antenna"
+		ek_antenna {
+			emission_envelope {
+				eirpsd_masks { power_spectral_density { reference_bandwidth_hz: 1 fixed {} } }
+				eirpsd_masks {
+					power_spectral_density {
+						reference_bandwidth_hz: 1
+						off_axis {
+							control_points { angle_deg: 5 }
+							control_points { angle_deg: 0 }
+						}
+					}
+				}
+			}
+		}`))
+	wantPath := "emission_envelope.eirpsd_masks[1].power_spectral_density.off_axis.control_points[1].angle_deg"
+	if !errors.As(err, &antennaErr) || antennaErr.ID != "antenna" || antennaErr.Path != wantPath {
+		t.Errorf("wanted an AntennaFieldError for antenna at %q, got %v", wantPath, err)
+	}
+
+	coll := er.NewCollection()
+	for _, txtPb := range []string{`id: "port" ek_port{}`, `id: "platform" ek_platform{}`} {
+		if err := coll.InsertEntity(mustParse(txtPb)); err != nil {
+			t.Fatalf("InsertEntity: %v", err)
+		}
+	}
+	var unsupported *validation.UnsupportedRelationshipError
+	rel := er.Relationship{A: "port", Kind: npb.RK_RK_CONTAINS, Z: "platform"}
+	err = validation.DefaultValidator{}.ValidateRelationship(coll, rel)
+	wantKey := validation.AllowedRelationship{A: "EK_PORT", RK: npb.RK_RK_CONTAINS, Z: "EK_PLATFORM"}
+	if !errors.As(err, &unsupported) || unsupported.Key != wantKey || unsupported.Relationship != rel {
+		t.Errorf("wanted an UnsupportedRelationshipError with key %v, got %v", wantKey, err)
+	}
+
+	var dangling *er.DanglingEndpointError
+	err = validation.DefaultGraphValidator{}.ValidateRelationship(graph.New(), rel)
+	if !errors.As(err, &dangling) || dangling.ID() != "port" {
+		t.Errorf("wanted a DanglingEndpointError for 'port', got %v", err)
+	}
+}